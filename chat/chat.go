@@ -0,0 +1,135 @@
+package chat
+
+import (
+	"net/http"
+
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+type Action string
+
+const (
+	ActionCreatePoll  Action = "create_poll"
+	ActionVote        Action = "vote"
+	ActionRefresh     Action = "refresh"
+	ActionShowDetails Action = "show_details"
+	ActionClosePoll   Action = "close_poll"
+	// ActionRequestRanking asks for a form to rank the options of a ranked
+	// choice poll, ActionSubmitRanking submits the ranking.
+	ActionRequestRanking Action = "request_ranking"
+	ActionSubmitRanking  Action = "submit_ranking"
+	// ActionShowResults shows the current results of a poll to the user only.
+	ActionShowResults Action = "show_results"
+	ActionListPolls   Action = "list_polls"
+	ActionDeletePoll  Action = "delete_poll"
+	// ActionShowMyVote shows the user which options they voted for.
+	ActionShowMyVote Action = "show_my_vote"
+	// ActionRetractVote removes all votes of the user for a poll.
+	ActionRetractVote Action = "retract_vote"
+	// ActionRequestEdit asks for a form to edit a poll, ActionEditPoll applies
+	// the changes of the form.
+	ActionRequestEdit Action = "request_edit"
+	ActionEditPoll    Action = "edit_poll"
+	// ActionRequestWriteIn asks for a form to propose an option for a poll
+	// which allows write-ins, ActionAddWriteIn adds the proposed option.
+	ActionRequestWriteIn Action = "request_write_in"
+	ActionAddWriteIn     Action = "add_write_in"
+	// ActionDisableWriteIns stops further write-ins for a poll.
+	ActionDisableWriteIns Action = "disable_write_ins"
+	// ActionReplaceVote votes like ActionVote, but keeps the vote if the
+	// voter selects the chosen option of a single choice poll again.
+	ActionReplaceVote Action = "replace_vote"
+)
+
+// Command is a platform-neutral request of a chat user.
+type Command struct {
+	Action Action
+	// Platform is set to the name of the platform which parsed the command.
+	Platform string
+	UserID   string
+	// UserIsAdmin is set by platforms which know that the user administers
+	// the workspace, which allows deleting polls of other users.
+	UserIsAdmin bool
+	PollID      string
+	// Poll holds the poll to create for ActionCreatePoll. Its ID, creator and
+	// platform are set when the poll is created.
+	Poll poll.Poll
+	// Option and Ranking hold option IDs. OptionIsPosition is set if Option
+	// holds the position of the option instead, which is what buttons of
+	// messages sent before options had IDs contain.
+	Option           int
+	OptionIsPosition bool
+	Ranking          []int
+	// Edit holds the changes of the poll for ActionEditPoll.
+	Edit poll.PollEdit
+	// WriteIn holds the label of the option proposed with ActionAddWriteIn.
+	WriteIn string
+	// ResponseURL is used to update the poll message later on, if the platform
+	// supports it.
+	ResponseURL string
+	// Origin holds the platform specific request the command was parsed from,
+	// so the platform can use it when writing the reply.
+	Origin interface{}
+}
+
+type ReplyKind int
+
+const (
+	ReplyPoll ReplyKind = iota
+	ReplyVoteDetails
+	ReplyRankingForm
+	ReplyError
+	ReplyPollList
+	// ReplyNotice informs the user about the outcome of a command which
+	// doesn't change a poll message.
+	ReplyNotice
+	ReplyMyVote
+	ReplyEditForm
+	ReplyWriteInForm
+	// ReplyDeletedPoll tells the user with Text that Poll was deleted, the
+	// platform replaces the message of the poll.
+	ReplyDeletedPoll
+)
+
+// Reply is the platform-neutral result of a command. Depending on Kind only
+// some of the fields are set: Poll and either Results, Runoff or
+// ResultsHidden and Participants for ReplyPoll, Poll for ReplyRankingForm,
+// Details for ReplyVoteDetails, Polls for ReplyPollList, Poll and Choices for
+// ReplyMyVote, Poll for ReplyEditForm and ReplyWriteInForm, Text for
+// ReplyError and ReplyNotice and Poll and Text for ReplyDeletedPoll.
+type Reply struct {
+	Kind    ReplyKind
+	Poll    poll.Poll
+	Results map[int]uint64
+	Runoff  poll.RunoffResult
+	// ResultsHidden is set instead of the results for polls whose results are
+	// hidden until they are closed, only the number of voters is shown then.
+	ResultsHidden bool
+	Participants  int
+	// Choices holds the options the user currently votes for, as returned by
+	// poll.Store.GetVoterChoices. Replies to votes include them as well, so
+	// the platform can confirm the vote to the voter.
+	Choices []int
+	Details map[string][]string
+	Polls   []poll.Poll
+	Text    string
+}
+
+// Platform adapts a chat platform to the platform-neutral commands and
+// replies.
+type Platform interface {
+	Name() string
+	// ParseCommand reads a command from an inbound request. If the request
+	// can't be handled the platform writes the response itself and returns
+	// false.
+	ParseCommand(writer http.ResponseWriter, request *http.Request) (Command, bool)
+	WriteReply(writer http.ResponseWriter, command Command, reply Reply)
+}
+
+// MessageUpdater is implemented by platforms which can update the message of
+// a poll without a request, e.g. once a poll is closed by its deadline.
+type MessageUpdater interface {
+	// Name returns the name of the platform as stored in the polls.
+	Name() string
+	UpdatePollMessage(reply Reply) error
+}
@@ -0,0 +1,85 @@
+package chat
+
+import (
+	"log"
+	"net/http"
+
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+// NewRequestHandler returns a handler which parses commands with the
+// platform, executes them and writes the replies with the platform.
+func NewRequestHandler(platform Platform, service *Service) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		command, ok := platform.ParseCommand(writer, request)
+		if !ok {
+			return
+		}
+		command.Platform = platform.Name()
+		platform.WriteReply(writer, command, service.Execute(command))
+	}
+}
+
+// NewClosedPollNotifier returns a callback for poll.DeadlineScheduler which
+// updates the message of a poll closed by its deadline on the platform the
+// poll was created on. Polls without platform belong to defaultPlatform.
+func NewClosedPollNotifier(service *Service, logger *log.Logger, defaultPlatform string, platforms ...MessageUpdater) func(p poll.Poll) {
+	findUpdater := newUpdaterFinder(logger, defaultPlatform, platforms)
+	return func(p poll.Poll) {
+		logger.Printf("Poll %s was closed by its deadline", p.ID)
+		updater, ok := findUpdater(p)
+		if !ok {
+			return
+		}
+		reply, err := service.PollReply(p)
+		if err != nil {
+			logger.Println("Error calculating final poll count: ", err)
+			return
+		}
+		err = updater.UpdatePollMessage(reply)
+		if err != nil {
+			logger.Println("Error updating message of closed poll: ", err)
+		}
+	}
+}
+
+// NewDeletedPollNotifier returns a callback for poll.RetentionJanitor which
+// replaces the message of a deleted poll on the platform the poll was created
+// on. Polls without response URL have no message to replace.
+func NewDeletedPollNotifier(logger *log.Logger, defaultPlatform string, platforms ...MessageUpdater) func(p poll.Poll) {
+	findUpdater := newUpdaterFinder(logger, defaultPlatform, platforms)
+	return func(p poll.Poll) {
+		if p.ResponseURL == "" {
+			return
+		}
+		updater, ok := findUpdater(p)
+		if !ok {
+			return
+		}
+		err := updater.UpdatePollMessage(Reply{Kind: ReplyDeletedPoll, Poll: p})
+		if err != nil {
+			logger.Println("Error replacing message of deleted poll: ", err)
+		}
+	}
+}
+
+// newUpdaterFinder returns a function looking up the MessageUpdater of the
+// platform a poll was created on. Polls without platform belong to
+// defaultPlatform.
+func newUpdaterFinder(logger *log.Logger, defaultPlatform string, platforms []MessageUpdater) func(p poll.Poll) (MessageUpdater, bool) {
+	updaters := make(map[string]MessageUpdater)
+	for _, platform := range platforms {
+		updaters[platform.Name()] = platform
+	}
+	return func(p poll.Poll) (MessageUpdater, bool) {
+		platformName := p.Platform
+		if platformName == "" {
+			platformName = defaultPlatform
+		}
+		updater, ok := updaters[platformName]
+		if !ok {
+			logger.Printf("Platform %q of poll %s can't update poll messages", platformName, p.ID)
+		}
+		return updater, ok
+	}
+}
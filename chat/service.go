@@ -0,0 +1,360 @@
+package chat
+
+import (
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+const hiddenResultsMessage = "The results of this poll are hidden until it is closed!"
+
+// Service executes commands of all platforms on the poll store.
+type Service struct {
+	store  poll.Store
+	logger *log.Logger
+}
+
+func NewService(store poll.Store, logger *log.Logger) *Service {
+	return &Service{store, logger}
+}
+
+func (s *Service) Execute(command Command) Reply {
+	switch command.Action {
+	case ActionCreatePoll:
+		return s.createPoll(command)
+	case ActionVote, ActionReplaceVote, ActionSubmitRanking:
+		return s.vote(command)
+	case ActionRefresh:
+		return s.refresh(command)
+	case ActionShowDetails:
+		return s.showDetails(command)
+	case ActionClosePoll:
+		return s.closePoll(command)
+	case ActionRequestRanking:
+		return s.requestRanking(command)
+	case ActionShowResults:
+		return s.showResults(command)
+	case ActionListPolls:
+		return s.listPolls(command)
+	case ActionDeletePoll:
+		return s.deletePoll(command)
+	case ActionShowMyVote:
+		return s.showMyVote(command)
+	case ActionRetractVote:
+		return s.retractVote(command)
+	case ActionRequestEdit:
+		return s.requestEdit(command)
+	case ActionEditPoll:
+		return s.editPoll(command)
+	case ActionRequestWriteIn:
+		return s.requestWriteIn(command)
+	case ActionAddWriteIn:
+		return s.addWriteIn(command)
+	case ActionDisableWriteIns:
+		return s.disableWriteIns(command)
+	}
+	return s.errorReply(errors.Errorf("Unknown action %q", command.Action), "Invalid command: ", "Unknown command!")
+}
+
+func (s *Service) errorReply(err error, logMessage, userMessage string) Reply {
+	s.logger.Println(logMessage, err)
+	// The messages of deleted polls can still be clicked
+	if errors.Cause(err) == poll.ErrPollNotFound {
+		userMessage = "This poll doesn't exist anymore, it was deleted."
+	}
+	return Reply{Kind: ReplyError, Text: userMessage}
+}
+
+func (s *Service) createPoll(command Command) Reply {
+	newPoll := command.Poll
+	newPoll.ID = uuid.NewV4().String()
+	newPoll.CreatorID = command.UserID
+	newPoll.Platform = command.Platform
+	newPoll.ResponseURL = command.ResponseURL
+	newPoll.CreatedAt = time.Now().UTC()
+	err := s.store.AddPoll(newPoll)
+	if validationErr, ok := err.(*poll.ValidationError); ok {
+		return s.errorReply(err, "Invalid poll: ", validationErr.Message)
+	}
+	if err != nil {
+		return s.errorReply(err, "Error adding poll to store: ", "Error creating new poll!")
+	}
+	return s.pollReply(newPoll, "Error creating message for new poll: ", "Error creating new poll!")
+}
+
+func (s *Service) vote(command Command) Reply {
+	s.logger.Println("Handle new vote request")
+	if command.OptionIsPosition {
+		p, err := s.store.GetPoll(command.PollID)
+		if err != nil {
+			return s.errorReply(err, "Error fetching poll from store for vote: ", "Error submitting vote!")
+		}
+		if command.Option < 0 || command.Option >= len(p.Options) {
+			return s.errorReply(errors.Errorf("Invalid option position %d", command.Option), "Vote for unknown option: ", "Error submitting vote!")
+		}
+		command.Option = p.Options[command.Option].ID
+	}
+	vote := poll.Vote{ID: uuid.NewV4().String(), VoterID: command.UserID, PollID: command.PollID, VotedFor: command.Option, Ranking: command.Ranking}
+	var err error
+	if command.Action == ActionReplaceVote {
+		err = s.store.ReplaceVote(vote)
+	} else {
+		err = s.store.AddVote(vote)
+	}
+	if errors.Cause(err) == poll.ErrPollClosed {
+		return s.errorReply(err, "Vote for closed poll: ", "This poll is closed, votes aren't accepted anymore!")
+	}
+	if errors.Cause(err) == poll.ErrTooManyChoices {
+		return s.errorReply(err, "Too many choices: ", "You already selected the maximum number of options, remove one of your votes first!")
+	}
+	if err != nil {
+		return s.errorReply(err, "Error adding vote to store: ", "Error submitting vote!")
+	}
+	return s.confirmedRefresh(command)
+}
+
+func (s *Service) retractVote(command Command) Reply {
+	s.logger.Println("Handle retract vote request")
+	err := s.store.RetractVote(command.PollID, command.UserID)
+	if errors.Cause(err) == poll.ErrPollClosed {
+		return s.errorReply(err, "Vote retraction for closed poll: ", "This poll is closed, votes can't be withdrawn anymore!")
+	}
+	if err != nil {
+		return s.errorReply(err, "Error retracting vote: ", "Error withdrawing vote!")
+	}
+	return s.confirmedRefresh(command)
+}
+
+// confirmedRefresh recreates the poll after a change of the votes of the user
+// and includes the current choices of the user to confirm the change.
+func (s *Service) confirmedRefresh(command Command) Reply {
+	reply := s.refresh(command)
+	if reply.Kind == ReplyPoll {
+		var err error
+		// The vote was stored, so missing choices only skip the confirmation
+		reply.Choices, err = s.store.GetVoterChoices(command.PollID, command.UserID)
+		if err != nil {
+			s.logger.Println("Error fetching choices of voter for confirmation: ", err)
+		}
+	}
+	return reply
+}
+
+func (s *Service) refresh(command Command) Reply {
+	s.logger.Println("Handle poll update")
+	p, err := s.store.GetPoll(command.PollID)
+	if err != nil {
+		return s.errorReply(err, "Error fetching poll from store for message recreation: ", "Error refreshing poll!")
+	}
+	// Response URLs expire, so polls which are closed by their deadline need the latest one,
+	// as do polls with hidden results to show the results once they are closed
+	if (!p.Deadline.IsZero() || p.HideResults) && p.IsOpen(time.Now()) && command.ResponseURL != "" {
+		err = s.store.SetResponseURL(p.ID, command.ResponseURL)
+		if err != nil {
+			s.logger.Println("Error storing response URL of poll: ", err)
+		}
+	}
+	return s.pollReply(p, "Error calculating current poll count: ", "Error refreshing poll!")
+}
+
+// PollReply creates the reply showing a poll with its current results. For
+// polls whose results are hidden only the number of voters is included.
+func (s *Service) PollReply(p poll.Poll) (Reply, error) {
+	if p.ResultsHidden(time.Now()) {
+		participants, err := s.store.GetParticipantCount(p.ID)
+		return Reply{Kind: ReplyPoll, Poll: p, ResultsHidden: true, Participants: participants}, err
+	}
+	return s.resultsReply(p)
+}
+
+func (s *Service) resultsReply(p poll.Poll) (Reply, error) {
+	if p.Type == poll.PollTypeRankedChoice {
+		runoff, err := s.store.GetRunoffResult(p.ID)
+		return Reply{Kind: ReplyPoll, Poll: p, Runoff: runoff}, err
+	}
+	results, err := s.store.GetResult(p.ID)
+	return Reply{Kind: ReplyPoll, Poll: p, Results: results}, err
+}
+
+func (s *Service) pollReply(p poll.Poll, logMessage, userMessage string) Reply {
+	reply, err := s.PollReply(p)
+	if err != nil {
+		return s.errorReply(err, logMessage, userMessage)
+	}
+	return reply
+}
+
+func (s *Service) showDetails(command Command) Reply {
+	s.logger.Println("Handle poll detail request")
+	p, err := s.store.GetPoll(command.PollID)
+	if err != nil {
+		return s.errorReply(err, "Error fetching poll from store for vote details: ", "Error getting poll details!")
+	}
+	if p.ResultsHidden(time.Now()) && command.UserID != p.CreatorID {
+		return s.errorReply(errors.Errorf("User %s requested hidden vote details of poll %s", command.UserID, p.ID), "Vote details requested for poll with hidden results: ", hiddenResultsMessage)
+	}
+	voteDetails, err := s.store.GetVoteDetails(command.PollID)
+	if err != nil {
+		return s.errorReply(err, "Error calculating current poll vote details: ", "Error getting poll details!")
+	}
+	return Reply{Kind: ReplyVoteDetails, Details: voteDetails}
+}
+
+func (s *Service) closePoll(command Command) Reply {
+	s.logger.Println("Handle close poll request")
+	closedPoll, err := s.store.ClosePoll(command.PollID, command.UserID)
+	if errors.Cause(err) == poll.ErrNotPollCreator {
+		return s.errorReply(err, "Close poll request from other user than creator: ", "Only the creator of the poll can close it!")
+	}
+	if err != nil {
+		return s.errorReply(err, "Error closing poll: ", "Error closing poll!")
+	}
+	return s.pollReply(closedPoll, "Error calculating final poll count: ", "Error refreshing poll!")
+}
+
+func (s *Service) requestRanking(command Command) Reply {
+	s.logger.Println("Handle rank options request")
+	rankedPoll, err := s.store.GetPoll(command.PollID)
+	if err != nil {
+		return s.errorReply(err, "Error fetching poll from store for ranking form: ", "Error opening ranking form!")
+	}
+	if !rankedPoll.IsOpen(time.Now()) {
+		return s.errorReply(poll.ErrPollClosed, "Ranking requested for closed poll: ", "This poll is closed, votes aren't accepted anymore!")
+	}
+	return Reply{Kind: ReplyRankingForm, Poll: rankedPoll}
+}
+
+func (s *Service) showResults(command Command) Reply {
+	s.logger.Println("Handle poll results request")
+	p, err := s.store.GetPoll(command.PollID)
+	if err != nil {
+		return s.errorReply(err, "Error fetching poll from store for results: ", "Error getting poll results!")
+	}
+	if p.ResultsHidden(time.Now()) && command.UserID != p.CreatorID {
+		return s.errorReply(errors.Errorf("User %s requested hidden results of poll %s", command.UserID, p.ID), "Results requested for poll with hidden results: ", hiddenResultsMessage)
+	}
+	// The creator sees the results even if they are hidden from everyone else
+	reply, err := s.resultsReply(p)
+	if err != nil {
+		return s.errorReply(err, "Error calculating current poll count: ", "Error getting poll results!")
+	}
+	return reply
+}
+
+func (s *Service) listPolls(command Command) Reply {
+	s.logger.Println("Handle list polls request")
+	createdPolls, err := s.store.GetPollsByCreator(command.UserID)
+	if err != nil {
+		return s.errorReply(err, "Error fetching polls of creator from store: ", "Error listing your polls!")
+	}
+	return Reply{Kind: ReplyPollList, Polls: createdPolls}
+}
+
+func (s *Service) deletePoll(command Command) Reply {
+	s.logger.Println("Handle delete poll request")
+	deletedPoll, err := s.store.DeletePoll(command.PollID, command.UserID, command.UserIsAdmin)
+	if errors.Cause(err) == poll.ErrNotPollCreator {
+		return s.errorReply(err, "Delete poll request from other user than creator: ", "Only the creator of the poll or a workspace admin can delete it!")
+	}
+	if err != nil {
+		return s.errorReply(err, "Error deleting poll: ", "Error deleting poll!")
+	}
+	return Reply{Kind: ReplyDeletedPoll, Poll: deletedPoll, Text: "The poll was deleted."}
+}
+
+func (s *Service) showMyVote(command Command) Reply {
+	s.logger.Println("Handle show my vote request")
+	p, err := s.store.GetPoll(command.PollID)
+	if err != nil {
+		return s.errorReply(err, "Error fetching poll from store for vote of user: ", "Error getting your vote!")
+	}
+	choices, err := s.store.GetVoterChoices(command.PollID, command.UserID)
+	if err != nil {
+		return s.errorReply(err, "Error fetching choices of voter: ", "Error getting your vote!")
+	}
+	return Reply{Kind: ReplyMyVote, Poll: p, Choices: choices}
+}
+
+func (s *Service) requestEdit(command Command) Reply {
+	s.logger.Println("Handle edit poll request")
+	p, err := s.store.GetPoll(command.PollID)
+	if err != nil {
+		return s.errorReply(err, "Error fetching poll from store for edit form: ", "Error opening form for editing the poll!")
+	}
+	if p.CreatorID != command.UserID {
+		return s.errorReply(errors.Wrapf(poll.ErrNotPollCreator, "User %s requested edit form of poll %s", command.UserID, p.ID), "Edit request from other user than creator: ", "Only the creator of the poll can edit it!")
+	}
+	if !p.IsOpen(time.Now()) {
+		return s.errorReply(poll.ErrPollClosed, "Edit requested for closed poll: ", "This poll is closed, it can't be edited anymore!")
+	}
+	return Reply{Kind: ReplyEditForm, Poll: p}
+}
+
+func (s *Service) editPoll(command Command) Reply {
+	s.logger.Println("Handle poll edit")
+	editedPoll, err := s.store.EditPoll(command.PollID, command.UserID, command.Edit)
+	if validationErr, ok := err.(*poll.ValidationError); ok {
+		return s.errorReply(err, "Invalid poll edit: ", validationErr.Message)
+	}
+	if errors.Cause(err) == poll.ErrNotPollCreator {
+		return s.errorReply(err, "Poll edit from other user than creator: ", "Only the creator of the poll can edit it!")
+	}
+	if errors.Cause(err) == poll.ErrPollClosed {
+		return s.errorReply(err, "Edit of closed poll: ", "This poll is closed, it can't be edited anymore!")
+	}
+	if err != nil {
+		return s.errorReply(err, "Error editing poll: ", "Error editing poll!")
+	}
+	return s.pollReply(editedPoll, "Error calculating poll count after edit: ", "Error refreshing poll!")
+}
+
+func (s *Service) requestWriteIn(command Command) Reply {
+	s.logger.Println("Handle write-in request")
+	p, err := s.store.GetPoll(command.PollID)
+	if err != nil {
+		return s.errorReply(err, "Error fetching poll from store for write-in form: ", "Error opening form for adding an option!")
+	}
+	if !p.AllowWriteIns {
+		return s.errorReply(poll.ErrWriteInsDisabled, "Write-in requested for poll without write-ins: ", "This poll doesn't accept new options!")
+	}
+	if !p.IsOpen(time.Now()) {
+		return s.errorReply(poll.ErrPollClosed, "Write-in requested for closed poll: ", "This poll is closed, options can't be added anymore!")
+	}
+	if len(p.Options) >= poll.MaxOptions {
+		return s.errorReply(poll.ErrTooManyOptions, "Write-in requested for poll with maximum number of options: ", "This poll already has the maximum number of options!")
+	}
+	return Reply{Kind: ReplyWriteInForm, Poll: p}
+}
+
+func (s *Service) addWriteIn(command Command) Reply {
+	s.logger.Println("Handle write-in")
+	updatedPoll, err := s.store.AddWriteIn(command.PollID, command.UserID, command.WriteIn)
+	if validationErr, ok := err.(*poll.ValidationError); ok {
+		return s.errorReply(err, "Invalid write-in: ", validationErr.Message)
+	}
+	if errors.Cause(err) == poll.ErrWriteInsDisabled {
+		return s.errorReply(err, "Write-in for poll without write-ins: ", "This poll doesn't accept new options!")
+	}
+	if errors.Cause(err) == poll.ErrPollClosed {
+		return s.errorReply(err, "Write-in for closed poll: ", "This poll is closed, options can't be added anymore!")
+	}
+	if err != nil {
+		return s.errorReply(err, "Error adding write-in: ", "Error adding option!")
+	}
+	return s.pollReply(updatedPoll, "Error calculating poll count after write-in: ", "Error refreshing poll!")
+}
+
+func (s *Service) disableWriteIns(command Command) Reply {
+	s.logger.Println("Handle disabling write-ins")
+	updatedPoll, err := s.store.DisableWriteIns(command.PollID, command.UserID)
+	if errors.Cause(err) == poll.ErrNotPollCreator {
+		return s.errorReply(err, "Disabling write-ins from other user than creator: ", "Only the creator of the poll can stop new options!")
+	}
+	if err != nil {
+		return s.errorReply(err, "Error disabling write-ins: ", "Error stopping new options!")
+	}
+	return s.pollReply(updatedPoll, "Error calculating poll count after disabling write-ins: ", "Error refreshing poll!")
+}
@@ -0,0 +1,290 @@
+package chat
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/go-test/deep"
+	"markusreschke.name/selfhostedchatpolling/poll"
+	"markusreschke.name/selfhostedchatpolling/poll/memstore"
+)
+
+func TestMain(m *testing.M) {
+	flag.Bool("integration", false, "run integration tests")
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+func newTestService() *Service {
+	return NewService(poll.NewDefaultStore(memstore.NewInMemoryStoreBackend()), log.New(ioutil.Discard, "", 0))
+}
+
+func TestCreatingAndVoting(t *testing.T) {
+	service := newTestService()
+	createCommand := Command{Action: ActionCreatePoll, Platform: "test", UserID: "creator", Poll: poll.Poll{Question: "q", Options: poll.NewOptions("a1", "a2")}}
+	reply := service.Execute(createCommand)
+	if reply.Kind != ReplyPoll || reply.Poll.ID == "" || reply.Poll.CreatorID != "creator" || reply.Poll.Platform != "test" {
+		t.Fatalf("Unexpected reply for new poll: %v", reply)
+	}
+	pollID := reply.Poll.ID
+	reply = service.Execute(Command{Action: ActionVote, UserID: "voter", PollID: pollID, Option: 1})
+	if diff := deep.Equal(map[int]uint64{1: 1}, reply.Results); reply.Kind != ReplyPoll || diff != nil {
+		t.Fatalf("Unexpected reply for vote: %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionShowDetails, UserID: "voter", PollID: pollID})
+	expectedDetails := map[string][]string{"a1": {}, "a2": {"voter"}}
+	if diff := deep.Equal(expectedDetails, reply.Details); reply.Kind != ReplyVoteDetails || diff != nil {
+		t.Fatalf("Unexpected reply for vote details: %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionVote, UserID: "voter", PollID: pollID, Option: 2})
+	if reply.Kind != ReplyError || reply.Text != "Error submitting vote!" {
+		t.Fatalf("Expected error reply for invalid vote but got %v", reply)
+	}
+}
+
+func TestShowingMyVote(t *testing.T) {
+	service := newTestService()
+	reply := service.Execute(Command{Action: ActionCreatePoll, UserID: "creator", Poll: poll.Poll{Question: "q", Options: poll.NewOptions("a1", "a2"), Anonymous: true}})
+	pollID := reply.Poll.ID
+	reply = service.Execute(Command{Action: ActionShowMyVote, UserID: "voter", PollID: pollID})
+	if reply.Kind != ReplyMyVote || reply.Choices == nil || len(reply.Choices) != 0 || reply.Poll.ID != pollID {
+		t.Fatalf("Expected no choices before voting but got %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionVote, UserID: "voter", PollID: pollID, Option: 1})
+	if diff := deep.Equal([]int{1}, reply.Choices); reply.Kind != ReplyPoll || diff != nil {
+		t.Fatalf("Expected choices of voter in reply to vote but got %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionShowMyVote, UserID: "voter", PollID: pollID})
+	if diff := deep.Equal([]int{1}, reply.Choices); reply.Kind != ReplyMyVote || diff != nil {
+		t.Fatalf("Unexpected reply for vote of user: %v", reply)
+	}
+}
+
+func TestRetractingVote(t *testing.T) {
+	service := newTestService()
+	reply := service.Execute(Command{Action: ActionCreatePoll, UserID: "creator", Poll: poll.Poll{Question: "q", Options: poll.NewOptions("a1", "a2")}})
+	pollID := reply.Poll.ID
+	service.Execute(Command{Action: ActionVote, UserID: "voter", PollID: pollID, Option: 1})
+	service.Execute(Command{Action: ActionVote, UserID: "voter2", PollID: pollID, Option: 1})
+	reply = service.Execute(Command{Action: ActionRetractVote, UserID: "voter", PollID: pollID})
+	if diff := deep.Equal(map[int]uint64{1: 1}, reply.Results); reply.Kind != ReplyPoll || diff != nil || reply.Choices == nil || len(reply.Choices) != 0 {
+		t.Fatalf("Expected updated results without choices after retraction but got %v", reply)
+	}
+	// Voting for the selected option again retracts the vote as well
+	reply = service.Execute(Command{Action: ActionVote, UserID: "voter2", PollID: pollID, Option: 1})
+	if diff := deep.Equal(map[int]uint64{}, reply.Results); reply.Kind != ReplyPoll || diff != nil || len(reply.Choices) != 0 {
+		t.Fatalf("Expected no votes after voting for the selected option again but got %v", reply)
+	}
+	service.Execute(Command{Action: ActionClosePoll, UserID: "creator", PollID: pollID})
+	reply = service.Execute(Command{Action: ActionRetractVote, UserID: "voter", PollID: pollID})
+	if reply.Kind != ReplyError || reply.Text != "This poll is closed, votes can't be withdrawn anymore!" {
+		t.Fatalf("Expected error reply for retraction on closed poll but got %v", reply)
+	}
+}
+
+func TestEditingPoll(t *testing.T) {
+	service := newTestService()
+	reply := service.Execute(Command{Action: ActionCreatePoll, UserID: "creator", Poll: poll.Poll{Question: "q", Options: poll.NewOptions("a1", "a2", "a3")}})
+	pollID := reply.Poll.ID
+	service.Execute(Command{Action: ActionVote, UserID: "voter", PollID: pollID, Option: 2})
+	reply = service.Execute(Command{Action: ActionRequestEdit, UserID: "voter", PollID: pollID})
+	if reply.Kind != ReplyError || reply.Text != "Only the creator of the poll can edit it!" {
+		t.Fatalf("Expected error reply for editing poll of other user but got %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionRequestEdit, UserID: "creator", PollID: pollID})
+	if reply.Kind != ReplyEditForm || reply.Poll.ID != pollID {
+		t.Fatalf("Unexpected reply for edit request: %v", reply)
+	}
+	edit := poll.PollEdit{Question: "q?", NewOptions: []string{"A2"}}
+	reply = service.Execute(Command{Action: ActionEditPoll, UserID: "creator", PollID: pollID, Edit: edit})
+	if reply.Kind != ReplyError || reply.Text != `The option "A2" is given more than once!` {
+		t.Fatalf("Expected error reply for invalid edit but got %v", reply)
+	}
+	edit = poll.PollEdit{Question: "q?", NewOptions: []string{"a4"}, RemovedOptions: []int{0}}
+	reply = service.Execute(Command{Action: ActionEditPoll, UserID: "creator", PollID: pollID, Edit: edit})
+	if diff := deep.Equal(map[int]uint64{2: 1}, reply.Results); reply.Kind != ReplyPoll || reply.Poll.Question != "q?" || len(reply.Poll.Options) != 3 || diff != nil {
+		t.Fatalf("Unexpected reply for edit: %v", reply)
+	}
+	// Buttons of old messages hold the position of the option instead of its ID
+	reply = service.Execute(Command{Action: ActionVote, UserID: "voter2", PollID: pollID, Option: 0, OptionIsPosition: true})
+	if diff := deep.Equal(map[int]uint64{1: 1, 2: 1}, reply.Results); diff != nil {
+		t.Fatalf("Expected vote by position for option 1 but got %v", reply.Results)
+	}
+}
+
+func TestCreatingInvalidPoll(t *testing.T) {
+	service := newTestService()
+	reply := service.Execute(Command{Action: ActionCreatePoll, UserID: "creator", Poll: poll.Poll{Question: "q", Options: poll.NewOptions("Pizza", "pizza")}})
+	if reply.Kind != ReplyError || reply.Text != `The option "pizza" is given more than once!` {
+		t.Fatalf("Expected error reply for invalid poll but got %v", reply)
+	}
+}
+
+func TestClosingPoll(t *testing.T) {
+	service := newTestService()
+	reply := service.Execute(Command{Action: ActionCreatePoll, UserID: "creator", Poll: poll.Poll{Question: "q", Options: poll.NewOptions("a1", "a2")}})
+	pollID := reply.Poll.ID
+	reply = service.Execute(Command{Action: ActionClosePoll, UserID: "voter", PollID: pollID})
+	if reply.Kind != ReplyError || reply.Text != "Only the creator of the poll can close it!" {
+		t.Fatalf("Expected error reply for closing poll of other user but got %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionClosePoll, UserID: "creator", PollID: pollID})
+	if reply.Kind != ReplyPoll || !reply.Poll.Closed {
+		t.Fatalf("Unexpected reply for closing poll: %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionVote, UserID: "voter", PollID: pollID, Option: 0})
+	if reply.Kind != ReplyError || reply.Text != "This poll is closed, votes aren't accepted anymore!" {
+		t.Fatalf("Expected error reply for vote on closed poll but got %v", reply)
+	}
+}
+
+func TestHiddenResults(t *testing.T) {
+	service := newTestService()
+	reply := service.Execute(Command{Action: ActionCreatePoll, UserID: "creator", Poll: poll.Poll{Question: "q", Options: poll.NewOptions("a1", "a2"), HideResults: true}})
+	pollID := reply.Poll.ID
+	service.Execute(Command{Action: ActionVote, UserID: "voter", PollID: pollID, Option: 1})
+	reply = service.Execute(Command{Action: ActionVote, UserID: "voter2", PollID: pollID, Option: 1})
+	if reply.Kind != ReplyPoll || !reply.ResultsHidden || reply.Participants != 2 || reply.Results != nil {
+		t.Fatalf("Expected only the number of voters for poll with hidden results but got %v", reply)
+	}
+	for _, action := range []Action{ActionShowResults, ActionShowDetails} {
+		reply = service.Execute(Command{Action: action, UserID: "voter", PollID: pollID})
+		if reply.Kind != ReplyError || reply.Text != hiddenResultsMessage {
+			t.Fatalf("Expected error reply for %s of poll with hidden results but got %v", action, reply)
+		}
+	}
+	reply = service.Execute(Command{Action: ActionShowResults, UserID: "creator", PollID: pollID})
+	if diff := deep.Equal(map[int]uint64{1: 2}, reply.Results); reply.Kind != ReplyPoll || reply.ResultsHidden || diff != nil {
+		t.Fatalf("Expected creator to see the hidden results but got %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionClosePoll, UserID: "creator", PollID: pollID})
+	if diff := deep.Equal(map[int]uint64{1: 2}, reply.Results); reply.Kind != ReplyPoll || reply.ResultsHidden || diff != nil {
+		t.Fatalf("Expected results of closed poll but got %v", reply)
+	}
+}
+
+func TestWriteIns(t *testing.T) {
+	service := newTestService()
+	reply := service.Execute(Command{Action: ActionCreatePoll, UserID: "creator", Poll: poll.Poll{Question: "q", Options: poll.NewOptions("a1", "a2"), AllowWriteIns: true}})
+	pollID := reply.Poll.ID
+	reply = service.Execute(Command{Action: ActionRequestWriteIn, UserID: "voter", PollID: pollID})
+	if reply.Kind != ReplyWriteInForm || reply.Poll.ID != pollID {
+		t.Fatalf("Expected write-in form but got %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionAddWriteIn, UserID: "voter", PollID: pollID, WriteIn: "A1"})
+	if reply.Kind != ReplyError || reply.Text != `The option "A1" is given more than once!` {
+		t.Fatalf("Expected error reply for duplicate write-in but got %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionAddWriteIn, UserID: "voter", PollID: pollID, WriteIn: "a3"})
+	if diff := deep.Equal(poll.Option{ID: 2, Label: "a3", ProposedBy: "voter"}, reply.Poll.Options[2]); reply.Kind != ReplyPoll || diff != nil {
+		t.Fatalf("Expected poll with write-in but got %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionVote, UserID: "voter", PollID: pollID, Option: 2})
+	if diff := deep.Equal(map[int]uint64{2: 1}, reply.Results); diff != nil {
+		t.Fatalf("Expected vote for write-in but got %v", reply.Results)
+	}
+	reply = service.Execute(Command{Action: ActionDisableWriteIns, UserID: "voter", PollID: pollID})
+	if reply.Kind != ReplyError || reply.Text != "Only the creator of the poll can stop new options!" {
+		t.Fatalf("Expected error reply for disabling write-ins of other user but got %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionDisableWriteIns, UserID: "creator", PollID: pollID})
+	if reply.Kind != ReplyPoll || reply.Poll.AllowWriteIns {
+		t.Fatalf("Unexpected reply for disabling write-ins: %v", reply)
+	}
+	for _, action := range []Action{ActionRequestWriteIn, ActionAddWriteIn} {
+		reply = service.Execute(Command{Action: action, UserID: "voter", PollID: pollID, WriteIn: "a4"})
+		if reply.Kind != ReplyError || reply.Text != "This poll doesn't accept new options!" {
+			t.Fatalf("Expected error reply for %s after disabling write-ins but got %v", action, reply)
+		}
+	}
+}
+
+func TestListingAndDeletingPolls(t *testing.T) {
+	service := newTestService()
+	reply := service.Execute(Command{Action: ActionCreatePoll, UserID: "creator", Poll: poll.Poll{Question: "q", Options: poll.NewOptions("a1", "a2")}})
+	pollID := reply.Poll.ID
+	if reply.Poll.CreatedAt.IsZero() {
+		t.Fatalf("Expected creation time to be set for new poll: %v", reply.Poll)
+	}
+	service.Execute(Command{Action: ActionVote, UserID: "voter", PollID: pollID, Option: 1})
+	reply = service.Execute(Command{Action: ActionShowResults, UserID: "voter", PollID: pollID})
+	if diff := deep.Equal(map[int]uint64{1: 1}, reply.Results); reply.Kind != ReplyPoll || diff != nil {
+		t.Fatalf("Unexpected reply for poll results: %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionListPolls, UserID: "creator"})
+	if reply.Kind != ReplyPollList || len(reply.Polls) != 1 || reply.Polls[0].ID != pollID {
+		t.Fatalf("Unexpected reply for listing polls: %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionDeletePoll, UserID: "voter", PollID: pollID})
+	if reply.Kind != ReplyError || reply.Text != "Only the creator of the poll or a workspace admin can delete it!" {
+		t.Fatalf("Expected error reply for deleting poll of other user but got %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionDeletePoll, UserID: "creator", PollID: pollID})
+	if reply.Kind != ReplyDeletedPoll || reply.Poll.ID != pollID {
+		t.Fatalf("Unexpected reply for deleting poll: %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionVote, UserID: "voter", PollID: pollID, Option: 0})
+	if reply.Kind != ReplyError || reply.Text != "This poll doesn't exist anymore, it was deleted." {
+		t.Fatalf("Expected not found error for voting in deleted poll but got %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionListPolls, UserID: "creator"})
+	if reply.Kind != ReplyPollList || len(reply.Polls) != 0 {
+		t.Fatalf("Expected no polls after deleting the poll but got %v", reply)
+	}
+	reply = service.Execute(Command{Action: ActionCreatePoll, UserID: "creator", Poll: poll.Poll{Question: "q", Options: poll.NewOptions("a1", "a2")}})
+	reply = service.Execute(Command{Action: ActionDeletePoll, UserID: "admin", UserIsAdmin: true, PollID: reply.Poll.ID})
+	if reply.Kind != ReplyDeletedPoll {
+		t.Fatalf("Expected admin to delete poll of other user but got %v", reply)
+	}
+}
+
+type fakePlatform struct {
+	name           string
+	updatedReplies []Reply
+}
+
+func (p *fakePlatform) Name() string {
+	return p.name
+}
+
+func (p *fakePlatform) ParseCommand(writer http.ResponseWriter, request *http.Request) (Command, bool) {
+	return Command{}, false
+}
+
+func (p *fakePlatform) WriteReply(writer http.ResponseWriter, command Command, reply Reply) {
+}
+
+func (p *fakePlatform) UpdatePollMessage(reply Reply) error {
+	p.updatedReplies = append(p.updatedReplies, reply)
+	return nil
+}
+
+func TestClosedPollNotifier(t *testing.T) {
+	service := newTestService()
+	defaultPlatform := &fakePlatform{name: "default"}
+	otherPlatform := &fakePlatform{name: "other"}
+	notify := NewClosedPollNotifier(service, log.New(ioutil.Discard, "", 0), "default", defaultPlatform, otherPlatform)
+	notify(poll.Poll{ID: "1", Question: "q", Options: poll.NewOptions("a1"), Closed: true})
+	notify(poll.Poll{ID: "2", Question: "q", Options: poll.NewOptions("a1"), Closed: true, Platform: "other"})
+	notify(poll.Poll{ID: "3", Question: "q", Options: poll.NewOptions("a1"), Closed: true, Platform: "unknown"})
+	if len(defaultPlatform.updatedReplies) != 1 || defaultPlatform.updatedReplies[0].Poll.ID != "1" {
+		t.Errorf("Expected update of poll 1 on default platform but got %v", defaultPlatform.updatedReplies)
+	}
+	if len(otherPlatform.updatedReplies) != 1 || otherPlatform.updatedReplies[0].Poll.ID != "2" {
+		t.Errorf("Expected update of poll 2 on other platform but got %v", otherPlatform.updatedReplies)
+	}
+}
+
+func TestDeletedPollNotifier(t *testing.T) {
+	platform := &fakePlatform{name: "default"}
+	notify := NewDeletedPollNotifier(log.New(ioutil.Discard, "", 0), "default", platform)
+	notify(poll.Poll{ID: "1", Question: "q", ResponseURL: "https://example.com/response"})
+	notify(poll.Poll{ID: "2", Question: "q"})
+	if len(platform.updatedReplies) != 1 || platform.updatedReplies[0].Kind != ReplyDeletedPoll || platform.updatedReplies[0].Poll.ID != "1" {
+		t.Errorf("Expected deleted poll 1 to be replaced but got %v", platform.updatedReplies)
+	}
+}
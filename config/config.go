@@ -4,11 +4,15 @@ import (
 	"errors"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
 	BackendCloudant = "cloudant"
 	BackendInMemory = "inmemory"
+	BackendSQLite   = "sqlite"
+	BackendPostgres = "postgres"
 )
 
 var (
@@ -17,22 +21,153 @@ var (
 
 type AppConfig struct {
 	SlackVerificationToken string
+	SlackSigningSecret     string
+	// SlackSignatureMaxSkew is the maximum age of signed requests.
+	SlackSignatureMaxSkew time.Duration
+	// SlackAcceptLegacyToken allows unsigned requests with the verification
+	// token while migrating to signed requests.
+	SlackAcceptLegacyToken bool
 	SlackOAuthToken        string
-	Port                   int
-	DbName                 string
-	LogTraffic             bool
-	Backend                string
+	// SlackUseBlockKit selects Block Kit instead of attachments for messages.
+	SlackUseBlockKit      bool
+	Port                  int
+	DbName                string
+	LogTraffic            bool
+	Backend               string
+	SQLitePath            string
+	PostgresDSN           string
+	DeadlineCheckInterval time.Duration
+	// RetentionPeriod is the age after which polls are deleted, zero keeps
+	// polls forever.
+	RetentionPeriod time.Duration
+	// MattermostCommandTokens holds the tokens of all Mattermost slash
+	// commands pointing to the integration.
+	MattermostCommandTokens []string
+	MattermostActionURL     string
+	MattermostActionToken   string
+	MattermostServerURL     string
+	MattermostBotToken      string
+	// TeamsWebhookSecret is the base64 encoded security token of the Teams
+	// outgoing webhook.
+	TeamsWebhookSecret string
+	// DiscordPublicKey is the hex encoded public key of the Discord
+	// application.
+	DiscordPublicKey  string
+	MatrixHomeserver  string
+	MatrixAccessToken string
+	MatrixUserID      string
+	// MatrixUsePollEvents posts polls as MSC3381 poll events instead of
+	// messages which are voted on with reactions.
+	MatrixUsePollEvents bool
 }
 
-func ReadConfigFromEnv() (AppConfig, error) {
-	var config AppConfig
+func (c AppConfig) SlackEnabled() bool {
+	return c.SlackSigningSecret != "" || c.SlackVerificationToken != ""
+}
+
+func (c AppConfig) MattermostEnabled() bool {
+	return len(c.MattermostCommandTokens) > 0
+}
+
+func (c AppConfig) TeamsEnabled() bool {
+	return c.TeamsWebhookSecret != ""
+}
+
+func (c AppConfig) DiscordEnabled() bool {
+	return c.DiscordPublicKey != ""
+}
+
+func (c AppConfig) MatrixEnabled() bool {
+	return c.MatrixHomeserver != ""
+}
+
+func readSlackConfig(config *AppConfig) error {
+	var err error
+	config.SlackSigningSecret = os.Getenv("SLACK_SIGNING_SECRET")
+	config.SlackAcceptLegacyToken, err = strconv.ParseBool(os.Getenv("SHCP_SLACK_ACCEPT_LEGACY_TOKEN"))
+	if err != nil {
+		config.SlackAcceptLegacyToken = false
+	}
 	config.SlackVerificationToken = os.Getenv("SLACK_TOKEN")
-	if config.SlackVerificationToken == "" {
-		return config, errors.New("SLACK_TOKEN environment variable is not set!")
+	if !config.SlackEnabled() {
+		return nil
+	}
+	if config.SlackVerificationToken == "" && config.SlackAcceptLegacyToken {
+		return errors.New("SLACK_TOKEN environment variable is not set!")
+	}
+	config.SlackSignatureMaxSkew, err = time.ParseDuration(os.Getenv("SHCP_SLACK_SIGNATURE_MAX_SKEW"))
+	if err != nil || config.SlackSignatureMaxSkew <= 0 {
+		config.SlackSignatureMaxSkew = 5 * time.Minute
 	}
 	config.SlackOAuthToken = os.Getenv("SLACK_OAUTH_TOKEN")
 	if config.SlackOAuthToken == "" {
-		return config, errors.New("SLACK_OAUTH_TOKEN environment variable is not set!")
+		return errors.New("SLACK_OAUTH_TOKEN environment variable is not set!")
+	}
+	return nil
+}
+
+func readMattermostConfig(config *AppConfig) error {
+	for _, token := range strings.Split(os.Getenv("SHCP_MATTERMOST_TOKENS"), ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			config.MattermostCommandTokens = append(config.MattermostCommandTokens, token)
+		}
+	}
+	if !config.MattermostEnabled() {
+		return nil
+	}
+	config.MattermostActionURL = os.Getenv("SHCP_MATTERMOST_ACTION_URL")
+	if config.MattermostActionURL == "" {
+		return errors.New("SHCP_MATTERMOST_ACTION_URL environment variable is not set!")
+	}
+	config.MattermostActionToken = os.Getenv("SHCP_MATTERMOST_ACTION_TOKEN")
+	if config.MattermostActionToken == "" {
+		return errors.New("SHCP_MATTERMOST_ACTION_TOKEN environment variable is not set!")
+	}
+	config.MattermostServerURL = strings.TrimSuffix(os.Getenv("SHCP_MATTERMOST_URL"), "/")
+	config.MattermostBotToken = os.Getenv("SHCP_MATTERMOST_BOT_TOKEN")
+	return nil
+}
+
+func readMatrixConfig(config *AppConfig) error {
+	config.MatrixHomeserver = strings.TrimSuffix(os.Getenv("SHCP_MATRIX_HOMESERVER"), "/")
+	if !config.MatrixEnabled() {
+		return nil
+	}
+	config.MatrixAccessToken = os.Getenv("SHCP_MATRIX_ACCESS_TOKEN")
+	if config.MatrixAccessToken == "" {
+		return errors.New("SHCP_MATRIX_ACCESS_TOKEN environment variable is not set!")
+	}
+	config.MatrixUserID = os.Getenv("SHCP_MATRIX_USER_ID")
+	if config.MatrixUserID == "" {
+		return errors.New("SHCP_MATRIX_USER_ID environment variable is not set!")
+	}
+	var err error
+	config.MatrixUsePollEvents, err = strconv.ParseBool(os.Getenv("SHCP_MATRIX_POLL_EVENTS"))
+	if err != nil {
+		config.MatrixUsePollEvents = false
+	}
+	return nil
+}
+
+func ReadConfigFromEnv() (AppConfig, error) {
+	var config AppConfig
+	var err error
+	err = readSlackConfig(&config)
+	if err != nil {
+		return config, err
+	}
+	err = readMattermostConfig(&config)
+	if err != nil {
+		return config, err
+	}
+	config.TeamsWebhookSecret = os.Getenv("SHCP_TEAMS_WEBHOOK_SECRET")
+	config.DiscordPublicKey = os.Getenv("SHCP_DISCORD_PUBLIC_KEY")
+	err = readMatrixConfig(&config)
+	if err != nil {
+		return config, err
+	}
+	if !config.SlackEnabled() && !config.MattermostEnabled() && !config.TeamsEnabled() && !config.DiscordEnabled() && !config.MatrixEnabled() {
+		return config, errors.New("No chat platform is configured, set the Slack, Mattermost, Teams, Discord or Matrix environment variables!")
 	}
 	config.Backend = os.Getenv("SHCP_BACKEND")
 	if config.Backend == "" {
@@ -47,6 +182,29 @@ func ReadConfigFromEnv() (AppConfig, error) {
 	}
 	config.Port = port
 	config.DbName = os.Getenv("CLOUDANT_DB")
+	config.SQLitePath = os.Getenv("SHCP_SQLITE_PATH")
+	if config.SQLitePath == "" {
+		config.SQLitePath = "shcp.db"
+	}
+	config.PostgresDSN = os.Getenv("SHCP_POSTGRES_DSN")
+	if config.Backend == BackendPostgres && config.PostgresDSN == "" {
+		return config, errors.New("SHCP_POSTGRES_DSN environment variable is not set!")
+	}
+	config.DeadlineCheckInterval, err = time.ParseDuration(os.Getenv("SHCP_DEADLINE_CHECK_INTERVAL"))
+	if err != nil || config.DeadlineCheckInterval <= 0 {
+		config.DeadlineCheckInterval = time.Minute
+	}
+	if retentionDays := os.Getenv("SHCP_RETENTION_DAYS"); retentionDays != "" {
+		days, err := strconv.Atoi(retentionDays)
+		if err != nil || days < 0 {
+			return config, errors.New("SHCP_RETENTION_DAYS has to be a number of days!")
+		}
+		config.RetentionPeriod = time.Duration(days) * 24 * time.Hour
+	}
+	config.SlackUseBlockKit, err = strconv.ParseBool(os.Getenv("SHCP_SLACK_BLOCK_KIT"))
+	if err != nil {
+		config.SlackUseBlockKit = false
+	}
 	config.LogTraffic, err = strconv.ParseBool(os.Getenv("SHCP_LOG_TRAFFIC"))
 	if err != nil {
 		config.LogTraffic = false
@@ -0,0 +1,195 @@
+package discord
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/pkg/errors"
+	"markusreschke.name/selfhostedchatpolling/chat"
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+const testPublicKey = "0a39e9f1a8dad21f43a62c13b1229ff43bccd62aba57dc96c9553a6aba1333a4"
+
+func TestMain(m *testing.M) {
+	flag.Bool("integration", false, "run integration tests")
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+type recordedRequest struct {
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+func readRecordedRequest(t *testing.T, fileName string) *http.Request {
+	dat, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal("Error reading sample file: ", err)
+	}
+	var recorded recordedRequest
+	err = json.Unmarshal(dat, &recorded)
+	if err != nil {
+		t.Fatal("Error parsing sample file: ", err)
+	}
+	request := httptest.NewRequest(http.MethodPost, "/discord/interactions", bytes.NewBufferString(recorded.Body))
+	for name, value := range recorded.Headers {
+		request.Header.Set(name, value)
+	}
+	return request
+}
+
+func newTestPlatform(t *testing.T) *Platform {
+	publicKey, err := ParsePublicKey(testPublicKey)
+	if err != nil {
+		t.Fatal("Error parsing public key: ", err)
+	}
+	return NewPlatform(PlatformConfig{PublicKey: publicKey}, log.New(ioutil.Discard, "", 0))
+}
+
+func TestVerifySignature(t *testing.T) {
+	publicKey := newTestPlatform(t).config.PublicKey
+	testCases := []struct {
+		name          string
+		fixture       string
+		tamper        func(header http.Header, body []byte) []byte
+		expectedError error
+	}{
+		{"SignedSlashCommand", "exampleSlashCommandInteraction.json", nil, nil},
+		{"SignedButton", "exampleButtonInteraction.json", nil, nil},
+		{"TamperedBody", "exampleButtonInteraction.json", func(header http.Header, body []byte) []byte {
+			return bytes.Replace(body, []byte("|1\""), []byte("|0\""), 1)
+		}, ErrInvalidSignature},
+		{"TamperedTimestamp", "exampleButtonInteraction.json", func(header http.Header, body []byte) []byte {
+			header.Set(TimestampHeader, "1531420619")
+			return body
+		}, ErrInvalidSignature},
+		{"MissingSignature", "exampleButtonInteraction.json", func(header http.Header, body []byte) []byte {
+			header.Del(SignatureHeader)
+			return body
+		}, ErrMissingSignature},
+		{"MalformedSignature", "exampleButtonInteraction.json", func(header http.Header, body []byte) []byte {
+			header.Set(SignatureHeader, "abc")
+			return body
+		}, ErrInvalidSignature},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := readRecordedRequest(t, testCase.fixture)
+			body, _ := ioutil.ReadAll(request.Body)
+			if testCase.tamper != nil {
+				body = testCase.tamper(request.Header, body)
+			}
+			err := VerifySignature(request.Header, body, publicKey)
+			if errors.Cause(err) != testCase.expectedError {
+				t.Errorf("Expected error %v but got %v", testCase.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestPing(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	_, ok := newTestPlatform(t).ParseCommand(recorder, readRecordedRequest(t, "examplePingInteraction.json"))
+	if ok || recorder.Code != http.StatusOK || recorder.Body.String() != `{"type":1}` {
+		t.Errorf("Expected pong but got %v, status %d and body %s", ok, recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestParsingSlashCommand(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	command, ok := newTestPlatform(t).ParseCommand(recorder, readRecordedRequest(t, "exampleSlashCommandInteraction.json"))
+	if !ok {
+		t.Fatalf("Expected valid command but got status %d", recorder.Code)
+	}
+	expectedPoll := poll.Poll{Question: "Where do we go for lunch?", Options: poll.NewOptions("Pizza", "Thai food", "Burgers")}
+	if diff := deep.Equal(expectedPoll, command.Poll); command.Action != chat.ActionCreatePoll || command.UserID != "53908232506183680" || diff != nil {
+		t.Errorf("Unexpected command for slash command: %v", command)
+	}
+}
+
+func TestParsingButtonInteraction(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	command, ok := newTestPlatform(t).ParseCommand(recorder, readRecordedRequest(t, "exampleButtonInteraction.json"))
+	if !ok {
+		t.Fatalf("Expected valid command but got status %d", recorder.Code)
+	}
+	if command.Action != chat.ActionVote || command.PollID != "6b57e603-2366-4116-b51d-011837677e33" || command.Option != 1 || !command.OptionIsPosition || command.UserID != "80351110224678912" {
+		t.Errorf("Unexpected command for button interaction: %v", command)
+	}
+}
+
+func TestRejectingUnsignedInteraction(t *testing.T) {
+	request := readRecordedRequest(t, "exampleButtonInteraction.json")
+	request.Header.Del(SignatureHeader)
+	recorder := httptest.NewRecorder()
+	_, ok := newTestPlatform(t).ParseCommand(recorder, request)
+	if ok || recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected unsigned interaction to be rejected but got %v and status %d", ok, recorder.Code)
+	}
+}
+
+func TestPollMessage(t *testing.T) {
+	dat, err := ioutil.ReadFile("exampleDiscordPollMessage.json")
+	if err != nil {
+		t.Fatal("Error reading sample file: ", err)
+	}
+	var expectedMessage MessageData
+	err = json.Unmarshal(dat, &expectedMessage)
+	if err != nil {
+		t.Fatal("Error parsing sample file: ", err)
+	}
+	testPoll := poll.Poll{ID: "6b57e603-2366-4116-b51d-011837677e33", Question: "Test Question", CreatorID: "foobar",
+		Options: poll.NewOptions("Answer 1", "Answer 2", "Answer 3", "Answer 4", "Answer 5", "Answer 6")}
+	actualMessage := NewPollMessage(testPoll, map[int]uint64{0: 3, 5: 1})
+	if diff := deep.Equal(expectedMessage, actualMessage); diff != nil {
+		t.Error("Created message is not as expected: ", diff)
+	}
+}
+
+func TestButtonRows(t *testing.T) {
+	var options []string
+	for i := 0; i < MaxOptions; i++ {
+		options = append(options, "Option")
+	}
+	msg := NewPollMessage(poll.Poll{ID: "1", Question: "q", Options: poll.NewOptions(options...)}, nil)
+	if len(msg.Components) > MaxRowsPerMessage {
+		t.Errorf("Expected at most %d rows but got %d", MaxRowsPerMessage, len(msg.Components))
+	}
+	for _, row := range msg.Components {
+		if len(row.Components) > MaxButtonsPerRow {
+			t.Errorf("Expected at most %d buttons per row but got %d", MaxButtonsPerRow, len(row.Components))
+		}
+	}
+}
+
+func TestRejectingDeadlines(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal("Error generating key: ", err)
+	}
+	platform := NewPlatform(PlatformConfig{PublicKey: publicKey}, log.New(ioutil.Discard, "", 0))
+	request := readRecordedRequest(t, "exampleSlashCommandInteraction.json")
+	body, _ := ioutil.ReadAll(request.Body)
+	body = bytes.Replace(body, []byte("Burgers"), []byte("Burgers --closes 2h"), 1)
+	timestamp := request.Header.Get(TimestampHeader)
+	request = httptest.NewRequest(http.MethodPost, "/discord/interactions", bytes.NewReader(body))
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(SignatureHeader, hex.EncodeToString(ed25519.Sign(privateKey, append([]byte(timestamp), body...))))
+	recorder := httptest.NewRecorder()
+	_, ok := platform.ParseCommand(recorder, request)
+	if ok || !strings.Contains(recorder.Body.String(), "Deadlines aren't supported on Discord") {
+		t.Errorf("Expected poll with deadline to be rejected but got %v and %q", ok, recorder.Body.String())
+	}
+}
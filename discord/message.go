@@ -0,0 +1,226 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+const (
+	InteractionTypePing             = 1
+	InteractionTypeApplicationCmd   = 2
+	InteractionTypeMessageComponent = 3
+
+	ResponseTypePong                     = 1
+	ResponseTypeChannelMessageWithSource = 4
+	ResponseTypeUpdateMessage            = 7
+
+	ComponentTypeActionRow = 1
+	ComponentTypeButton    = 2
+
+	ButtonStylePrimary   = 1
+	ButtonStyleSecondary = 2
+	ButtonStyleDanger    = 4
+
+	// MessageFlagEphemeral shows a message only to the user of an interaction.
+	MessageFlagEphemeral = 64
+
+	MaxButtonsPerRow    = 5
+	MaxRowsPerMessage   = 5
+	maxButtonLabelRunes = 80
+
+	// CustomIDSeparator separates the action, poll ID and option in the
+	// custom IDs of buttons.
+	CustomIDSeparator = "|"
+	// ActionVoteForOption buttons hold the ID of the option. ActionVote
+	// buttons of messages sent before options had IDs hold its position.
+	ActionVoteForOption = "vote_option"
+	ActionVote          = "vote"
+	ActionShowDetails   = "details"
+	ActionRefresh       = "refresh"
+	ActionClosePoll     = "close"
+)
+
+// MaxOptions is the maximum number of options of a poll, the last row of
+// buttons is used for the poll controls.
+const MaxOptions = MaxButtonsPerRow * (MaxRowsPerMessage - 1)
+
+type Interaction struct {
+	ID     string           `json:"id"`
+	Type   int              `json:"type"`
+	Data   *InteractionData `json:"data,omitempty"`
+	Member *Member          `json:"member,omitempty"`
+	// User is only set for interactions in direct messages, Member otherwise.
+	User  *User  `json:"user,omitempty"`
+	Token string `json:"token"`
+}
+
+// InteractionData holds the name and options of a slash command or the custom
+// ID of a clicked button.
+type InteractionData struct {
+	Name     string              `json:"name,omitempty"`
+	Options  []ApplicationOption `json:"options,omitempty"`
+	CustomID string              `json:"custom_id,omitempty"`
+}
+
+type ApplicationOption struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+type Member struct {
+	User User `json:"user"`
+}
+
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+func (i Interaction) UserID() string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+type InteractionResponse struct {
+	Type int          `json:"type"`
+	Data *MessageData `json:"data,omitempty"`
+}
+
+type MessageData struct {
+	Content    string      `json:"content"`
+	Components []Component `json:"components"`
+	Flags      int         `json:"flags,omitempty"`
+}
+
+// Component is either an action row containing buttons or a button.
+type Component struct {
+	Type       int         `json:"type"`
+	Components []Component `json:"components,omitempty"`
+	Style      int         `json:"style,omitempty"`
+	Label      string      `json:"label,omitempty"`
+	CustomID   string      `json:"custom_id,omitempty"`
+}
+
+func (r *InteractionResponse) ToJSON() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func newButton(label string, style int, action, pollID string, option int) Component {
+	runes := []rune(label)
+	if len(runes) > maxButtonLabelRunes {
+		label = string(runes[:maxButtonLabelRunes-1]) + "…"
+	}
+	customID := strings.Join([]string{action, pollID, strconv.Itoa(option)}, CustomIDSeparator)
+	return Component{Type: ComponentTypeButton, Style: style, Label: label, CustomID: customID}
+}
+
+// newActionRows puts the buttons into action rows of at most
+// MaxButtonsPerRow buttons.
+func newActionRows(buttons []Component) []Component {
+	rows := []Component{}
+	for len(buttons) > 0 {
+		rowLength := MaxButtonsPerRow
+		if len(buttons) < rowLength {
+			rowLength = len(buttons)
+		}
+		rows = append(rows, Component{Type: ComponentTypeActionRow, Components: buttons[:rowLength]})
+		buttons = buttons[rowLength:]
+	}
+	return rows
+}
+
+// ParseCustomID splits the custom ID of a button into action, poll ID and
+// option.
+func ParseCustomID(customID string) (action, pollID string, option int, ok bool) {
+	parts := strings.Split(customID, CustomIDSeparator)
+	if len(parts) != 3 {
+		return "", "", 0, false
+	}
+	option, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return "", "", 0, false
+	}
+	return parts[0], parts[1], option, true
+}
+
+func NewPollMessage(p poll.Poll, results map[int]uint64) MessageData {
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "**%s**\n", p.Question)
+	if hint := votingHint(p); hint != "" && !p.Closed {
+		fmt.Fprintf(&content, "_%s_\n", hint)
+	}
+	for _, option := range p.Options {
+		fmt.Fprintf(&content, "%s: %s\n", option.Text(), formatVoteCount(results[option.ID]))
+	}
+	var controls []Component
+	if !p.Anonymous {
+		controls = append(controls, newButton("Show vote details", ButtonStyleSecondary, ActionShowDetails, p.ID, 0))
+	}
+	if p.Closed {
+		content.WriteString("*This poll is closed.*")
+		return MessageData{Content: content.String(), Components: newActionRows(controls)}
+	}
+	var voteButtons []Component
+	for _, option := range p.Options {
+		voteButtons = append(voteButtons, newButton(option.Text(), ButtonStylePrimary, ActionVoteForOption, p.ID, option.ID))
+	}
+	controls = append(controls, newButton("Refresh", ButtonStyleSecondary, ActionRefresh, p.ID, 0),
+		newButton("Close poll", ButtonStyleDanger, ActionClosePoll, p.ID, 0))
+	components := append(newActionRows(voteButtons), newActionRows(controls)...)
+	return MessageData{Content: strings.TrimSuffix(content.String(), "\n"), Components: components}
+}
+
+func NewVoteDetailMessage(results map[string][]string) MessageData {
+	var options []string
+	for option := range results {
+		options = append(options, option)
+	}
+	sort.Strings(options)
+	var content bytes.Buffer
+	for _, option := range options {
+		var mentions []string
+		for _, voter := range results[option] {
+			mentions = append(mentions, "<@"+voter+">")
+		}
+		fmt.Fprintf(&content, "%s: %s\n", option, strings.Join(mentions, ", "))
+	}
+	return NewEphemeralMessage(content.String())
+}
+
+// NewEphemeralMessage creates a message which is only shown to the user of the
+// interaction.
+func NewEphemeralMessage(message string) MessageData {
+	return MessageData{Content: message, Components: []Component{}, Flags: MessageFlagEphemeral}
+}
+
+func votingHint(p poll.Poll) string {
+	if p.Type != poll.PollTypeMultipleChoice {
+		return ""
+	}
+	if p.MaxChoices > 1 {
+		return fmt.Sprintf("Select up to %d options, click again to remove a vote", p.MaxChoices)
+	}
+	if p.MaxChoices == 1 {
+		return "Select one option, click again to remove your vote"
+	}
+	return "Select all options that apply, click again to remove a vote"
+}
+
+func formatVoteCount(voteCount uint64) string {
+	voteCountText := "Vote"
+	if voteCount != 1 {
+		voteCountText += "s"
+	}
+	return fmt.Sprintf("%d %s", voteCount, voteCountText)
+}
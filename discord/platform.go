@@ -0,0 +1,198 @@
+package discord
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"markusreschke.name/selfhostedchatpolling/chat"
+	"markusreschke.name/selfhostedchatpolling/poll"
+	"markusreschke.name/selfhostedchatpolling/slack"
+)
+
+const (
+	PlatformName          = "discord"
+	contentTypeJSON       = "application/json"
+	httpHeaderContentType = "Content-Type"
+	// ArgumentsOption is the name of the string option of the slash commands
+	// which holds the question and the options.
+	ArgumentsOption = "arguments"
+)
+
+// CommandKinds maps the names of the slash commands to the kind of poll they
+// create.
+var CommandKinds = map[string]struct {
+	Anonymous bool
+	Type      poll.PollType
+}{
+	"poll":      {false, poll.PollTypeSingleChoice},
+	"pollanon":  {true, poll.PollTypeSingleChoice},
+	"pollmulti": {false, poll.PollTypeMultipleChoice},
+}
+
+type PlatformConfig struct {
+	PublicKey  ed25519.PublicKey
+	LogTraffic bool
+}
+
+// Platform is the chat.Platform for the interactions endpoint of a Discord
+// application. Slash commands create polls, the buttons of poll messages send
+// message component interactions.
+type Platform struct {
+	config PlatformConfig
+	logger *log.Logger
+}
+
+func NewPlatform(config PlatformConfig, logger *log.Logger) *Platform {
+	return &Platform{config, logger}
+}
+
+func (p *Platform) Name() string {
+	return PlatformName
+}
+
+func (p *Platform) ParseCommand(writer http.ResponseWriter, request *http.Request) (chat.Command, bool) {
+	if request.Method != http.MethodPost {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		p.logger.Println("MethodNotAllowed")
+		return chat.Command{}, false
+	}
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Couldn't read body", err)
+		return chat.Command{}, false
+	}
+	if p.config.LogTraffic {
+		p.logger.Printf("Discord Interaction: %s\n", string(body))
+	}
+	err = VerifySignature(request.Header, body, p.config.PublicKey)
+	if err != nil {
+		writer.WriteHeader(http.StatusUnauthorized)
+		p.logger.Println("Unauthorized - Request verification failed: ", err)
+		return chat.Command{}, false
+	}
+	var interaction Interaction
+	err = json.Unmarshal(body, &interaction)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Couldn't parse interaction", err)
+		return chat.Command{}, false
+	}
+	switch interaction.Type {
+	case InteractionTypePing:
+		p.writeResponse(writer, InteractionResponse{Type: ResponseTypePong})
+		return chat.Command{}, false
+	case InteractionTypeApplicationCmd:
+		return p.parseSlashCommand(writer, interaction)
+	case InteractionTypeMessageComponent:
+		return p.parseComponentInteraction(writer, interaction)
+	}
+	writer.WriteHeader(http.StatusBadRequest)
+	p.logger.Printf("BadRequest - Unknown interaction type %d", interaction.Type)
+	return chat.Command{}, false
+}
+
+func (p *Platform) parseSlashCommand(writer http.ResponseWriter, interaction Interaction) (chat.Command, bool) {
+	if interaction.Data == nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Slash command without data")
+		return chat.Command{}, false
+	}
+	kind, ok := CommandKinds[interaction.Data.Name]
+	if !ok {
+		p.logger.Printf("Unknown slash command %q", interaction.Data.Name)
+		p.writeMessage(writer, ResponseTypeChannelMessageWithSource, NewEphemeralMessage("Unknown command!"))
+		return chat.Command{}, false
+	}
+	var arguments string
+	for _, option := range interaction.Data.Options {
+		if value, isString := option.Value.(string); option.Name == ArgumentsOption && isString {
+			arguments = value
+		}
+	}
+	newPoll, err := slack.ParsePollCommand(arguments, kind.Anonymous, kind.Type)
+	if err == nil && len(newPoll.Options) > MaxOptions {
+		err = errors.Errorf("Polls on Discord can have at most %d options", MaxOptions)
+	}
+	if err == nil && newPoll.HideResults {
+		err = errors.New("Hidden results aren't supported on Discord")
+	}
+	if err == nil && newPoll.AllowWriteIns {
+		err = errors.New("Write-ins aren't supported on Discord")
+	}
+	// Only Slack and Matrix update the message of polls closed by their deadline
+	if err == nil && !newPoll.Deadline.IsZero() {
+		err = errors.New("Deadlines aren't supported on Discord")
+	}
+	if err != nil {
+		p.logger.Println("Invalid poll command: ", err)
+		p.writeMessage(writer, ResponseTypeChannelMessageWithSource, NewEphemeralMessage(err.Error()))
+		return chat.Command{}, false
+	}
+	return chat.Command{Action: chat.ActionCreatePoll, UserID: interaction.UserID(), Poll: newPoll, Origin: interaction}, true
+}
+
+func (p *Platform) parseComponentInteraction(writer http.ResponseWriter, interaction Interaction) (chat.Command, bool) {
+	if interaction.Data == nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Component interaction without data")
+		return chat.Command{}, false
+	}
+	action, pollID, option, ok := ParseCustomID(interaction.Data.CustomID)
+	if !ok {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Printf("BadRequest - Invalid custom ID %q", interaction.Data.CustomID)
+		return chat.Command{}, false
+	}
+	command := chat.Command{UserID: interaction.UserID(), PollID: pollID, Option: option, Origin: interaction}
+	switch action {
+	case ActionVoteForOption:
+		command.Action = chat.ActionVote
+	case ActionVote:
+		command.Action = chat.ActionVote
+		command.OptionIsPosition = true
+	case ActionShowDetails:
+		command.Action = chat.ActionShowDetails
+	case ActionRefresh:
+		command.Action = chat.ActionRefresh
+	case ActionClosePoll:
+		command.Action = chat.ActionClosePoll
+	default:
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Printf("BadRequest - Unknown action %q", action)
+		return chat.Command{}, false
+	}
+	return command, true
+}
+
+func (p *Platform) WriteReply(writer http.ResponseWriter, command chat.Command, reply chat.Reply) {
+	switch reply.Kind {
+	case chat.ReplyPoll:
+		responseType := ResponseTypeUpdateMessage
+		if command.Action == chat.ActionCreatePoll {
+			responseType = ResponseTypeChannelMessageWithSource
+		}
+		p.writeMessage(writer, responseType, NewPollMessage(reply.Poll, reply.Results))
+	case chat.ReplyVoteDetails:
+		p.writeMessage(writer, ResponseTypeChannelMessageWithSource, NewVoteDetailMessage(reply.Details))
+	case chat.ReplyRankingForm:
+		p.writeMessage(writer, ResponseTypeChannelMessageWithSource, NewEphemeralMessage("Ranked choice polls aren't supported on Discord!"))
+	default:
+		p.writeMessage(writer, ResponseTypeChannelMessageWithSource, NewEphemeralMessage(reply.Text))
+	}
+}
+
+func (p *Platform) writeMessage(writer http.ResponseWriter, responseType int, msg MessageData) {
+	p.writeResponse(writer, InteractionResponse{Type: responseType, Data: &msg})
+}
+
+func (p *Platform) writeResponse(writer http.ResponseWriter, response InteractionResponse) {
+	writer.Header().Set(httpHeaderContentType, contentTypeJSON)
+	writer.WriteHeader(http.StatusOK)
+	responseJSON, _ := response.ToJSON()
+	writer.Write(responseJSON)
+}
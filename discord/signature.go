@@ -0,0 +1,49 @@
+package discord
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	SignatureHeader = "X-Signature-Ed25519"
+	TimestampHeader = "X-Signature-Timestamp"
+)
+
+var (
+	ErrMissingSignature = errors.New("Request is not signed!")
+	ErrInvalidSignature = errors.New("Request signature is invalid!")
+)
+
+// ParsePublicKey decodes the hex encoded public key of a Discord application.
+func ParsePublicKey(publicKeyHex string) (ed25519.PublicKey, error) {
+	publicKey, err := hex.DecodeString(publicKeyHex)
+	if err != nil {
+		return nil, errors.Wrap(err, "Public key isn't hex encoded!")
+	}
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, errors.Errorf("Public key has %d bytes instead of %d!", len(publicKey), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(publicKey), nil
+}
+
+// VerifySignature checks the Ed25519 signature of an interaction, which
+// Discord creates over the timestamp header followed by the raw body.
+func VerifySignature(header http.Header, body []byte, publicKey ed25519.PublicKey) error {
+	signatureHex := header.Get(SignatureHeader)
+	timestamp := header.Get(TimestampHeader)
+	if signatureHex == "" || timestamp == "" {
+		return ErrMissingSignature
+	}
+	signature, err := hex.DecodeString(signatureHex)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return errors.Wrap(ErrInvalidSignature, "Signature isn't hex encoded")
+	}
+	if !ed25519.Verify(publicKey, append([]byte(timestamp), body...), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -5,16 +5,25 @@ import (
 	"log"
 
 	"github.com/cloudfoundry-community/go-cfenv"
+	"markusreschke.name/selfhostedchatpolling/chat"
 	"markusreschke.name/selfhostedchatpolling/config"
+	"markusreschke.name/selfhostedchatpolling/discord"
 	"markusreschke.name/selfhostedchatpolling/handlers"
+	"markusreschke.name/selfhostedchatpolling/matrix"
+	"markusreschke.name/selfhostedchatpolling/mattermost"
 	"markusreschke.name/selfhostedchatpolling/poll"
 	"markusreschke.name/selfhostedchatpolling/poll/memstore"
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/IBM-Bluemix/go-cloudant"
 	"markusreschke.name/selfhostedchatpolling/poll/cloudantstore"
+	"markusreschke.name/selfhostedchatpolling/poll/pgstore"
+	"markusreschke.name/selfhostedchatpolling/poll/sqlitestore"
+	"markusreschke.name/selfhostedchatpolling/slack"
+	"markusreschke.name/selfhostedchatpolling/teams"
 )
 
 func getCloudantCredentialsFromEnv(cloudantServiceName string) (user, password string, err error) {
@@ -54,6 +63,80 @@ func configureCloudantBackend(appConfig config.AppConfig, logger *log.Logger) po
 	return pollStoreBackend
 }
 
+func configureSQLiteBackend(appConfig config.AppConfig, logger *log.Logger) poll.StoreBackend {
+	pollStoreBackend, err := sqlitestore.NewSQLiteStoreBackend(appConfig.SQLitePath)
+	if err != nil {
+		logger.Fatalf("Couldn't create poll store: %v", err)
+	}
+	return pollStoreBackend
+}
+
+func configurePostgresBackend(appConfig config.AppConfig, logger *log.Logger) poll.StoreBackend {
+	pollStoreBackend, err := pgstore.NewPostgresStoreBackend(appConfig.PostgresDSN)
+	if err != nil {
+		logger.Fatalf("Couldn't create poll store: %v", err)
+	}
+	return pollStoreBackend
+}
+
+func configureSlackPlatform(appConfig config.AppConfig, logger *log.Logger) *slack.Platform {
+	verifier := slack.RequestVerifier{
+		SigningSecret:     appConfig.SlackSigningSecret,
+		MaxSkew:           appConfig.SlackSignatureMaxSkew,
+		LegacyToken:       appConfig.SlackVerificationToken,
+		AcceptLegacyToken: appConfig.SlackAcceptLegacyToken,
+	}
+	platformConfig := slack.PlatformConfig{
+		Verifier:    verifier,
+		OAuthToken:  appConfig.SlackOAuthToken,
+		UseBlockKit: appConfig.SlackUseBlockKit,
+		LogTraffic:  appConfig.LogTraffic,
+	}
+	return slack.NewPlatform(platformConfig, logger)
+}
+
+func configureMattermostPlatform(appConfig config.AppConfig, logger *log.Logger) *mattermost.Platform {
+	platformConfig := mattermost.PlatformConfig{
+		CommandTokens: appConfig.MattermostCommandTokens,
+		ActionURL:     appConfig.MattermostActionURL,
+		ActionToken:   appConfig.MattermostActionToken,
+		ServerURL:     appConfig.MattermostServerURL,
+		BotToken:      appConfig.MattermostBotToken,
+		LogTraffic:    appConfig.LogTraffic,
+	}
+	return mattermost.NewPlatform(platformConfig, logger)
+}
+
+func configureTeamsPlatform(appConfig config.AppConfig, logger *log.Logger) *teams.Platform {
+	platformConfig := teams.PlatformConfig{
+		WebhookSecret: appConfig.TeamsWebhookSecret,
+		LogTraffic:    appConfig.LogTraffic,
+	}
+	teamsPlatform, err := teams.NewPlatform(platformConfig, logger)
+	if err != nil {
+		logger.Fatalf("Couldn't create Teams platform: %v", err)
+	}
+	return teamsPlatform
+}
+
+func configureDiscordPlatform(appConfig config.AppConfig, logger *log.Logger) *discord.Platform {
+	publicKey, err := discord.ParsePublicKey(appConfig.DiscordPublicKey)
+	if err != nil {
+		logger.Fatalf("Couldn't create Discord platform: %v", err)
+	}
+	return discord.NewPlatform(discord.PlatformConfig{PublicKey: publicKey, LogTraffic: appConfig.LogTraffic}, logger)
+}
+
+func configureMatrixBot(appConfig config.AppConfig, pollService *chat.Service, logger *log.Logger) *matrix.Bot {
+	botConfig := matrix.BotConfig{
+		UserID:        appConfig.MatrixUserID,
+		UsePollEvents: appConfig.MatrixUsePollEvents,
+		RetryDelay:    10 * time.Second,
+		LogTraffic:    appConfig.LogTraffic,
+	}
+	return matrix.NewBot(matrix.NewClient(appConfig.MatrixHomeserver, appConfig.MatrixAccessToken), pollService, botConfig, logger)
+}
+
 func main() {
 	logger := log.New(os.Stdout, "logger: ", log.Lshortfile)
 	appConfig, err := config.ReadConfigFromEnv()
@@ -66,13 +149,54 @@ func main() {
 		pollStoreBackend = configureCloudantBackend(appConfig, logger)
 	case config.BackendInMemory:
 		pollStoreBackend = memstore.NewInMemoryStoreBackend()
+	case config.BackendSQLite:
+		pollStoreBackend = configureSQLiteBackend(appConfig, logger)
+	case config.BackendPostgres:
+		pollStoreBackend = configurePostgresBackend(appConfig, logger)
 	default:
 		logger.Fatal("Invalid backend configured!")
 	}
 	pollStore := poll.NewDefaultStore(pollStoreBackend)
-	http.HandleFunc("/newpoll", handlers.GetNewPollRequestHandler(appConfig, logger, pollStore, false))
-	http.HandleFunc("/newpollanon", handlers.GetNewPollRequestHandler(appConfig, logger, pollStore, true))
-	http.HandleFunc("/updatepoll", handlers.GetPollButtonRequestHandler(appConfig, logger, pollStore))
+	pollService := chat.NewService(pollStore, logger)
+	var messageUpdaters []chat.MessageUpdater
+	if appConfig.SlackEnabled() {
+		slackPlatform := configureSlackPlatform(appConfig, logger)
+		messageUpdaters = append(messageUpdaters, slackPlatform)
+		http.HandleFunc("/poll", chat.NewRequestHandler(slackPlatform.PollCommand(), pollService))
+		// The endpoints of the separate slash commands are kept as aliases
+		http.HandleFunc("/newpoll", chat.NewRequestHandler(slackPlatform.SlashCommand(false, poll.PollTypeSingleChoice), pollService))
+		http.HandleFunc("/newpollanon", chat.NewRequestHandler(slackPlatform.SlashCommand(true, poll.PollTypeSingleChoice), pollService))
+		http.HandleFunc("/newpollmulti", chat.NewRequestHandler(slackPlatform.SlashCommand(false, poll.PollTypeMultipleChoice), pollService))
+		http.HandleFunc("/newpollranked", chat.NewRequestHandler(slackPlatform.SlashCommand(false, poll.PollTypeRankedChoice), pollService))
+		http.HandleFunc("/updatepoll", chat.NewRequestHandler(slackPlatform, pollService))
+	}
+	if appConfig.MattermostEnabled() {
+		mattermostPlatform := configureMattermostPlatform(appConfig, logger)
+		http.HandleFunc("/mattermost/newpoll", chat.NewRequestHandler(mattermostPlatform.SlashCommand(false, poll.PollTypeSingleChoice), pollService))
+		http.HandleFunc("/mattermost/newpollanon", chat.NewRequestHandler(mattermostPlatform.SlashCommand(true, poll.PollTypeSingleChoice), pollService))
+		http.HandleFunc("/mattermost/newpollmulti", chat.NewRequestHandler(mattermostPlatform.SlashCommand(false, poll.PollTypeMultipleChoice), pollService))
+		http.HandleFunc("/mattermost/actions", chat.NewRequestHandler(mattermostPlatform, pollService))
+	}
+	if appConfig.TeamsEnabled() {
+		teamsPlatform := configureTeamsPlatform(appConfig, logger)
+		http.HandleFunc("/teams/messages", chat.NewRequestHandler(teamsPlatform, pollService))
+	}
+	if appConfig.DiscordEnabled() {
+		discordPlatform := configureDiscordPlatform(appConfig, logger)
+		http.HandleFunc("/discord/interactions", chat.NewRequestHandler(discordPlatform, pollService))
+	}
+	if appConfig.MatrixEnabled() {
+		matrixBot := configureMatrixBot(appConfig, pollService, logger)
+		messageUpdaters = append(messageUpdaters, matrixBot)
+		go matrixBot.Run(make(chan struct{}))
+	}
+	deadlineScheduler := poll.NewDeadlineScheduler(pollStore, appConfig.DeadlineCheckInterval, logger,
+		chat.NewClosedPollNotifier(pollService, logger, slack.PlatformName, messageUpdaters...))
+	go deadlineScheduler.Run(make(chan struct{}))
+	if appConfig.RetentionPeriod > 0 {
+		go poll.NewRetentionJanitor(pollStore, appConfig.RetentionPeriod, time.Hour, logger,
+			chat.NewDeletedPollNotifier(logger, slack.PlatformName, messageUpdaters...)).Run(make(chan struct{}))
+	}
 	http.HandleFunc("/version", handlers.GetVersionRequestHandler(appConfig, logger))
 	http.ListenAndServe(":"+strconv.Itoa(appConfig.Port), nil)
 }
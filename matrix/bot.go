@@ -0,0 +1,422 @@
+package matrix
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"markusreschke.name/selfhostedchatpolling/chat"
+	"markusreschke.name/selfhostedchatpolling/poll"
+	"markusreschke.name/selfhostedchatpolling/slack"
+)
+
+const (
+	PlatformName = "matrix"
+	syncTimeout  = 30 * time.Second
+)
+
+// CommandKinds maps the bot commands to the kind of poll they create. Votes
+// are visible reactions, so there are no anonymous polls on Matrix.
+var CommandKinds = map[string]poll.PollType{
+	"!poll":      poll.PollTypeSingleChoice,
+	"!pollmulti": poll.PollTypeMultipleChoice,
+}
+
+type BotConfig struct {
+	// UserID is the Matrix ID of the bot account, its own events are ignored.
+	UserID string
+	// UsePollEvents posts polls as MSC3381 poll events instead of messages
+	// which are voted on with reactions.
+	UsePollEvents bool
+	RetryDelay    time.Duration
+	LogTraffic    bool
+}
+
+// trackedReaction is a vote by reaction, which is removed again when the
+// reaction is redacted. Reactions refer to options by their position in the
+// poll message.
+type trackedReaction struct {
+	roomID      string
+	pollEventID string
+	voterID     string
+	option      int
+	pollType    poll.PollType
+}
+
+// Bot creates polls from commands in the rooms it has joined and counts
+// reactions and poll responses as votes. It keeps the message of each poll up
+// to date with the current results.
+type Bot struct {
+	client  *Client
+	service *chat.Service
+	config  BotConfig
+	logger  *log.Logger
+	// pollIDs caches the poll IDs of poll events.
+	pollIDs   map[string]string
+	reactions map[string]trackedReaction
+}
+
+func NewBot(client *Client, service *chat.Service, config BotConfig, logger *log.Logger) *Bot {
+	return &Bot{client, service, config, logger, make(map[string]string), make(map[string]trackedReaction)}
+}
+
+// Run syncs with the homeserver until stop is closed. Events which happened
+// before the bot was started are ignored.
+func (b *Bot) Run(stop <-chan struct{}) {
+	since := ""
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		nextBatch, err := b.sync(since)
+		if err != nil {
+			b.logger.Println("Error syncing with Matrix homeserver: ", err)
+			select {
+			case <-stop:
+				return
+			case <-time.After(b.config.RetryDelay):
+			}
+			continue
+		}
+		since = nextBatch
+	}
+}
+
+// sync handles the events since the given batch token and returns the token
+// for the next sync.
+func (b *Bot) sync(since string) (string, error) {
+	response, err := b.client.Sync(since, syncTimeout)
+	if err != nil {
+		return since, err
+	}
+	for roomID := range response.Rooms.Invite {
+		err = b.client.JoinRoom(roomID)
+		if err != nil {
+			b.logger.Printf("Error joining room %s: %v", roomID, err)
+		}
+	}
+	if since == "" {
+		return response.NextBatch, nil
+	}
+	for roomID, room := range response.Rooms.Join {
+		for _, event := range room.Timeline.Events {
+			if event.Sender == b.config.UserID {
+				continue
+			}
+			if b.config.LogTraffic {
+				b.logger.Printf("Matrix Event in %s: %s %s\n", roomID, event.Type, string(event.Content))
+			}
+			err = b.handleEvent(roomID, event)
+			if err != nil {
+				b.logger.Printf("Error handling event %s: %v", event.EventID, err)
+			}
+		}
+	}
+	return response.NextBatch, nil
+}
+
+func (b *Bot) handleEvent(roomID string, event Event) error {
+	switch event.Type {
+	case EventTypeMessage:
+		var content MessageContent
+		err := json.Unmarshal(event.Content, &content)
+		if err != nil {
+			return errors.Wrap(err, "Error parsing message")
+		}
+		return b.handleMessage(roomID, event.Sender, content)
+	case EventTypeReaction:
+		var content RelationContent
+		err := json.Unmarshal(event.Content, &content)
+		if err != nil {
+			return errors.Wrap(err, "Error parsing reaction")
+		}
+		return b.handleReaction(roomID, event, content.RelatesTo)
+	case EventTypePollResponse:
+		var content RelationContent
+		err := json.Unmarshal(event.Content, &content)
+		if err != nil || content.PollResponse == nil {
+			return errors.Wrap(err, "Error parsing poll response")
+		}
+		return b.handlePollResponse(roomID, event.Sender, content.RelatesTo.EventID, content.PollResponse.Answers)
+	case EventTypePollEnd:
+		var content RelationContent
+		err := json.Unmarshal(event.Content, &content)
+		if err != nil {
+			return errors.Wrap(err, "Error parsing poll end")
+		}
+		return b.execute(roomID, content.RelatesTo.EventID, chat.Command{Action: chat.ActionClosePoll, UserID: event.Sender})
+	case EventTypeRedaction:
+		var content RelationContent
+		json.Unmarshal(event.Content, &content)
+		redacts := event.Redacts
+		if redacts == "" {
+			redacts = content.Redacts
+		}
+		return b.handleRedaction(redacts)
+	}
+	return nil
+}
+
+func (b *Bot) handleMessage(roomID, sender string, content MessageContent) error {
+	if content.MsgType != "m.text" || content.RelatesTo != nil {
+		return nil
+	}
+	commandName, arguments := slack.SplitCommandName(content.Body)
+	pollType, ok := CommandKinds[commandName]
+	if !ok {
+		return nil
+	}
+	newPoll, err := slack.ParsePollCommand(arguments, false, pollType)
+	if err == nil && len(newPoll.Options) > len(OptionReactions) {
+		err = errors.Errorf("Polls on Matrix can have at most %d options", len(OptionReactions))
+	}
+	// Votes are reactions, which everyone in the room can see
+	if err == nil && newPoll.HideResults {
+		err = errors.New("Hidden results aren't supported on Matrix")
+	}
+	if err == nil && newPoll.AllowWriteIns {
+		err = errors.New("Write-ins aren't supported on Matrix")
+	}
+	if err != nil {
+		b.logger.Println("Invalid poll command: ", err)
+		return b.sendNotice(roomID, err.Error())
+	}
+	reply := b.service.Execute(chat.Command{Action: chat.ActionCreatePoll, Platform: PlatformName, UserID: sender, Poll: newPoll})
+	if reply.Kind != chat.ReplyPoll {
+		return b.sendNotice(roomID, reply.Text)
+	}
+	var eventID string
+	if b.config.UsePollEvents {
+		eventID, err = b.client.SendEvent(roomID, EventTypePollStart, NewPollStart(reply.Poll))
+	} else {
+		eventID, err = b.client.SendEvent(roomID, EventTypeMessage, NewPollMessage(reply.Poll, reply.Results))
+	}
+	if err != nil {
+		return errors.Wrap(err, "Error sending poll")
+	}
+	b.pollIDs[eventID] = reply.Poll.ID
+	// The poll event is stored as response URL of the poll to update it
+	// once the poll is closed by its deadline
+	if !reply.Poll.Deadline.IsZero() {
+		return b.execute(roomID, eventID, chat.Command{Action: chat.ActionRefresh, UserID: sender, ResponseURL: pollEventURI(roomID, eventID)})
+	}
+	return nil
+}
+
+func (b *Bot) handleReaction(roomID string, event Event, relatesTo RelatesTo) error {
+	if relatesTo.Key == CloseReaction {
+		return b.execute(roomID, relatesTo.EventID, chat.Command{Action: chat.ActionClosePoll, UserID: event.Sender})
+	}
+	option, ok := optionForReaction(relatesTo.Key)
+	if !ok {
+		return nil
+	}
+	// A reaction is a vote as long as it exists, so reacting again with the
+	// option of the current vote of a single choice poll keeps the vote
+	reply, err := b.executeOnPoll(roomID, relatesTo.EventID, chat.Command{Action: chat.ActionReplaceVote, UserID: event.Sender, Option: option, OptionIsPosition: true})
+	if err != nil || reply.Kind != chat.ReplyPoll {
+		return err
+	}
+	b.reactions[event.EventID] = trackedReaction{roomID, relatesTo.EventID, event.Sender, option, reply.Poll.Type}
+	return nil
+}
+
+// handleRedaction removes the vote of a redacted reaction. The vote of a
+// single choice poll is only withdrawn if it is still for the option of the
+// reaction, as reacting with another option replaced it.
+func (b *Bot) handleRedaction(redactedEventID string) error {
+	reaction, ok := b.reactions[redactedEventID]
+	if !ok {
+		return nil
+	}
+	delete(b.reactions, redactedEventID)
+	if reaction.pollType == poll.PollTypeMultipleChoice {
+		return b.execute(reaction.roomID, reaction.pollEventID, chat.Command{Action: chat.ActionVote, UserID: reaction.voterID, Option: reaction.option, OptionIsPosition: true})
+	}
+	myVote, err := b.executeOnPoll(reaction.roomID, reaction.pollEventID, chat.Command{Action: chat.ActionShowMyVote, UserID: reaction.voterID})
+	if err != nil || myVote.Kind != chat.ReplyMyVote || reaction.option >= len(myVote.Poll.Options) {
+		return err
+	}
+	if len(myVote.Choices) != 1 || myVote.Choices[0] != myVote.Poll.Options[reaction.option].ID {
+		return nil
+	}
+	return b.execute(reaction.roomID, reaction.pollEventID, chat.Command{Action: chat.ActionRetractVote, UserID: reaction.voterID})
+}
+
+// handlePollResponse applies the selected answers of a poll response. A
+// response replaces all previous selections of the voter, a response without
+// answers withdraws the vote.
+func (b *Bot) handlePollResponse(roomID, sender, pollEventID string, answers []string) error {
+	var options []int
+	for _, answer := range answers {
+		option, err := strconv.Atoi(answer)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid poll answer %q", answer)
+		}
+		options = append(options, option)
+	}
+	refresh, err := b.executeOnPoll(roomID, pollEventID, chat.Command{Action: chat.ActionRefresh})
+	if err != nil || refresh.Kind != chat.ReplyPoll {
+		return err
+	}
+	if refresh.Poll.Type != poll.PollTypeMultipleChoice {
+		if len(options) == 0 {
+			return b.execute(roomID, pollEventID, chat.Command{Action: chat.ActionRetractVote, UserID: sender})
+		}
+		return b.execute(roomID, pollEventID, chat.Command{Action: chat.ActionReplaceVote, UserID: sender, Option: options[0]})
+	}
+	details, err := b.executeOnPoll(roomID, pollEventID, chat.Command{Action: chat.ActionShowDetails})
+	if err != nil || details.Kind != chat.ReplyVoteDetails {
+		return err
+	}
+	for _, option := range refresh.Poll.Options {
+		if containsInt(options, option.ID) != containsString(details.Details[option.Label], sender) {
+			err = b.execute(roomID, pollEventID, chat.Command{Action: chat.ActionVote, UserID: sender, Option: option.ID})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Bot) execute(roomID, pollEventID string, command chat.Command) error {
+	_, err := b.executeOnPoll(roomID, pollEventID, command)
+	return err
+}
+
+// executeOnPoll executes the command on the poll of the given event and
+// updates the poll event with the reply. Errors for the user are sent as
+// notices.
+func (b *Bot) executeOnPoll(roomID, pollEventID string, command chat.Command) (chat.Reply, error) {
+	pollID, err := b.pollIDForEvent(roomID, pollEventID)
+	if err != nil || pollID == "" {
+		return chat.Reply{}, err
+	}
+	command.Platform = PlatformName
+	command.PollID = pollID
+	reply := b.service.Execute(command)
+	switch reply.Kind {
+	case chat.ReplyPoll:
+		if command.Action == chat.ActionRefresh {
+			break
+		}
+		err = b.updatePollEvent(roomID, pollEventID, reply)
+	case chat.ReplyError:
+		err = b.sendNotice(roomID, reply.Text)
+	}
+	return reply, err
+}
+
+func (b *Bot) updatePollEvent(roomID, pollEventID string, reply chat.Reply) error {
+	var err error
+	if !b.config.UsePollEvents {
+		_, err = b.client.SendEvent(roomID, EventTypeMessage, NewPollEdit(pollEventID, reply.Poll, reply.Results))
+	} else if reply.Poll.Closed {
+		// Clients show the results of poll events themselves, they only
+		// need to know when the poll is closed
+		_, err = b.client.SendEvent(roomID, EventTypePollEnd, NewPollEnd(pollEventID))
+	}
+	return errors.Wrap(err, "Error updating poll event")
+}
+
+// Name returns the platform name of the polls created by the bot.
+func (b *Bot) Name() string {
+	return PlatformName
+}
+
+// UpdatePollMessage updates the poll event of a poll closed by its deadline or
+// deleted after the retention period.
+func (b *Bot) UpdatePollMessage(reply chat.Reply) error {
+	roomID, pollEventID, ok := parsePollEventURI(reply.Poll.ResponseURL)
+	if !ok {
+		return errors.Errorf("Poll %s has no poll event to update", reply.Poll.ID)
+	}
+	if reply.Kind != chat.ReplyDeletedPoll {
+		return b.updatePollEvent(roomID, pollEventID, reply)
+	}
+	var err error
+	if b.config.UsePollEvents {
+		_, err = b.client.SendEvent(roomID, EventTypePollEnd, NewDeletedPollEnd(pollEventID))
+	} else {
+		_, err = b.client.SendEvent(roomID, EventTypeMessage, NewDeletedPollEdit(pollEventID))
+	}
+	return errors.Wrap(err, "Error replacing deleted poll event")
+}
+
+// pollEventURI returns the matrix: URI of the event, e.g.
+// matrix:roomid/room:example.org/e/event.
+func pollEventURI(roomID, eventID string) string {
+	return "matrix:roomid/" + url.PathEscape(strings.TrimPrefix(roomID, "!")) + "/e/" + url.PathEscape(strings.TrimPrefix(eventID, "$"))
+}
+
+// parsePollEventURI returns the room and event ID of a URI created by
+// pollEventURI.
+func parsePollEventURI(uri string) (string, string, bool) {
+	if !strings.HasPrefix(uri, "matrix:roomid/") {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(uri, "matrix:roomid/"), "/e/")
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	roomID, err := url.PathUnescape(parts[0])
+	if err != nil {
+		return "", "", false
+	}
+	eventID, err := url.PathUnescape(parts[1])
+	if err != nil {
+		return "", "", false
+	}
+	return "!" + roomID, "$" + eventID, true
+}
+
+// pollIDForEvent returns the ID of the poll posted with the given event, or
+// an empty ID if the event isn't a poll of the bot.
+func (b *Bot) pollIDForEvent(roomID, eventID string) (string, error) {
+	if pollID, ok := b.pollIDs[eventID]; ok {
+		return pollID, nil
+	}
+	event, err := b.client.GetEvent(roomID, eventID)
+	if err != nil {
+		return "", errors.Wrapf(err, "Error fetching event %s", eventID)
+	}
+	if event.Sender != b.config.UserID {
+		return "", nil
+	}
+	var content struct {
+		PollID string `json:"name.markusreschke.shcp.poll_id"`
+	}
+	json.Unmarshal(event.Content, &content)
+	b.pollIDs[eventID] = content.PollID
+	return content.PollID, nil
+}
+
+func (b *Bot) sendNotice(roomID, text string) error {
+	_, err := b.client.SendEvent(roomID, EventTypeMessage, NewNotice(text))
+	return errors.Wrap(err, "Error sending notice")
+}
@@ -0,0 +1,113 @@
+package matrix
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+const clientAPIPath = "/_matrix/client/v3"
+
+// Client is a minimal client of the Matrix client-server API.
+type Client struct {
+	homeserverURL string
+	accessToken   string
+	httpClient    *http.Client
+}
+
+// Event is a room event as returned by the sync API.
+type Event struct {
+	Type    string          `json:"type"`
+	EventID string          `json:"event_id"`
+	Sender  string          `json:"sender"`
+	Content json.RawMessage `json:"content"`
+	// Redacts is the redacted event of redactions in rooms before room
+	// version 11, newer rooms have it in the content.
+	Redacts string `json:"redacts,omitempty"`
+}
+
+type SyncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join map[string]struct {
+			Timeline struct {
+				Events []Event `json:"events"`
+			} `json:"timeline"`
+		} `json:"join"`
+		Invite map[string]json.RawMessage `json:"invite"`
+	} `json:"rooms"`
+}
+
+func NewClient(homeserverURL, accessToken string) *Client {
+	return &Client{homeserverURL, accessToken, &http.Client{Timeout: 2 * time.Minute}}
+}
+
+func (c *Client) do(method, path string, query url.Values, requestBody interface{}, responseBody interface{}) error {
+	requestURL := c.homeserverURL + clientAPIPath + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+	var body bytes.Buffer
+	if requestBody != nil {
+		err := json.NewEncoder(&body).Encode(requestBody)
+		if err != nil {
+			return errors.Wrap(err, "Error encoding request")
+		}
+	}
+	request, err := http.NewRequest(method, requestURL, &body)
+	if err != nil {
+		return errors.Wrap(err, "Error creating request")
+	}
+	request.Header.Set("Authorization", "Bearer "+c.accessToken)
+	request.Header.Set("Content-Type", "application/json")
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return errors.Wrapf(err, "Error requesting %s", path)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return errors.Errorf("Request to %s failed with status %s", path, response.Status)
+	}
+	if responseBody == nil {
+		return nil
+	}
+	return errors.Wrapf(json.NewDecoder(response.Body).Decode(responseBody), "Error decoding response of %s", path)
+}
+
+// Sync returns the events since the given batch token. It waits up to
+// timeout for new events.
+func (c *Client) Sync(since string, timeout time.Duration) (SyncResponse, error) {
+	query := url.Values{"timeout": {strconv.FormatInt(int64(timeout/time.Millisecond), 10)}}
+	if since != "" {
+		query.Set("since", since)
+	}
+	var response SyncResponse
+	err := c.do(http.MethodGet, "/sync", query, nil, &response)
+	return response, err
+}
+
+// SendEvent sends a room event and returns its ID.
+func (c *Client) SendEvent(roomID, eventType string, content interface{}) (string, error) {
+	path := "/rooms/" + url.PathEscape(roomID) + "/send/" + url.PathEscape(eventType) + "/" + uuid.NewV4().String()
+	var response struct {
+		EventID string `json:"event_id"`
+	}
+	err := c.do(http.MethodPut, path, nil, content, &response)
+	return response.EventID, err
+}
+
+func (c *Client) GetEvent(roomID, eventID string) (Event, error) {
+	var event Event
+	err := c.do(http.MethodGet, "/rooms/"+url.PathEscape(roomID)+"/event/"+url.PathEscape(eventID), nil, nil, &event)
+	return event, err
+}
+
+func (c *Client) JoinRoom(roomID string) error {
+	return c.do(http.MethodPost, "/join/"+url.PathEscape(roomID), nil, struct{}{}, nil)
+}
@@ -0,0 +1,252 @@
+package matrix
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"markusreschke.name/selfhostedchatpolling/chat"
+	"markusreschke.name/selfhostedchatpolling/poll"
+	"markusreschke.name/selfhostedchatpolling/poll/memstore"
+)
+
+const (
+	testRoomID    = "!room:example.org"
+	testBotUserID = "@polls:example.org"
+)
+
+func TestMain(m *testing.M) {
+	flag.Bool("integration", false, "run integration tests")
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+type sentEvent struct {
+	Type    string
+	Content map[string]interface{}
+}
+
+// fakeHomeserver serves one sync response per sync request and records the
+// events sent by the bot.
+type fakeHomeserver struct {
+	mutex         sync.Mutex
+	syncResponses []string
+	sentEvents    []sentEvent
+	joinedRooms   []string
+}
+
+func (h *fakeHomeserver) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if request.Header.Get("Authorization") != "Bearer token" {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(request.URL.Path, clientAPIPath)
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "/sync":
+		if len(h.syncResponses) == 0 {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writer.Write([]byte(h.syncResponses[0]))
+		h.syncResponses = h.syncResponses[1:]
+	case len(parts) == 6 && parts[1] == "rooms" && parts[3] == "send":
+		var content map[string]interface{}
+		json.NewDecoder(request.Body).Decode(&content)
+		h.sentEvents = append(h.sentEvents, sentEvent{parts[4], content})
+		fmt.Fprintf(writer, `{"event_id":"$sent%d"}`, len(h.sentEvents))
+	case len(parts) == 5 && parts[1] == "rooms" && parts[3] == "event":
+		var index int
+		if _, err := fmt.Sscanf(parts[4], "$sent%d", &index); err != nil || index < 1 || index > len(h.sentEvents) {
+			json.NewEncoder(writer).Encode(Event{Type: EventTypeMessage, EventID: parts[4], Sender: "@mallory:example.org",
+				Content: json.RawMessage(`{"msgtype":"m.text","body":"fake","name.markusreschke.shcp.poll_id":"1"}`)})
+			return
+		}
+		content, _ := json.Marshal(h.sentEvents[index-1].Content)
+		json.NewEncoder(writer).Encode(Event{Type: h.sentEvents[index-1].Type, EventID: parts[4], Sender: testBotUserID, Content: content})
+	case len(parts) == 3 && parts[1] == "join":
+		h.joinedRooms = append(h.joinedRooms, parts[2])
+		writer.Write([]byte(`{}`))
+	default:
+		writer.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (h *fakeHomeserver) lastSentEvent(t *testing.T) sentEvent {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.sentEvents) == 0 {
+		t.Fatal("Expected the bot to send an event")
+	}
+	return h.sentEvents[len(h.sentEvents)-1]
+}
+
+func newSyncResponse(nextBatch string, events ...string) string {
+	return fmt.Sprintf(`{"next_batch":%q,"rooms":{"join":{%q:{"timeline":{"events":[%s]}}}}}`, nextBatch, testRoomID, strings.Join(events, ","))
+}
+
+func newMessageEvent(eventID, sender, body string) string {
+	content, _ := json.Marshal(MessageContent{MsgType: "m.text", Body: body})
+	return fmt.Sprintf(`{"type":"m.room.message","event_id":%q,"sender":%q,"content":%s}`, eventID, sender, content)
+}
+
+func newReactionEvent(eventID, sender, pollEventID, key string) string {
+	return fmt.Sprintf(`{"type":"m.reaction","event_id":%q,"sender":%q,"content":{"m.relates_to":{"rel_type":"m.annotation","event_id":%q,"key":%q}}}`,
+		eventID, sender, pollEventID, key)
+}
+
+func newPollResponseEvent(eventID, sender, pollEventID string, answers ...string) string {
+	answersJSON, _ := json.Marshal(answers)
+	return fmt.Sprintf(`{"type":%q,"event_id":%q,"sender":%q,"content":{"m.relates_to":{"rel_type":"m.reference","event_id":%q},"org.matrix.msc3381.poll.response":{"answers":%s}}}`,
+		EventTypePollResponse, eventID, sender, pollEventID, answersJSON)
+}
+
+func newTestBot(homeserverURL string, store poll.Store, usePollEvents bool) *Bot {
+	logger := log.New(ioutil.Discard, "", 0)
+	config := BotConfig{UserID: testBotUserID, UsePollEvents: usePollEvents, RetryDelay: time.Millisecond}
+	return NewBot(NewClient(homeserverURL, "token"), chat.NewService(store, logger), config, logger)
+}
+
+func syncAll(t *testing.T, bot *Bot, batches ...string) {
+	for _, since := range batches {
+		_, err := bot.sync(since)
+		if err != nil {
+			t.Fatal("Error syncing: ", err)
+		}
+	}
+}
+
+func TestPollWithReactions(t *testing.T) {
+	homeserver := &fakeHomeserver{syncResponses: []string{
+		`{"next_batch":"s1","rooms":{"invite":{"!room:example.org":{}},"join":{"!room:example.org":{"timeline":{"events":[` +
+			newMessageEvent("$old", "@alice:example.org", `!poll "Old poll" a b`) + `]}}}}}`,
+		newSyncResponse("s2", newMessageEvent("$1", "@alice:example.org", `!poll "Where do we go for lunch?" Pizza "Thai food"`)),
+		newSyncResponse("s3", newReactionEvent("$2", "@bob:example.org", "$sent1", "2️⃣"), newReactionEvent("$3", "@carol:example.org", "$sent1", "2️⃣"),
+			newReactionEvent("$4", "@bob:example.org", "$sent1", "1️⃣")),
+	}}
+	server := httptest.NewServer(homeserver)
+	defer server.Close()
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	syncAll(t, newTestBot(server.URL, store, false), "", "s1")
+	if diff := deep.Equal([]string{testRoomID}, homeserver.joinedRooms); diff != nil {
+		t.Error("Expected the bot to join the room it was invited to: ", diff)
+	}
+	pollMessage := homeserver.lastSentEvent(t)
+	if len(homeserver.sentEvents) != 1 || pollMessage.Type != EventTypeMessage || pollMessage.Content["name.markusreschke.shcp.poll_id"] == "" {
+		t.Fatalf("Expected one poll message but got %v", homeserver.sentEvents)
+	}
+	// a restarted bot has to look up the poll ID of the poll message
+	syncAll(t, newTestBot(server.URL, store, false), "s2")
+	edit := homeserver.lastSentEvent(t)
+	expectedText := "📊 Where do we go for lunch?\n1️⃣ Pizza: 1 Vote\n2️⃣ Thai food: 1 Vote\nReact with the number of an option to vote."
+	newContent, _ := edit.Content["m.new_content"].(map[string]interface{})
+	if newContent["body"] != expectedText || edit.Content["m.relates_to"].(map[string]interface{})["event_id"] != "$sent1" {
+		t.Errorf("Expected edit of poll message with text %q but got %v", expectedText, edit.Content)
+	}
+}
+
+func TestReactingAgainAfterRedaction(t *testing.T) {
+	homeserver := &fakeHomeserver{syncResponses: []string{
+		newSyncResponse("s1"),
+		newSyncResponse("s2", newMessageEvent("$1", "@alice:example.org", `!poll "Lunch?" Pizza "Thai food"`)),
+		newSyncResponse("s3", newReactionEvent("$2", "@bob:example.org", "$sent1", "1️⃣")),
+		newSyncResponse("s4", `{"type":"m.room.redaction","event_id":"$3","sender":"@bob:example.org","redacts":"$2","content":{}}`),
+		newSyncResponse("s5", newReactionEvent("$4", "@bob:example.org", "$sent1", "1️⃣")),
+		newSyncResponse("s6", newReactionEvent("$5", "@bob:example.org", "$sent1", "1️⃣")),
+	}}
+	server := httptest.NewServer(homeserver)
+	defer server.Close()
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	bot := newTestBot(server.URL, store, false)
+	syncAll(t, bot, "", "s1", "s2", "s3")
+	pollID, _ := homeserver.sentEvents[0].Content["name.markusreschke.shcp.poll_id"].(string)
+	if results, _ := store.GetResult(pollID); len(results) != 0 {
+		t.Fatalf("Expected redaction to withdraw the vote but got %v", results)
+	}
+	syncAll(t, bot, "s4", "s5")
+	if results, _ := store.GetResult(pollID); results[0] != 1 {
+		t.Errorf("Expected repeated reactions to keep the vote but got %v", results)
+	}
+}
+
+func TestUpdatingPollClosedByDeadline(t *testing.T) {
+	homeserver := &fakeHomeserver{syncResponses: []string{
+		newSyncResponse("s1"),
+		newSyncResponse("s2", newMessageEvent("$1", "@alice:example.org", `!poll --closes 1h "Lunch?" Pizza "Thai food"`)),
+	}}
+	server := httptest.NewServer(homeserver)
+	defer server.Close()
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	bot := newTestBot(server.URL, store, false)
+	syncAll(t, bot, "", "s1")
+	pollID, _ := homeserver.sentEvents[0].Content["name.markusreschke.shcp.poll_id"].(string)
+	storedPoll, err := store.GetPoll(pollID)
+	if err != nil || storedPoll.ResponseURL != "matrix:roomid/room:example.org/e/sent1" {
+		t.Fatalf("Expected poll event to be stored as response URL but got %v and error %v", storedPoll, err)
+	}
+	storedPoll.Closed = true
+	chat.NewClosedPollNotifier(bot.service, bot.logger, "slack", bot)(storedPoll)
+	edit := homeserver.lastSentEvent(t)
+	newContent, _ := edit.Content["m.new_content"].(map[string]interface{})
+	body, _ := newContent["body"].(string)
+	if len(homeserver.sentEvents) != 2 || edit.Content["m.relates_to"].(map[string]interface{})["event_id"] != "$sent1" || !strings.Contains(body, "Lunch?") {
+		t.Errorf("Expected edit of the closed poll message but got %v", homeserver.sentEvents)
+	}
+}
+
+func TestPollWithPollEvents(t *testing.T) {
+	homeserver := &fakeHomeserver{syncResponses: []string{
+		newSyncResponse("s1"),
+		newSyncResponse("s2", newMessageEvent("$1", "@alice:example.org", `!pollmulti "Which days work for you?" Mon Tue Wed`)),
+		newSyncResponse("s3", newPollResponseEvent("$2", "@bob:example.org", "$sent1", "0", "2"), newPollResponseEvent("$3", "@carol:example.org", "$sent1", "1")),
+		newSyncResponse("s4", newPollResponseEvent("$4", "@bob:example.org", "$sent1", "2")),
+		newSyncResponse("s5", fmt.Sprintf(`{"type":%q,"event_id":"$5","sender":"@alice:example.org","content":{"m.relates_to":{"rel_type":"m.reference","event_id":"$sent1"}}}`, EventTypePollEnd)),
+	}}
+	server := httptest.NewServer(homeserver)
+	defer server.Close()
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	syncAll(t, newTestBot(server.URL, store, true), "", "s1", "s2", "s3", "s4")
+	pollStart := homeserver.sentEvents[0]
+	pollID, _ := pollStart.Content["name.markusreschke.shcp.poll_id"].(string)
+	if pollStart.Type != EventTypePollStart || pollID == "" {
+		t.Fatalf("Expected poll start event but got %v", pollStart)
+	}
+	results, err := store.GetResult(pollID)
+	if err != nil {
+		t.Fatal("Error getting results: ", err)
+	}
+	if diff := deep.Equal(map[int]uint64{1: 1, 2: 1}, results); diff != nil {
+		t.Error("Poll responses weren't counted correctly: ", diff)
+	}
+	closedPoll, _ := store.GetPoll(pollID)
+	if !closedPoll.Closed || homeserver.lastSentEvent(t).Type != EventTypePollEnd {
+		t.Errorf("Expected poll to be closed by its creator but got %v and %v", closedPoll, homeserver.lastSentEvent(t))
+	}
+}
+
+func TestIgnoringReactionsToForeignEvents(t *testing.T) {
+	homeserver := &fakeHomeserver{syncResponses: []string{
+		newSyncResponse("s2", newReactionEvent("$2", "@bob:example.org", "$foreign", "1️⃣")),
+	}}
+	server := httptest.NewServer(homeserver)
+	defer server.Close()
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	store.AddPoll(poll.Poll{ID: "1", Question: "q", Options: poll.NewOptions("a1", "a2")})
+	syncAll(t, newTestBot(server.URL, store, false), "s1")
+	results, _ := store.GetResult("1")
+	if len(results) != 0 || len(homeserver.sentEvents) != 0 {
+		t.Errorf("Expected reaction to foreign event to be ignored but got results %v and events %v", results, homeserver.sentEvents)
+	}
+}
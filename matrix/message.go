@@ -0,0 +1,173 @@
+package matrix
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+const (
+	EventTypeMessage   = "m.room.message"
+	EventTypeReaction  = "m.reaction"
+	EventTypeRedaction = "m.room.redaction"
+	// Poll events as specified by MSC3381, which are still in the unstable
+	// namespace.
+	EventTypePollStart    = "org.matrix.msc3381.poll.start"
+	EventTypePollResponse = "org.matrix.msc3381.poll.response"
+	EventTypePollEnd      = "org.matrix.msc3381.poll.end"
+	pollKindDisclosed     = "org.matrix.msc3381.poll.disclosed"
+	textKey               = "org.matrix.msc1767.text"
+
+	// CloseReaction closes a poll when its creator reacts with it.
+	CloseReaction = "🔒"
+
+	deletedPollText = "This poll was deleted."
+)
+
+// OptionReactions are the reactions voting for the options of a poll, so
+// polls on Matrix can have at most as many options.
+var OptionReactions = []string{"1️⃣", "2️⃣", "3️⃣", "4️⃣", "5️⃣", "6️⃣", "7️⃣", "8️⃣", "9️⃣", "🔟"}
+
+type RelatesTo struct {
+	RelType string `json:"rel_type,omitempty"`
+	EventID string `json:"event_id,omitempty"`
+	Key     string `json:"key,omitempty"`
+}
+
+// MessageContent is the content of m.room.message events. Poll messages
+// carry the ID of their poll and edits the replaced content.
+type MessageContent struct {
+	MsgType    string          `json:"msgtype"`
+	Body       string          `json:"body"`
+	PollID     string          `json:"name.markusreschke.shcp.poll_id,omitempty"`
+	NewContent *MessageContent `json:"m.new_content,omitempty"`
+	RelatesTo  *RelatesTo      `json:"m.relates_to,omitempty"`
+}
+
+// RelationContent is the content of reactions, poll responses and poll ends.
+type RelationContent struct {
+	RelatesTo    RelatesTo `json:"m.relates_to"`
+	PollResponse *struct {
+		Answers []string `json:"answers"`
+	} `json:"org.matrix.msc3381.poll.response,omitempty"`
+	Redacts string `json:"redacts,omitempty"`
+}
+
+type PollStartContent struct {
+	PollStart PollStart `json:"org.matrix.msc3381.poll.start"`
+	Text      string    `json:"org.matrix.msc1767.text"`
+	PollID    string    `json:"name.markusreschke.shcp.poll_id"`
+}
+
+type PollStart struct {
+	Question      map[string]string `json:"question"`
+	Kind          string            `json:"kind"`
+	MaxSelections int               `json:"max_selections"`
+	Answers       []PollAnswer      `json:"answers"`
+}
+
+type PollEndContent struct {
+	RelatesTo RelatesTo         `json:"m.relates_to"`
+	PollEnd   map[string]string `json:"org.matrix.msc3381.poll.end"`
+	Text      string            `json:"org.matrix.msc1767.text"`
+}
+
+type PollAnswer struct {
+	ID   string `json:"id"`
+	Text string `json:"org.matrix.msc1767.text"`
+}
+
+// NewPollText creates the plain text of a poll message with the current
+// results.
+func NewPollText(p poll.Poll, results map[int]uint64) string {
+	var text bytes.Buffer
+	fmt.Fprintf(&text, "📊 %s\n", p.Question)
+	for index, option := range p.Options {
+		fmt.Fprintf(&text, "%s %s: %s\n", OptionReactions[index], option.Text(), formatVoteCount(results[option.ID]))
+	}
+	if p.Closed {
+		text.WriteString("This poll is closed.")
+		return text.String()
+	}
+	text.WriteString(votingHint(p))
+	return text.String()
+}
+
+func NewPollMessage(p poll.Poll, results map[int]uint64) MessageContent {
+	return MessageContent{MsgType: "m.text", Body: NewPollText(p, results), PollID: p.ID}
+}
+
+// NewPollEdit replaces the text of the poll message with the given event ID.
+func NewPollEdit(eventID string, p poll.Poll, results map[int]uint64) MessageContent {
+	newContent := NewPollMessage(p, results)
+	return MessageContent{MsgType: "m.text", Body: "* " + newContent.Body, NewContent: &newContent,
+		RelatesTo: &RelatesTo{RelType: "m.replace", EventID: eventID}}
+}
+
+// NewPollStart creates an MSC3381 poll whose answer IDs are the option IDs,
+// clients supporting it show the results themselves. Polls started before
+// options had IDs use the option indexes, which are the IDs of their options.
+func NewPollStart(p poll.Poll) PollStartContent {
+	maxSelections := 1
+	if p.Type == poll.PollTypeMultipleChoice {
+		maxSelections = len(p.Options)
+		if p.MaxChoices > 0 {
+			maxSelections = p.MaxChoices
+		}
+	}
+	content := PollStartContent{Text: NewPollText(p, nil), PollID: p.ID}
+	content.PollStart = PollStart{Question: map[string]string{textKey: p.Question}, Kind: pollKindDisclosed, MaxSelections: maxSelections}
+	for _, option := range p.Options {
+		content.PollStart.Answers = append(content.PollStart.Answers, PollAnswer{strconv.Itoa(option.ID), option.Text()})
+	}
+	return content
+}
+
+func NewPollEnd(eventID string) PollEndContent {
+	return PollEndContent{RelatesTo{RelType: "m.reference", EventID: eventID}, map[string]string{}, "This poll is closed."}
+}
+
+// NewDeletedPollEdit replaces the text of the message of a deleted poll.
+func NewDeletedPollEdit(eventID string) MessageContent {
+	newContent := MessageContent{MsgType: "m.text", Body: deletedPollText}
+	return MessageContent{MsgType: "m.text", Body: "* " + deletedPollText, NewContent: &newContent,
+		RelatesTo: &RelatesTo{RelType: "m.replace", EventID: eventID}}
+}
+
+// NewDeletedPollEnd ends a deleted poll event, which can't be replaced.
+func NewDeletedPollEnd(eventID string) PollEndContent {
+	return PollEndContent{RelatesTo{RelType: "m.reference", EventID: eventID}, map[string]string{}, deletedPollText}
+}
+
+func NewNotice(text string) MessageContent {
+	return MessageContent{MsgType: "m.notice", Body: text}
+}
+
+func votingHint(p poll.Poll) string {
+	if p.Type != poll.PollTypeMultipleChoice {
+		return "React with the number of an option to vote."
+	}
+	if p.MaxChoices > 0 {
+		return fmt.Sprintf("React with the numbers of up to %d options to vote.", p.MaxChoices)
+	}
+	return "React with the numbers of all options that apply to vote."
+}
+
+func formatVoteCount(voteCount uint64) string {
+	voteCountText := "Vote"
+	if voteCount != 1 {
+		voteCountText += "s"
+	}
+	return fmt.Sprintf("%d %s", voteCount, voteCountText)
+}
+
+func optionForReaction(key string) (int, bool) {
+	for index, reaction := range OptionReactions {
+		if reaction == key {
+			return index, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,108 @@
+package mattermost
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"markusreschke.name/selfhostedchatpolling/chat"
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+func TestMain(m *testing.M) {
+	flag.Bool("integration", false, "run integration tests")
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+func newTestPlatform() *Platform {
+	config := PlatformConfig{CommandTokens: []string{"token1", "token2"}, ActionURL: "https://polls.example.com/mattermost/actions", ActionToken: "secret"}
+	return NewPlatform(config, log.New(ioutil.Discard, "", 0))
+}
+
+func TestPollMessage(t *testing.T) {
+	dat, err := ioutil.ReadFile("exampleMattermostPollMessage.json")
+	if err != nil {
+		t.Fatal("Error reading sample file: ", err)
+	}
+	var expectedMessage Message
+	err = json.Unmarshal(dat, &expectedMessage)
+	if err != nil {
+		t.Fatal("Error parsing sample file: ", err)
+	}
+	testPoll := poll.Poll{ID: "6b57e603-2366-4116-b51d-011837677e33", Question: "Test Question", CreatorID: "foobar", Options: poll.NewOptions("Answer 1", "Answer 2"),
+		Type: poll.PollTypeMultipleChoice, MaxChoices: 2}
+	actualMessage := newTestPlatform().renderer.NewPollMessage(testPoll, map[int]uint64{0: 3, 1: 1})
+	if diff := deep.Equal(expectedMessage, actualMessage); diff != nil {
+		t.Error("Created message is not as expected: ", diff)
+	}
+}
+
+func TestParsingActionRequest(t *testing.T) {
+	testCases := []struct {
+		token          string
+		expectedOK     bool
+		expectedStatus int
+	}{
+		{"secret", true, http.StatusOK},
+		{"wrong", false, http.StatusUnauthorized},
+		{"", false, http.StatusUnauthorized},
+	}
+	for _, testCase := range testCases {
+		actionRequest := ActionRequest{UserID: "voter", PostID: "post", Context: ActionContext{ActionVote, "1", 1, testCase.token}}
+		body, _ := json.Marshal(actionRequest)
+		recorder := httptest.NewRecorder()
+		command, ok := newTestPlatform().ParseCommand(recorder, httptest.NewRequest(http.MethodPost, "/mattermost/actions", strings.NewReader(string(body))))
+		if ok != testCase.expectedOK || recorder.Code != testCase.expectedStatus {
+			t.Errorf("Expected %v and status %d for token %q but got %v and %d", testCase.expectedOK, testCase.expectedStatus, testCase.token, ok, recorder.Code)
+			continue
+		}
+		if ok && (command.Action != chat.ActionVote || command.UserID != "voter" || command.PollID != "1" || command.Option != 1 || !command.OptionIsPosition) {
+			t.Errorf("Unexpected command for vote action: %v", command)
+		}
+	}
+}
+
+func TestParsingSlashCommand(t *testing.T) {
+	testCases := []struct {
+		token          string
+		expectedOK     bool
+		expectedStatus int
+	}{
+		{"token1", true, http.StatusOK},
+		{"token2", true, http.StatusOK},
+		{"secret", false, http.StatusUnauthorized},
+	}
+	for _, testCase := range testCases {
+		form := url.Values{"token": {testCase.token}, "user_id": {"creator"}, "text": {`"Test Question" "Answer 1" "Answer 2"`}}
+		recorder := httptest.NewRecorder()
+		platform := newTestPlatform().SlashCommand(true, poll.PollTypeSingleChoice)
+		command, ok := platform.ParseCommand(recorder, httptest.NewRequest(http.MethodPost, "/mattermost/newpoll", strings.NewReader(form.Encode())))
+		if ok != testCase.expectedOK || recorder.Code != testCase.expectedStatus {
+			t.Errorf("Expected %v and status %d for token %q but got %v and %d", testCase.expectedOK, testCase.expectedStatus, testCase.token, ok, recorder.Code)
+			continue
+		}
+		expectedPoll := poll.Poll{Question: "Test Question", Options: poll.NewOptions("Answer 1", "Answer 2"), Anonymous: true}
+		if diff := deep.Equal(expectedPoll, command.Poll); ok && (command.Action != chat.ActionCreatePoll || command.UserID != "creator" || diff != nil) {
+			t.Errorf("Unexpected command for slash command: %v", command)
+		}
+	}
+}
+
+func TestRejectingDeadlines(t *testing.T) {
+	form := url.Values{"token": {"token1"}, "user_id": {"creator"}, "text": {`"Test Question" "Answer 1" "Answer 2" --closes 2h`}}
+	recorder := httptest.NewRecorder()
+	platform := newTestPlatform().SlashCommand(false, poll.PollTypeSingleChoice)
+	_, ok := platform.ParseCommand(recorder, httptest.NewRequest(http.MethodPost, "/mattermost/newpoll", strings.NewReader(form.Encode())))
+	if ok || !strings.Contains(recorder.Body.String(), "Deadlines aren't supported on Mattermost") {
+		t.Errorf("Expected poll with deadline to be rejected but got %v and %q", ok, recorder.Body.String())
+	}
+}
@@ -0,0 +1,189 @@
+package mattermost
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+const (
+	ResponseTypeInChannel = "in_channel"
+	ResponseTypeEphemeral = "ephemeral"
+	// ActionVoteForOption buttons hold the ID of the option. ActionVote
+	// buttons of messages sent before options had IDs hold its position.
+	ActionVoteForOption = "vote_option"
+	ActionVote          = "vote"
+	ActionShowDetails   = "details"
+	ActionRefresh       = "refresh"
+	ActionClosePoll     = "close"
+)
+
+// Message is the response to a slash command. Its attachments use the Slack
+// compatible attachment schema of Mattermost with integration actions.
+type Message struct {
+	ResponseType string       `json:"response_type,omitempty"`
+	Text         string       `json:"text,omitempty"`
+	Attachments  []Attachment `json:"attachments,omitempty"`
+}
+
+type Attachment struct {
+	Fallback string   `json:"fallback,omitempty"`
+	Color    string   `json:"color,omitempty"`
+	Text     string   `json:"text,omitempty"`
+	Footer   string   `json:"footer,omitempty"`
+	Actions  []Action `json:"actions,omitempty"`
+}
+
+type Action struct {
+	// ID may only contain letters and digits.
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Style       string      `json:"style,omitempty"`
+	Integration Integration `json:"integration"`
+}
+
+// Integration tells Mattermost where to send the ActionRequest when a button
+// is clicked.
+type Integration struct {
+	URL     string        `json:"url"`
+	Context ActionContext `json:"context"`
+}
+
+// ActionContext is sent back to the integration with every click. The token
+// proves that the action belongs to a message created by the integration.
+type ActionContext struct {
+	Action string `json:"action"`
+	PollID string `json:"poll_id"`
+	Option int    `json:"option,omitempty"`
+	Token  string `json:"token"`
+}
+
+type ActionRequest struct {
+	UserID    string        `json:"user_id"`
+	UserName  string        `json:"user_name"`
+	ChannelID string        `json:"channel_id"`
+	TeamID    string        `json:"team_id"`
+	PostID    string        `json:"post_id"`
+	TriggerID string        `json:"trigger_id"`
+	Context   ActionContext `json:"context"`
+}
+
+// ActionResponse updates the post of the clicked button and/or shows an
+// ephemeral message to the user who clicked it.
+type ActionResponse struct {
+	Update        *PostUpdate `json:"update,omitempty"`
+	EphemeralText string      `json:"ephemeral_text,omitempty"`
+}
+
+type PostUpdate struct {
+	Message string    `json:"message"`
+	Props   PostProps `json:"props"`
+}
+
+type PostProps struct {
+	Attachments []Attachment `json:"attachments"`
+}
+
+func (m *Message) ToJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+func (r *ActionResponse) ToJSON() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// MessageRenderer creates messages whose buttons send their actions to
+// ActionURL.
+type MessageRenderer struct {
+	ActionURL   string
+	ActionToken string
+}
+
+func (r MessageRenderer) newAction(id, name, action, pollID string, option int) Action {
+	return Action{ID: id, Name: name, Integration: Integration{r.ActionURL, ActionContext{action, pollID, option, r.ActionToken}}}
+}
+
+func (r MessageRenderer) NewPollMessage(p poll.Poll, results map[int]uint64) Message {
+	var msg Message
+	msg.ResponseType = ResponseTypeInChannel
+	msg.Text = "#### " + p.Question
+	if hint := votingHint(p); hint != "" && !p.Closed {
+		msg.Text += "\n" + hint
+	}
+	var resultText bytes.Buffer
+	for _, option := range p.Options {
+		fmt.Fprintf(&resultText, "**%s**: %s\n", option.Text(), formatVoteCount(results[option.ID]))
+	}
+	resultAttachment := Attachment{Fallback: p.Question, Text: strings.TrimSuffix(resultText.String(), "\n")}
+	if p.Closed {
+		resultAttachment.Color = "#808080"
+		resultAttachment.Footer = "This poll is closed."
+		if !p.Anonymous {
+			resultAttachment.Actions = append(resultAttachment.Actions, r.newAction("details", "Show vote details", ActionShowDetails, p.ID, 0))
+		}
+		msg.Attachments = append(msg.Attachments, resultAttachment)
+		return msg
+	}
+	resultAttachment.Color = "#0000ff"
+	for _, option := range p.Options {
+		resultAttachment.Actions = append(resultAttachment.Actions, r.newAction("vote"+strconv.Itoa(option.ID), option.Text(), ActionVoteForOption, p.ID, option.ID))
+	}
+	controlAttachment := Attachment{Fallback: p.Question}
+	if !p.Anonymous {
+		controlAttachment.Actions = append(controlAttachment.Actions, r.newAction("details", "Show vote details", ActionShowDetails, p.ID, 0))
+	}
+	closeAction := r.newAction("close", "Close poll", ActionClosePoll, p.ID, 0)
+	closeAction.Style = "danger"
+	controlAttachment.Actions = append(controlAttachment.Actions, r.newAction("refresh", "Refresh", ActionRefresh, p.ID, 0), closeAction)
+	msg.Attachments = append(msg.Attachments, resultAttachment, controlAttachment)
+	return msg
+}
+
+// NewPollUpdate replaces the poll post after a button was clicked.
+func (r MessageRenderer) NewPollUpdate(p poll.Poll, results map[int]uint64) ActionResponse {
+	msg := r.NewPollMessage(p, results)
+	return ActionResponse{Update: &PostUpdate{msg.Text, PostProps{msg.Attachments}}}
+}
+
+func NewVoteDetailText(results map[string][]string) string {
+	var options []string
+	for option := range results {
+		options = append(options, option)
+	}
+	sort.Strings(options)
+	var messageText bytes.Buffer
+	for _, option := range options {
+		fmt.Fprintf(&messageText, "- %s: %s\n", option, strings.Join(results[option], ", "))
+	}
+	return messageText.String()
+}
+
+func NewErrorMessage(message string) Message {
+	return Message{ResponseType: ResponseTypeEphemeral, Text: message}
+}
+
+func votingHint(p poll.Poll) string {
+	if p.Type != poll.PollTypeMultipleChoice {
+		return ""
+	}
+	if p.MaxChoices > 1 {
+		return fmt.Sprintf("_Select up to %d options, click again to remove a vote_", p.MaxChoices)
+	}
+	if p.MaxChoices == 1 {
+		return "_Select one option, click again to remove your vote_"
+	}
+	return "_Select all options that apply, click again to remove a vote_"
+}
+
+func formatVoteCount(voteCount uint64) string {
+	voteCountText := "Vote"
+	if voteCount != 1 {
+		voteCountText += "s"
+	}
+	return fmt.Sprintf("%d %s", voteCount, voteCountText)
+}
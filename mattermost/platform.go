@@ -0,0 +1,260 @@
+package mattermost
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"markusreschke.name/selfhostedchatpolling/chat"
+	"markusreschke.name/selfhostedchatpolling/poll"
+	"markusreschke.name/selfhostedchatpolling/slack"
+)
+
+const (
+	PlatformName          = "mattermost"
+	contentTypeJSON       = "application/json"
+	httpHeaderContentType = "Content-Type"
+)
+
+type PlatformConfig struct {
+	// CommandTokens are the tokens of all slash commands pointing to the
+	// integration, Mattermost creates one per slash command.
+	CommandTokens []string
+	// ActionURL is the public URL of the action endpoint and ActionToken a
+	// secret which is sent back by Mattermost with every button click.
+	ActionURL   string
+	ActionToken string
+	// ServerURL and BotToken are used to look up user names for vote details.
+	// Without them the details show user IDs.
+	ServerURL  string
+	BotToken   string
+	LogTraffic bool
+}
+
+// Platform is the chat.Platform for Mattermost. It parses the actions of poll
+// messages, slash commands are parsed by the platforms returned by
+// SlashCommand.
+type Platform struct {
+	config   PlatformConfig
+	renderer MessageRenderer
+	logger   *log.Logger
+}
+
+func NewPlatform(config PlatformConfig, logger *log.Logger) *Platform {
+	return &Platform{config, MessageRenderer{config.ActionURL, config.ActionToken}, logger}
+}
+
+func (p *Platform) Name() string {
+	return PlatformName
+}
+
+// SlashCommand returns a platform which creates polls of the given kind from
+// slash commands.
+func (p *Platform) SlashCommand(anonymous bool, pollType poll.PollType) chat.Platform {
+	return slashCommandPlatform{p, anonymous, pollType}
+}
+
+func isValidToken(token string, validTokens ...string) bool {
+	for _, validToken := range validTokens {
+		if validToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(validToken)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Platform) ParseCommand(writer http.ResponseWriter, request *http.Request) (chat.Command, bool) {
+	if request.Method != http.MethodPost {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		p.logger.Println("MethodNotAllowed")
+		return chat.Command{}, false
+	}
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Couldn't read body", err)
+		return chat.Command{}, false
+	}
+	if p.config.LogTraffic {
+		p.logger.Printf("Mattermost Action Request: %s\n", string(body))
+	}
+	var actionRequest ActionRequest
+	err = json.Unmarshal(body, &actionRequest)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Couldn't parse action request", err)
+		return chat.Command{}, false
+	}
+	if !isValidToken(actionRequest.Context.Token, p.config.ActionToken) {
+		writer.WriteHeader(http.StatusUnauthorized)
+		p.logger.Println("Unauthorized - Invalid action token")
+		return chat.Command{}, false
+	}
+	command := chat.Command{UserID: actionRequest.UserID, PollID: actionRequest.Context.PollID, Option: actionRequest.Context.Option, Origin: actionRequest}
+	switch actionRequest.Context.Action {
+	case ActionVoteForOption:
+		command.Action = chat.ActionVote
+	case ActionVote:
+		command.Action = chat.ActionVote
+		command.OptionIsPosition = true
+	case ActionShowDetails:
+		command.Action = chat.ActionShowDetails
+	case ActionRefresh:
+		command.Action = chat.ActionRefresh
+	case ActionClosePoll:
+		command.Action = chat.ActionClosePoll
+	default:
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Printf("BadRequest - Unknown action %q", actionRequest.Context.Action)
+		return chat.Command{}, false
+	}
+	return command, true
+}
+
+func (p *Platform) WriteReply(writer http.ResponseWriter, command chat.Command, reply chat.Reply) {
+	var response ActionResponse
+	switch reply.Kind {
+	case chat.ReplyPoll:
+		response = p.renderer.NewPollUpdate(reply.Poll, reply.Results)
+	case chat.ReplyVoteDetails:
+		err := p.resolveUserNames(reply.Details)
+		if err != nil {
+			p.logger.Println("Error getting user names from Mattermost API: ", err)
+			response.EphemeralText = "Error getting user names for voters!"
+			break
+		}
+		response.EphemeralText = NewVoteDetailText(reply.Details)
+	case chat.ReplyRankingForm:
+		response.EphemeralText = "Ranked choice polls aren't supported on Mattermost!"
+	default:
+		response.EphemeralText = reply.Text
+	}
+	writer.Header().Set(httpHeaderContentType, contentTypeJSON)
+	writer.WriteHeader(http.StatusOK)
+	responseJSON, _ := response.ToJSON()
+	writer.Write(responseJSON)
+}
+
+// resolveUserNames replaces the user IDs in the vote details with @-mentions
+// of the users, if the Mattermost API is configured.
+func (p *Platform) resolveUserNames(details map[string][]string) error {
+	if p.config.ServerURL == "" || p.config.BotToken == "" {
+		return nil
+	}
+	var userIDs []string
+	for _, voters := range details {
+		userIDs = append(userIDs, voters...)
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+	requestJSON, _ := json.Marshal(userIDs)
+	request, err := http.NewRequest(http.MethodPost, p.config.ServerURL+"/api/v4/users/ids", bytes.NewReader(requestJSON))
+	if err != nil {
+		return errors.Wrap(err, "Error creating request for users!")
+	}
+	request.Header.Set(httpHeaderContentType, contentTypeJSON)
+	request.Header.Set("Authorization", "Bearer "+p.config.BotToken)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "Error fetching users!")
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return errors.Errorf("Fetching users failed with status %s", response.Status)
+	}
+	var users []struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&users)
+	if err != nil {
+		return errors.Wrap(err, "Error reading users!")
+	}
+	userNames := make(map[string]string)
+	for _, user := range users {
+		userNames[user.ID] = "@" + user.Username
+	}
+	for option, voters := range details {
+		for i, voter := range voters {
+			if userName, ok := userNames[voter]; ok {
+				voters[i] = userName
+			}
+		}
+		details[option] = voters
+	}
+	return nil
+}
+
+// slashCommandPlatform parses slash commands for new polls. Its replies are
+// slash command responses instead of action responses.
+type slashCommandPlatform struct {
+	*Platform
+	anonymous bool
+	pollType  poll.PollType
+}
+
+func (p slashCommandPlatform) ParseCommand(writer http.ResponseWriter, request *http.Request) (chat.Command, bool) {
+	if request.Method != http.MethodPost {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		p.logger.Println("MethodNotAllowed")
+		return chat.Command{}, false
+	}
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Body couldn't be read!", err)
+		return chat.Command{}, false
+	}
+	if p.config.LogTraffic {
+		p.logger.Printf("Mattermost Poll Creation Request: %s\n", string(body))
+	}
+	parsedBody, err := url.ParseQuery(string(body))
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Body couldn't be parsed!", err)
+		return chat.Command{}, false
+	}
+	if !isValidToken(parsedBody.Get("token"), p.config.CommandTokens...) {
+		writer.WriteHeader(http.StatusUnauthorized)
+		p.logger.Println("Unauthorized - Invalid slash command token")
+		return chat.Command{}, false
+	}
+	newPoll, err := slack.ParsePollCommand(parsedBody.Get("text"), p.anonymous, p.pollType)
+	if err == nil && newPoll.HideResults {
+		err = errors.New("Hidden results aren't supported on Mattermost")
+	}
+	if err == nil && newPoll.AllowWriteIns {
+		err = errors.New("Write-ins aren't supported on Mattermost")
+	}
+	// Only Slack and Matrix update the message of polls closed by their deadline
+	if err == nil && !newPoll.Deadline.IsZero() {
+		err = errors.New("Deadlines aren't supported on Mattermost")
+	}
+	if err != nil {
+		p.logger.Println("Invalid poll command: ", err)
+		p.writeMessage(writer, NewErrorMessage(err.Error()))
+		return chat.Command{}, false
+	}
+	return chat.Command{Action: chat.ActionCreatePoll, UserID: parsedBody.Get("user_id"), Poll: newPoll, Origin: parsedBody}, true
+}
+
+func (p slashCommandPlatform) WriteReply(writer http.ResponseWriter, command chat.Command, reply chat.Reply) {
+	if reply.Kind != chat.ReplyPoll {
+		p.writeMessage(writer, NewErrorMessage(reply.Text))
+		return
+	}
+	p.writeMessage(writer, p.renderer.NewPollMessage(reply.Poll, reply.Results))
+}
+
+func (p slashCommandPlatform) writeMessage(writer http.ResponseWriter, msg Message) {
+	writer.Header().Set(httpHeaderContentType, contentTypeJSON)
+	writer.WriteHeader(http.StatusOK)
+	responseJSON, _ := msg.ToJSON()
+	writer.Write(responseJSON)
+}
@@ -1,7 +1,10 @@
 package cloudantstore
 
 import (
+	"encoding/json"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/IBM-Bluemix/go-cloudant"
 	"github.com/pkg/errors"
@@ -13,15 +16,38 @@ type CloudantStore struct {
 }
 
 const (
-	pollPrefix    = "poll_"
-	votePrefix    = "vote_"
-	deleteRetries = 3
+	pollPrefix     = "poll_"
+	votePrefix     = "vote_"
+	ballotPrefix   = "ballot_"
+	deleteRetries  = 3
+	replaceRetries = 5
+	updateRetries  = 5
 )
 
+// pollDocument is used to read polls together with their revision
+type pollDocument struct {
+	poll.Poll
+	Rev string `json:"_rev,omitempty"`
+}
+
+// voterBallot points to the current vote of a voter for a poll, or to all
+// selected votes in multiple choice polls. Its revision is what makes
+// ReplaceVote and ToggleVote safe against concurrent votes of the same voter.
+type voterBallot struct {
+	ID            string         `json:"_id"`
+	Rev           string         `json:"_rev,omitempty"`
+	CurrentVoteID string         `json:",omitempty"`
+	SelectedVotes map[string]int `json:",omitempty"`
+}
+
 func buildCloudantVoteId(voteId string) string {
 	return votePrefix + voteId
 }
 
+func buildCloudantBallotId(pollID, voterID string) string {
+	return ballotPrefix + pollID + "_" + voterID
+}
+
 func NewCloudantStoreBackend(client *cloudant.Client, dbName string) (poll.StoreBackend, error) {
 	db, err := client.CreateDB(dbName)
 	if err != nil {
@@ -30,11 +56,60 @@ func NewCloudantStoreBackend(client *cloudant.Client, dbName string) (poll.Store
 			return nil, errors.Wrap(err, "Error acessing cloudant db!")
 		}
 	}
-	return &CloudantStore{db}, nil
+	store := &CloudantStore{db}
+	err = store.migratePollOptions()
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// migratePollOptions converts the options of polls stored before options had
+// IDs from plain strings to options whose ID is their position, which is what
+// the votes of these polls refer to.
+func (s *CloudantStore) migratePollOptions() error {
+	query := cloudant.Query{}
+	query.Selector = make(map[string]interface{})
+	query.Selector["_id"] = map[string]interface{}{"$gt": pollPrefix, "$lt": pollPrefix + "\uffff"}
+	query.Selector["Options"] = map[string]interface{}{
+		"$elemMatch": map[string]interface{}{"$type": "string"},
+	}
+	rawPolls, err := s.db.SearchDocument(query)
+	if err != nil {
+		return errors.Wrap(err, "Error finding polls with options to migrate!")
+	}
+	for _, rawPoll := range rawPolls {
+		doc, ok := rawPoll.(map[string]interface{})
+		if !ok {
+			return errors.New("Error migrating poll options, unexpected query result!")
+		}
+		id, _ := doc["_id"].(string)
+		rev, _ := doc["_rev"].(string)
+		labels, _ := doc["Options"].([]interface{})
+		options := make([]poll.Option, len(labels))
+		for i, label := range labels {
+			text, _ := label.(string)
+			options[i] = poll.Option{ID: i, Label: text}
+		}
+		doc["Options"] = options
+		_, err = s.db.UpdateDocument(id, rev, doc)
+		if err != nil {
+			return errors.Wrapf(err, "Error migrating options of poll %s!", id)
+		}
+	}
+	return nil
+}
+
+// normalizeDeadline makes deadlines comparable as strings in Cloudant queries
+func normalizeDeadline(p *poll.Poll) {
+	if !p.Deadline.IsZero() {
+		p.Deadline = p.Deadline.UTC().Truncate(time.Second)
+	}
 }
 
 func (s *CloudantStore) AddPoll(p poll.Poll) error {
 	p.ID = pollPrefix + p.ID
+	normalizeDeadline(&p)
 	_, _, err := s.db.CreateDocument(p)
 	if err != nil {
 		return errors.Wrap(err, "Error creating document for poll!")
@@ -43,8 +118,12 @@ func (s *CloudantStore) AddPoll(p poll.Poll) error {
 }
 
 func (s *CloudantStore) AddVote(v poll.Vote) error {
+	err := s.checkPollExists(v.PollID)
+	if err != nil {
+		return err
+	}
 	v.ID = buildCloudantVoteId(v.ID)
-	_, _, err := s.db.CreateDocument(v)
+	_, _, err = s.db.CreateDocument(v)
 	if err != nil {
 		return errors.Wrap(err, "Error creating document for vote!")
 	}
@@ -65,12 +144,18 @@ func rebuildVotesFromSearchResult(votes []interface{}) ([]poll.Vote, error) {
 }
 
 func rebuildVoteFromMap(voteMap map[string]interface{}) (poll.Vote, error) {
-	return poll.Vote{
-		strings.TrimPrefix(voteMap["_id"].(string), votePrefix),
-		voteMap["VoterID"].(string),
-		voteMap["PollID"].(string),
-		int(voteMap["VotedFor"].(float64)),
-	}, nil
+	vote := poll.Vote{
+		ID:       strings.TrimPrefix(voteMap["_id"].(string), votePrefix),
+		VoterID:  voteMap["VoterID"].(string),
+		PollID:   voteMap["PollID"].(string),
+		VotedFor: int(voteMap["VotedFor"].(float64)),
+	}
+	if ranking, ok := voteMap["Ranking"].([]interface{}); ok {
+		for _, option := range ranking {
+			vote.Ranking = append(vote.Ranking, int(option.(float64)))
+		}
+	}
+	return vote, nil
 }
 
 func (s *CloudantStore) GetVotesForPoll(pollId string) ([]poll.Vote, error) {
@@ -85,13 +170,137 @@ func (s *CloudantStore) GetVotesForPoll(pollId string) ([]poll.Vote, error) {
 }
 
 func (s *CloudantStore) GetPoll(pollId string) (poll.Poll, error) {
-	var poll poll.Poll
-	err := s.db.GetDocument(pollPrefix+pollId, &poll, nil)
+	var p poll.Poll
+	err := s.db.GetDocument(pollPrefix+pollId, &p, nil)
+	if err != nil {
+		return p, s.pollError(pollId, err)
+	}
+	p.ID = strings.Replace(p.ID, pollPrefix, "", 1)
+	return p, err
+}
+
+// pollError wraps the error of reading a poll. The error doesn't tell whether
+// the poll is missing, so the poll is searched and ErrPollNotFound returned if
+// there is none.
+func (s *CloudantStore) pollError(pollId string, err error) error {
+	query := cloudant.Query{}
+	query.Selector = map[string]interface{}{"_id": pollPrefix + pollId}
+	polls, searchErr := s.searchPolls(query)
+	if searchErr == nil && len(polls) == 0 {
+		return errors.Wrapf(poll.ErrPollNotFound, "Poll %s doesn't exist", pollId)
+	}
+	return errors.Wrapf(err, "Error getting poll %s!", pollId)
+}
+
+// checkPollExists makes votes for deleted polls fail. Cloudant has no
+// transactions, so unlike the SQL stores a vote racing the deletion of its
+// poll can still be stored.
+func (s *CloudantStore) checkPollExists(pollId string) error {
+	var doc pollDocument
+	err := s.db.GetDocument(pollPrefix+pollId, &doc, nil)
+	if err != nil {
+		return s.pollError(pollId, err)
+	}
+	return nil
+}
+
+func (s *CloudantStore) UpdatePoll(pollId string, update func(p *poll.Poll) error) (poll.Poll, error) {
+	cloudantPollId := pollPrefix + pollId
+	var err error
+	for i := 1; i <= updateRetries; i += 1 {
+		var doc pollDocument
+		err = s.db.GetDocument(cloudantPollId, &doc, nil)
+		if err != nil {
+			return poll.Poll{}, s.pollError(pollId, err)
+		}
+		updatedPoll := doc.Poll
+		updatedPoll.ID = pollId
+		err = update(&updatedPoll)
+		if err != nil {
+			return poll.Poll{}, err
+		}
+		storedPoll := updatedPoll
+		storedPoll.ID = cloudantPollId
+		normalizeDeadline(&storedPoll)
+		// Fails if the poll was changed since it was read, the update is then retried
+		_, err = s.db.UpdateDocument(cloudantPollId, doc.Rev, storedPoll)
+		if err == nil {
+			normalizeDeadline(&updatedPoll)
+			return updatedPoll, nil
+		}
+	}
+	return poll.Poll{}, errors.Wrapf(err, "Error updating poll %s!", pollId)
+}
+
+func (s *CloudantStore) GetPollsWithExpiredDeadline(now time.Time) ([]poll.Poll, error) {
+	query := cloudant.Query{}
+	query.Selector = make(map[string]interface{})
+	query.Selector["_id"] = map[string]interface{}{"$gt": pollPrefix, "$lt": pollPrefix + "\uffff"}
+	query.Selector["Closed"] = false
+	query.Selector["Deadline"] = map[string]interface{}{
+		"$gt":  time.Time{}.Format(time.RFC3339),
+		"$lte": now.UTC().Format(time.RFC3339),
+	}
+	expiredPolls, err := s.searchPolls(query)
+	return expiredPolls, errors.Wrap(err, "Error finding polls with expired deadline!")
+}
+
+func (s *CloudantStore) GetPollsByCreator(creatorID string) ([]poll.Poll, error) {
+	query := cloudant.Query{}
+	query.Selector = make(map[string]interface{})
+	query.Selector["_id"] = map[string]interface{}{"$gt": pollPrefix, "$lt": pollPrefix + "\uffff"}
+	query.Selector["CreatorID"] = creatorID
+	createdPolls, err := s.searchPolls(query)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error finding polls of creator %s!", creatorID)
+	}
+	sort.Slice(createdPolls, func(i, j int) bool { return createdPolls[i].CreatedAt.After(createdPolls[j].CreatedAt) })
+	return createdPolls, nil
+}
+
+// GetPollsCreatedBefore compares the creation times as RFC 3339 strings like
+// GetPollsWithExpiredDeadline. Polls without creation time have the zero
+// time, which is excluded.
+func (s *CloudantStore) GetPollsCreatedBefore(cutoff time.Time) ([]poll.Poll, error) {
+	query := cloudant.Query{}
+	query.Selector = make(map[string]interface{})
+	query.Selector["_id"] = map[string]interface{}{"$gt": pollPrefix, "$lt": pollPrefix + "\uffff"}
+	query.Selector["CreatedAt"] = map[string]interface{}{
+		"$gt": time.Time{}.Format(time.RFC3339),
+		"$lt": cutoff.UTC().Format(time.RFC3339),
+	}
+	oldPolls, err := s.searchPolls(query)
+	return oldPolls, errors.Wrap(err, "Error finding polls created before cutoff!")
+}
+
+func (s *CloudantStore) searchPolls(query cloudant.Query) ([]poll.Poll, error) {
+	rawPolls, err := s.db.SearchDocument(query)
+	if err != nil {
+		return nil, err
+	}
+	polls := []poll.Poll{}
+	for _, rawPoll := range rawPolls {
+		p, err := rebuildPollFromSearchResult(rawPoll)
+		if err != nil {
+			return nil, err
+		}
+		polls = append(polls, p)
+	}
+	return polls, nil
+}
+
+func rebuildPollFromSearchResult(rawPoll interface{}) (poll.Poll, error) {
+	var p poll.Poll
+	pollJSON, err := json.Marshal(rawPoll)
+	if err != nil {
+		return p, errors.Wrap(err, "Error recreating poll from query result!")
+	}
+	err = json.Unmarshal(pollJSON, &p)
 	if err != nil {
-		return poll, errors.Wrapf(err, "Error getting poll %s!", pollId)
+		return p, errors.Wrap(err, "Error recreating poll from query result!")
 	}
-	poll.ID = strings.Replace(poll.ID, pollPrefix, "", 1)
-	return poll, err
+	p.ID = strings.TrimPrefix(p.ID, pollPrefix)
+	return p, nil
 }
 
 func (s *CloudantStore) GetVote(voteId string) (poll.Vote, error) {
@@ -125,20 +334,341 @@ func (s *CloudantStore) PollHasVoteFromVoter(pollID, voterID string) (bool, poll
 }
 
 func (s *CloudantStore) RemoveVote(voteId string) error {
-	cloudantVoteId := buildCloudantVoteId(voteId)
+	err := s.deleteDocument(buildCloudantVoteId(voteId))
+	if err != nil {
+		return errors.Wrapf(err, "Error deleting vote %s", voteId)
+	}
+	return nil
+}
+
+// RemoveVotesOfVoter deletes the ballot of the voter before the votes, so
+// ReplaceVote and ToggleVote don't refer to deleted votes afterwards.
+func (s *CloudantStore) RemoveVotesOfVoter(pollID, voterID string) error {
+	ballotId := buildCloudantBallotId(pollID, voterID)
+	var ballot voterBallot
+	if s.db.GetDocument(ballotId, &ballot, nil) == nil && ballot.Rev != "" {
+		err := s.deleteDocument(ballotId)
+		if err != nil {
+			return errors.Wrapf(err, "Error deleting ballot %s", ballotId)
+		}
+	}
+	voteIds, err := s.getVoteIdsFromVoter(pollID, voterID)
+	if err != nil {
+		return err
+	}
+	for _, voteId := range voteIds {
+		err = s.RemoveVote(voteId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteDocument deletes the current revision of a document. It is retried
+// if the document is changed concurrently.
+func (s *CloudantStore) deleteDocument(id string) error {
 	var err error = nil
 	for i := 1; i <= deleteRetries; i += 1 {
-		rev, err := s.db.GetDocumentRev(cloudantVoteId)
+		var rev string
+		rev, err = s.db.GetDocumentRev(id)
 		if err != nil {
 			continue
 		}
-		_, err = s.db.DeleteDocument(cloudantVoteId, rev)
+		_, err = s.db.DeleteDocument(id, rev)
 		if err == nil {
 			break
 		}
 	}
+	return err
+}
+
+// DeletePoll deletes the votes and ballots of the poll before the poll
+// itself, so a failed deletion can be repeated.
+func (s *CloudantStore) DeletePoll(pollId string) error {
+	query := cloudant.Query{}
+	query.Selector = make(map[string]interface{})
+	query.Selector["PollID"] = pollId
+	rawVotes, err := s.db.SearchDocument(query)
 	if err != nil {
-		return errors.Wrapf(err, "Error deleting vote %s", voteId)
+		return errors.Wrapf(err, "Error finding votes of poll %s!", pollId)
+	}
+	votes, err := rebuildVotesFromSearchResult(rawVotes)
+	if err != nil {
+		return err
+	}
+	for _, vote := range votes {
+		err = s.RemoveVote(vote.ID)
+		if err != nil {
+			return err
+		}
+	}
+	ballots, err := s.getBallotsOfPoll(pollId)
+	if err != nil {
+		return err
+	}
+	for _, ballot := range ballots {
+		err = s.deleteDocument(ballot.ID)
+		if err != nil {
+			return errors.Wrapf(err, "Error deleting ballot %s", ballot.ID)
+		}
+	}
+	err = s.deleteDocument(pollPrefix + pollId)
+	if err != nil {
+		return errors.Wrapf(err, "Error deleting poll %s", pollId)
 	}
 	return nil
 }
+
+// RemoveOptions updates the poll and removes the options from it in one
+// document update before removing their votes and the ballots pointing to
+// them. Unlike the other backends the removal of the votes isn't atomic, a
+// failed removal leaves votes for unknown options behind, which are ignored.
+func (s *CloudantStore) RemoveOptions(pollId string, update func(p *poll.Poll) error, removedOptions []int) (poll.Poll, error) {
+	updatedPoll, err := s.UpdatePoll(pollId, func(p *poll.Poll) error {
+		err := update(p)
+		if err != nil {
+			return err
+		}
+		poll.RemoveOptionsFromPoll(p, removedOptions)
+		return nil
+	})
+	if err != nil {
+		return poll.Poll{}, err
+	}
+	votes, err := s.GetVotesForPoll(pollId)
+	if err != nil {
+		return poll.Poll{}, err
+	}
+	removedVotes := make(map[string]bool)
+	for _, vote := range votes {
+		if !poll.RemoveOptionsFromVote(&vote, removedOptions) {
+			removedVotes[vote.ID] = true
+			err = s.RemoveVote(vote.ID)
+		} else if vote.Ranking != nil {
+			err = s.updateVote(vote)
+		}
+		if err != nil {
+			return poll.Poll{}, err
+		}
+	}
+	ballots, err := s.getBallotsOfPoll(pollId)
+	if err != nil {
+		return poll.Poll{}, err
+	}
+	for _, ballot := range ballots {
+		if removedVotes[ballot.CurrentVoteID] {
+			err = s.deleteDocument(ballot.ID)
+			if err != nil {
+				return poll.Poll{}, errors.Wrapf(err, "Error deleting ballot %s", ballot.ID)
+			}
+			continue
+		}
+		newBallot := voterBallot{ID: ballot.ID, SelectedVotes: make(map[string]int)}
+		for voteId, votedFor := range ballot.SelectedVotes {
+			if !removedVotes[voteId] {
+				newBallot.SelectedVotes[voteId] = votedFor
+			}
+		}
+		if len(newBallot.SelectedVotes) == len(ballot.SelectedVotes) {
+			continue
+		}
+		_, err = s.db.UpdateDocument(ballot.ID, ballot.Rev, newBallot)
+		if err != nil {
+			return poll.Poll{}, errors.Wrapf(err, "Error updating ballot %s", ballot.ID)
+		}
+	}
+	return updatedPoll, nil
+}
+
+func (s *CloudantStore) updateVote(v poll.Vote) error {
+	cloudantVoteId := buildCloudantVoteId(v.ID)
+	rev, err := s.db.GetDocumentRev(cloudantVoteId)
+	if err != nil {
+		return errors.Wrapf(err, "Error getting vote %s!", v.ID)
+	}
+	storedVote := v
+	storedVote.ID = cloudantVoteId
+	_, err = s.db.UpdateDocument(cloudantVoteId, rev, storedVote)
+	return errors.Wrapf(err, "Error updating vote %s!", v.ID)
+}
+
+func (s *CloudantStore) getBallotsOfPoll(pollId string) ([]voterBallot, error) {
+	query := cloudant.Query{}
+	query.Selector = make(map[string]interface{})
+	ballotIdPrefix := buildCloudantBallotId(pollId, "")
+	query.Selector["_id"] = map[string]interface{}{"$gt": ballotIdPrefix, "$lt": ballotIdPrefix + "\uffff"}
+	rawBallots, err := s.db.SearchDocument(query)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error finding ballots of poll %s!", pollId)
+	}
+	var ballots []voterBallot
+	ballotsJSON, err := json.Marshal(rawBallots)
+	if err == nil {
+		err = json.Unmarshal(ballotsJSON, &ballots)
+	}
+	return ballots, errors.Wrapf(err, "Error recreating ballots of poll %s from query result!", pollId)
+}
+
+// ReplaceVote stores v and then moves the voter's ballot document to it. The
+// ballot is only written with the revision it was read with, so of two
+// concurrent calls one fails, removes its vote again and retries against the
+// new ballot. The vote displaced by a successful ballot update is removed
+// afterwards.
+func (s *CloudantStore) ReplaceVote(v poll.Vote) error {
+	_, err := s.replaceVote(v, false)
+	return err
+}
+
+// ToggleSingleVote works like ReplaceVote, but if the current vote of the
+// voter is for the same option the ballot is emptied instead, which
+// retracts the vote.
+func (s *CloudantStore) ToggleSingleVote(v poll.Vote) (bool, error) {
+	return s.replaceVote(v, true)
+}
+
+func (s *CloudantStore) replaceVote(v poll.Vote, retractSameOption bool) (bool, error) {
+	err := s.checkPollExists(v.PollID)
+	if err != nil {
+		return false, err
+	}
+	ballotId := buildCloudantBallotId(v.PollID, v.VoterID)
+	for i := 1; i <= replaceRetries; i += 1 {
+		var ballot voterBallot
+		var displacedVoteIds []string
+		if s.db.GetDocument(ballotId, &ballot, nil) == nil && ballot.Rev != "" {
+			if ballot.CurrentVoteID != "" {
+				displacedVoteIds = []string{ballot.CurrentVoteID}
+			}
+		} else {
+			// Votes stored before ballots were introduced have no ballot yet
+			ballot.Rev = ""
+			displacedVoteIds, err = s.getVoteIdsFromVoter(v.PollID, v.VoterID)
+			if err != nil {
+				return false, err
+			}
+		}
+		retract := false
+		if retractSameOption {
+			retract, err = s.votesAreFor(displacedVoteIds, v.VotedFor)
+			if err != nil {
+				return false, err
+			}
+		}
+		newBallot := voterBallot{ID: ballotId}
+		if !retract {
+			err = s.AddVote(v)
+			if err != nil {
+				return false, err
+			}
+			newBallot.CurrentVoteID = v.ID
+		}
+		if ballot.Rev == "" {
+			_, _, err = s.db.CreateDocument(newBallot)
+		} else {
+			_, err = s.db.UpdateDocument(ballotId, ballot.Rev, newBallot)
+		}
+		if err != nil {
+			if !retract {
+				s.RemoveVote(v.ID)
+			}
+			continue
+		}
+		for _, displacedVoteId := range displacedVoteIds {
+			if displacedVoteId != v.ID {
+				err = s.RemoveVote(displacedVoteId)
+				if err != nil {
+					return false, err
+				}
+			}
+		}
+		return !retract, nil
+	}
+	return false, errors.Wrapf(err, "Error replacing vote of voter %s for poll %s!", v.VoterID, v.PollID)
+}
+
+// votesAreFor reports whether the voter has a vote and all given votes are
+// for the option.
+func (s *CloudantStore) votesAreFor(voteIds []string, option int) (bool, error) {
+	for _, voteId := range voteIds {
+		vote, err := s.GetVote(voteId)
+		if err != nil {
+			return false, err
+		}
+		if vote.VotedFor != option {
+			return false, nil
+		}
+	}
+	return len(voteIds) > 0, nil
+}
+
+// ToggleVote works like ReplaceVote, but keeps all selected votes of the
+// voter in the ballot document.
+func (s *CloudantStore) ToggleVote(v poll.Vote, maxChoices int) (bool, error) {
+	err := s.checkPollExists(v.PollID)
+	if err != nil {
+		return false, err
+	}
+	ballotId := buildCloudantBallotId(v.PollID, v.VoterID)
+	for i := 1; i <= replaceRetries; i += 1 {
+		var ballot voterBallot
+		if s.db.GetDocument(ballotId, &ballot, nil) != nil {
+			ballot = voterBallot{}
+		}
+		newBallot := voterBallot{ID: ballotId, SelectedVotes: make(map[string]int)}
+		removedVoteId := ""
+		for voteId, votedFor := range ballot.SelectedVotes {
+			if votedFor == v.VotedFor {
+				removedVoteId = voteId
+			} else {
+				newBallot.SelectedVotes[voteId] = votedFor
+			}
+		}
+		if removedVoteId == "" {
+			if maxChoices > 0 && len(ballot.SelectedVotes) >= maxChoices {
+				return false, poll.ErrTooManyChoices
+			}
+			err = s.AddVote(v)
+			if err != nil {
+				return false, err
+			}
+			newBallot.SelectedVotes[v.ID] = v.VotedFor
+		}
+		if ballot.Rev == "" {
+			_, _, err = s.db.CreateDocument(newBallot)
+		} else {
+			_, err = s.db.UpdateDocument(ballotId, ballot.Rev, newBallot)
+		}
+		if err != nil {
+			if removedVoteId == "" {
+				s.RemoveVote(v.ID)
+			}
+			continue
+		}
+		if removedVoteId != "" {
+			return false, s.RemoveVote(removedVoteId)
+		}
+		return true, nil
+	}
+	return false, errors.Wrapf(err, "Error toggling vote of voter %s for poll %s!", v.VoterID, v.PollID)
+}
+
+func (s *CloudantStore) getVoteIdsFromVoter(pollID, voterID string) ([]string, error) {
+	query := cloudant.Query{}
+	query.Selector = make(map[string]interface{})
+	query.Selector["PollID"] = pollID
+	query.Selector["VoterID"] = voterID
+	rawVotes, err := s.db.SearchDocument(query)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error searching votes from voter %s for poll %s!", voterID, pollID)
+	}
+	votes, err := rebuildVotesFromSearchResult(rawVotes)
+	if err != nil {
+		return nil, err
+	}
+	voteIds := make([]string, 0, len(votes))
+	for _, vote := range votes {
+		voteIds = append(voteIds, vote.ID)
+	}
+	return voteIds, nil
+}
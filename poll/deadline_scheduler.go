@@ -0,0 +1,43 @@
+package poll
+
+import (
+	"log"
+	"time"
+)
+
+// DeadlineScheduler periodically closes polls whose deadline has passed and
+// hands each of them to onClose, e.g. to update the poll message in the chat.
+type DeadlineScheduler struct {
+	store    Store
+	interval time.Duration
+	logger   *log.Logger
+	onClose  func(p Poll)
+}
+
+func NewDeadlineScheduler(store Store, interval time.Duration, logger *log.Logger, onClose func(p Poll)) *DeadlineScheduler {
+	return &DeadlineScheduler{store, interval, logger, onClose}
+}
+
+// Run checks for expired polls every interval until stop is closed.
+func (d *DeadlineScheduler) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			d.closeExpiredPolls(now)
+		}
+	}
+}
+
+func (d *DeadlineScheduler) closeExpiredPolls(now time.Time) {
+	closedPolls, err := d.store.CloseExpiredPolls(now)
+	if err != nil {
+		d.logger.Println("Error closing expired polls: ", err)
+	}
+	for _, closedPoll := range closedPolls {
+		d.onClose(closedPoll)
+	}
+}
@@ -2,6 +2,9 @@ package poll
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -13,44 +16,92 @@ type DefaultStore struct {
 var (
 	ErrNoDetailsForAnonymousPoll = errors.New("Can't fetch vote details for anonymous polls!")
 	ErrInvalidChoice             = errors.New("Invalid option choice!")
+	ErrPollClosed                = errors.New("Poll is closed!")
+	ErrNotPollCreator            = errors.New("Only the creator of a poll can do this!")
+	ErrTooManyChoices            = errors.New("Maximum number of choices reached!")
+	ErrWriteInsDisabled          = errors.New("Poll doesn't allow write-ins!")
+	ErrPollNotFound              = errors.New("Poll not found!")
 )
 
 func NewDefaultStore(backend StoreBackend) Store {
 	return &DefaultStore{backend}
 }
 
+// AddPoll stores a new poll if it passes Validate, otherwise the
+// *ValidationError is returned.
 func (s *DefaultStore) AddPoll(p Poll) error {
+	err := Validate(p)
+	if err != nil {
+		return err
+	}
 	return s.backend.AddPoll(p)
 }
 
+// AddVote stores the vote of a voter for an open poll. Single choice polls
+// keep the latest vote of every voter, voting for the selected option again
+// retracts the vote. Multiple choice polls toggle the vote for the option and
+// ranked choice polls replace the ballot of the voter.
 func (s *DefaultStore) AddVote(v Vote) error {
-	isValidChoice, err := s.votedForValidOption(v)
+	return s.addVote(v, true)
+}
+
+// ReplaceVote stores the vote like AddVote, but never retracts the vote of a
+// single choice poll. It is used by platforms whose votes are state, like
+// reactions on Matrix, which can be repeated without meaning a retraction.
+func (s *DefaultStore) ReplaceVote(v Vote) error {
+	return s.addVote(v, false)
+}
+
+func (s *DefaultStore) addVote(v Vote, toggleSingleChoice bool) error {
+	pollForVote, err := s.backend.GetPoll(v.PollID)
 	if err != nil {
 		return err
 	}
-	if !isValidChoice {
-		return errors.Wrap(ErrInvalidChoice, fmt.Sprintf("Voter %s voted for invalid choice %s", v.VoterID, v.VotedFor))
+	if !pollForVote.IsOpen(time.Now()) {
+		return errors.Wrapf(ErrPollClosed, "Voter %s voted for closed poll %s", v.VoterID, v.PollID)
 	}
-	hasVotedAlready, previousVote, err := s.backend.PollHasVoteFromVoter(v.PollID, v.VoterID)
-	if err != nil {
+	if pollForVote.Type == PollTypeRankedChoice {
+		if !isValidRanking(pollForVote, v.Ranking) {
+			return errors.Wrap(ErrInvalidChoice, fmt.Sprintf("Voter %s submitted invalid ranking %v", v.VoterID, v.Ranking))
+		}
+		v.VotedFor = v.Ranking[0]
+	} else {
+		v.Ranking = nil
+	}
+	if !votedForValidOption(pollForVote, v) {
+		return errors.Wrap(ErrInvalidChoice, fmt.Sprintf("Voter %s voted for invalid choice %d", v.VoterID, v.VotedFor))
+	}
+	if pollForVote.Type == PollTypeMultipleChoice {
+		_, err = s.backend.ToggleVote(v, pollForVote.MaxChoices)
 		return err
 	}
-	if hasVotedAlready {
-		err := s.backend.RemoveVote(previousVote.ID)
-		if err != nil {
-			return err
-		}
+	if pollForVote.Type == PollTypeSingleChoice && toggleSingleChoice {
+		// Voting for the selected option again retracts the vote
+		_, err = s.backend.ToggleSingleVote(v)
+		return err
 	}
-	return s.backend.AddVote(v)
+	return s.backend.ReplaceVote(v)
 }
 
-func (s *DefaultStore) votedForValidOption(v Vote) (bool, error) {
-	pollForVote, err := s.backend.GetPoll(v.PollID)
-	if err != nil {
-		return false, err
+func votedForValidOption(pollForVote Poll, v Vote) bool {
+	_, found := pollForVote.FindOption(v.VotedFor)
+	return found
+}
+
+// isValidRanking checks that a ranking contains at least one option and only
+// valid options, each at most once. Options may be left unranked.
+func isValidRanking(pollForVote Poll, ranking []int) bool {
+	if len(ranking) == 0 {
+		return false
+	}
+	isRanked := make(map[int]bool)
+	for _, option := range ranking {
+		if _, found := pollForVote.FindOption(option); !found || isRanked[option] {
+			return false
+		}
+		isRanked[option] = true
 	}
-	isValidChoice := v.VotedFor >= 0 && v.VotedFor < len(pollForVote.Options)
-	return isValidChoice, nil
+	return true
 }
 
 func (s *DefaultStore) GetResult(pollId string) (map[int]uint64, error) {
@@ -65,6 +116,52 @@ func (s *DefaultStore) GetResult(pollId string) (map[int]uint64, error) {
 	return result, nil
 }
 
+// GetParticipantCount counts the distinct voters of a poll, which differs from
+// the number of votes for multiple choice polls.
+func (s *DefaultStore) GetParticipantCount(pollId string) (int, error) {
+	votes, err := s.backend.GetVotesForPoll(pollId)
+	if err != nil {
+		return 0, err
+	}
+	voters := make(map[string]bool)
+	for _, vote := range votes {
+		voters[vote.VoterID] = true
+	}
+	return len(voters), nil
+}
+
+// GetVoterChoices returns the options voterID voted for. Ranked choice polls
+// return the ranking of the voter, multiple choice polls the selected options
+// in ascending order. An empty slice is returned if the voter didn't vote.
+func (s *DefaultStore) GetVoterChoices(pollId, voterID string) ([]int, error) {
+	pollForId, err := s.backend.GetPoll(pollId)
+	if err != nil {
+		return nil, err
+	}
+	if pollForId.Type != PollTypeMultipleChoice {
+		hasVote, vote, err := s.backend.PollHasVoteFromVoter(pollId, voterID)
+		if err != nil || !hasVote {
+			return []int{}, err
+		}
+		if pollForId.Type == PollTypeRankedChoice {
+			return vote.Ranking, nil
+		}
+		return []int{vote.VotedFor}, nil
+	}
+	votes, err := s.backend.GetVotesForPoll(pollId)
+	if err != nil {
+		return nil, err
+	}
+	choices := []int{}
+	for _, vote := range votes {
+		if vote.VoterID == voterID {
+			choices = append(choices, vote.VotedFor)
+		}
+	}
+	sort.Ints(choices)
+	return choices, nil
+}
+
 func (s *DefaultStore) GetVoteDetails(pollId string) (map[string][]string, error) {
 	result := make(map[string][]string)
 	pollForId, err := s.backend.GetPoll(pollId)
@@ -78,16 +175,34 @@ func (s *DefaultStore) GetVoteDetails(pollId string) (map[string][]string, error
 	if err != nil {
 		return nil, err
 	}
-	for _, optionName := range pollForId.Options {
-		result[optionName] = []string{}
+	for _, option := range pollForId.Options {
+		result[option.Label] = []string{}
 	}
 	for _, vote := range votes {
-		optionName := pollForId.Options[vote.VotedFor]
-		result[optionName] = append(result[optionName], vote.VoterID)
+		// Votes for unknown options are ignored instead of failing the details
+		if option, found := pollForId.FindOption(vote.VotedFor); found {
+			result[option.Label] = append(result[option.Label], vote.VoterID)
+		}
 	}
 	return result, nil
 }
 
+func (s *DefaultStore) GetRunoffResult(pollId string) (RunoffResult, error) {
+	pollForId, err := s.backend.GetPoll(pollId)
+	if err != nil {
+		return RunoffResult{}, err
+	}
+	votes, err := s.backend.GetVotesForPoll(pollId)
+	if err != nil {
+		return RunoffResult{}, err
+	}
+	ballots := make([][]int, 0, len(votes))
+	for _, vote := range votes {
+		ballots = append(ballots, vote.Ranking)
+	}
+	return RunInstantRunoff(pollForId.OptionIDs(), ballots), nil
+}
+
 func (s *DefaultStore) GetPoll(pollId string) (Poll, error) {
 	return s.backend.GetPoll(pollId)
 }
@@ -95,3 +210,175 @@ func (s *DefaultStore) GetPoll(pollId string) (Poll, error) {
 func (s *DefaultStore) GetVote(voteId string) (Vote, error) {
 	return s.backend.GetVote(voteId)
 }
+
+func (s *DefaultStore) ClosePoll(pollId, userID string) (Poll, error) {
+	return s.backend.UpdatePoll(pollId, func(p *Poll) error {
+		if p.CreatorID != userID {
+			return errors.Wrapf(ErrNotPollCreator, "User %s tried to close poll %s", userID, pollId)
+		}
+		p.Closed = true
+		return nil
+	})
+}
+
+// CloseExpiredPolls closes all polls whose deadline has passed and returns
+// them in their closed state. Polls which were closed or deleted since they
+// were found aren't returned, so every poll is only reported once.
+func (s *DefaultStore) CloseExpiredPolls(now time.Time) ([]Poll, error) {
+	expiredPolls, err := s.backend.GetPollsWithExpiredDeadline(now)
+	if err != nil {
+		return nil, err
+	}
+	closedPolls := make([]Poll, 0, len(expiredPolls))
+	for _, expiredPoll := range expiredPolls {
+		closedPoll, err := s.backend.UpdatePoll(expiredPoll.ID, func(p *Poll) error {
+			if p.Closed {
+				return errors.Wrapf(ErrPollClosed, "Expired poll %s was already closed", p.ID)
+			}
+			p.Closed = true
+			return nil
+		})
+		if errors.Cause(err) == ErrPollClosed || errors.Cause(err) == ErrPollNotFound {
+			continue
+		}
+		if err != nil {
+			return closedPolls, errors.Wrapf(err, "Error closing expired poll %s", expiredPoll.ID)
+		}
+		closedPolls = append(closedPolls, closedPoll)
+	}
+	return closedPolls, nil
+}
+
+// RetractVote removes all votes of a voter for an open poll.
+func (s *DefaultStore) RetractVote(pollId, voterID string) error {
+	pollForVote, err := s.backend.GetPoll(pollId)
+	if err != nil {
+		return err
+	}
+	if !pollForVote.IsOpen(time.Now()) {
+		return errors.Wrapf(ErrPollClosed, "Voter %s retracted vote for closed poll %s", voterID, pollId)
+	}
+	return s.backend.RemoveVotesOfVoter(pollId, voterID)
+}
+
+func (s *DefaultStore) SetResponseURL(pollId, responseURL string) error {
+	_, err := s.backend.UpdatePoll(pollId, func(p *Poll) error {
+		p.ResponseURL = responseURL
+		return nil
+	})
+	return err
+}
+
+func (s *DefaultStore) GetPollsByCreator(creatorID string) ([]Poll, error) {
+	return s.backend.GetPollsByCreator(creatorID)
+}
+
+// EditPoll changes the question and options of an open poll. Only the creator
+// of the poll can edit it. The edited poll has to pass Validate, otherwise the
+// *ValidationError is returned and nothing is changed. New options get IDs
+// above the IDs of all current and removed options.
+func (s *DefaultStore) EditPoll(pollId, userID string, edit PollEdit) (Poll, error) {
+	update := func(p *Poll) error {
+		if p.CreatorID != userID {
+			return errors.Wrapf(ErrNotPollCreator, "User %s tried to edit poll %s", userID, pollId)
+		}
+		if !p.IsOpen(time.Now()) {
+			return errors.Wrapf(ErrPollClosed, "User %s tried to edit closed poll %s", userID, pollId)
+		}
+		for i, option := range edit.RemovedOptions {
+			if _, found := p.FindOption(option); !found || containsOption(edit.RemovedOptions[:i], option) {
+				return errors.Wrapf(ErrInvalidChoice, "Edit of poll %s removes invalid option %d", pollId, option)
+			}
+		}
+		for option := range edit.Labels {
+			if _, found := p.FindOption(option); !found {
+				return errors.Wrapf(ErrInvalidChoice, "Edit of poll %s renames invalid option %d", pollId, option)
+			}
+		}
+		editedOptions := make([]Option, len(p.Options))
+		for i, option := range p.Options {
+			if label, isRenamed := edit.Labels[option.ID]; isRenamed {
+				option.Label = label
+			}
+			editedOptions[i] = option
+		}
+		p.Question = edit.Question
+		p.Options = editedOptions
+		for _, label := range edit.NewOptions {
+			p.AddOption(label)
+		}
+		result := *p
+		RemoveOptionsFromPoll(&result, edit.RemovedOptions)
+		return Validate(result)
+	}
+	if len(edit.RemovedOptions) == 0 {
+		return s.backend.UpdatePoll(pollId, update)
+	}
+	return s.backend.RemoveOptions(pollId, update, edit.RemovedOptions)
+}
+
+// AddWriteIn appends an option proposed by a participant to an open poll
+// which allows write-ins. The poll with the new option has to pass Validate,
+// otherwise the *ValidationError is returned, e.g. for an option which is
+// already part of the poll.
+func (s *DefaultStore) AddWriteIn(pollId, userID, label string) (Poll, error) {
+	return s.backend.UpdatePoll(pollId, func(p *Poll) error {
+		if !p.AllowWriteIns {
+			return errors.Wrapf(ErrWriteInsDisabled, "User %s proposed option for poll %s", userID, pollId)
+		}
+		if !p.IsOpen(time.Now()) {
+			return errors.Wrapf(ErrPollClosed, "User %s proposed option for closed poll %s", userID, pollId)
+		}
+		if len(p.Options) >= MaxOptions {
+			return newValidationError(ErrTooManyOptions, "This poll already has the maximum of %d options!", MaxOptions)
+		}
+		p.AddOption(strings.TrimSpace(label))
+		p.Options[len(p.Options)-1].ProposedBy = userID
+		return Validate(*p)
+	})
+}
+
+// DisableWriteIns stops participants from adding options to a poll. Options
+// which were already added are kept. Only the creator of the poll can do
+// this.
+func (s *DefaultStore) DisableWriteIns(pollId, userID string) (Poll, error) {
+	return s.backend.UpdatePoll(pollId, func(p *Poll) error {
+		if p.CreatorID != userID {
+			return errors.Wrapf(ErrNotPollCreator, "User %s tried to disable write-ins of poll %s", userID, pollId)
+		}
+		p.AllowWriteIns = false
+		return nil
+	})
+}
+
+// DeletePoll removes a poll and its votes. Only the creator of the poll and
+// admins can delete it.
+func (s *DefaultStore) DeletePoll(pollId, userID string, isAdmin bool) (Poll, error) {
+	pollToDelete, err := s.backend.GetPoll(pollId)
+	if err != nil {
+		return Poll{}, err
+	}
+	if pollToDelete.CreatorID != userID && !isAdmin {
+		return Poll{}, errors.Wrapf(ErrNotPollCreator, "User %s tried to delete poll %s", userID, pollId)
+	}
+	return pollToDelete, s.backend.DeletePoll(pollId)
+}
+
+// DeletePollsCreatedBefore removes all polls created before cutoff and their
+// votes. If a deletion fails the polls deleted so far are returned with the
+// error.
+func (s *DefaultStore) DeletePollsCreatedBefore(cutoff time.Time) ([]Poll, error) {
+	oldPolls, err := s.backend.GetPollsCreatedBefore(cutoff)
+	if err != nil {
+		return nil, err
+	}
+	deletedPolls := make([]Poll, 0, len(oldPolls))
+	for _, oldPoll := range oldPolls {
+		err = s.backend.DeletePoll(oldPoll.ID)
+		if err != nil {
+			return deletedPolls, errors.Wrapf(err, "Error deleting old poll %s", oldPoll.ID)
+		}
+		deletedPolls = append(deletedPolls, oldPoll)
+	}
+	return deletedPolls, nil
+}
@@ -2,12 +2,17 @@ package poll_test
 
 import (
 	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/go-test/deep"
+	"github.com/pkg/errors"
 	"markusreschke.name/selfhostedchatpolling/poll"
 	"markusreschke.name/selfhostedchatpolling/poll/memstore"
 )
@@ -20,12 +25,12 @@ func TestMain(m *testing.M) {
 
 func TestAddingAndRetrievingData(t *testing.T) {
 	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
-	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: []string{"a1", "a2", "a3"}}
+	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2", "a3")}
 	err := store.AddPoll(testPoll)
 	if err != nil {
 		t.Fatalf("Error creating poll: %v", err)
 	}
-	vote := poll.Vote{"1", "voter", "1", 0}
+	vote := poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0}
 	err = store.AddVote(vote)
 	if err != nil {
 		t.Fatalf("Error creating vote: %v", err)
@@ -35,12 +40,12 @@ func TestAddingAndRetrievingData(t *testing.T) {
 		t.Fatalf("Expected %v but got %v", testPoll, pollFromStore)
 	}
 	voteFromStore, err := store.GetVote("1")
-	if !(vote == voteFromStore) {
+	if !reflect.DeepEqual(vote, voteFromStore) {
 		t.Fatalf("Expected %v but got %v", vote, voteFromStore)
 	}
 
 	// Test if store allows for invalid voting
-	voteInvalidChoice := poll.Vote{"1", "voter2", "1", 3}
+	voteInvalidChoice := poll.Vote{ID: "1", VoterID: "voter2", PollID: "1", VotedFor: 3}
 	err = store.AddVote(voteInvalidChoice)
 	if err == nil {
 		t.Error("Store allowed voting for invalid choice")
@@ -49,15 +54,15 @@ func TestAddingAndRetrievingData(t *testing.T) {
 
 func TestChangingVotes(t *testing.T) {
 	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
-	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: []string{"a1", "a2", "a3"}}
+	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2", "a3")}
 	store.AddPoll(testPoll)
-	vote := poll.Vote{"1", "voter", "1", 0}
+	vote := poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0}
 	err := store.AddVote(vote)
 	if err != nil {
 		t.Log("Error storing first vote: ", err)
 		t.Fail()
 	}
-	vote = poll.Vote{"1", "voter", "1", 1}
+	vote = poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 1}
 	err = store.AddVote(vote)
 	if err != nil {
 		t.Log("Error storing changed vote: ", err)
@@ -71,19 +76,19 @@ func TestChangingVotes(t *testing.T) {
 
 func TestGettingCount(t *testing.T) {
 	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
-	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: []string{"a1", "a2", "a3"}}
+	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2", "a3")}
 	store.AddPoll(testPoll)
-	vote := poll.Vote{"1", "voter", "1", 0}
+	vote := poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0}
 	store.AddVote(vote)
-	vote = poll.Vote{"2", "voter2", "1", 0}
+	vote = poll.Vote{ID: "2", VoterID: "voter2", PollID: "1", VotedFor: 0}
 	store.AddVote(vote)
-	vote = poll.Vote{"3", "voter3", "1", 2}
+	vote = poll.Vote{ID: "3", VoterID: "voter3", PollID: "1", VotedFor: 2}
 	store.AddVote(vote)
-	vote = poll.Vote{"4", "voter4", "1", 0}
+	vote = poll.Vote{ID: "4", VoterID: "voter4", PollID: "1", VotedFor: 0}
 	store.AddVote(vote)
-	vote = poll.Vote{"5", "voter5", "1", 2}
+	vote = poll.Vote{ID: "5", VoterID: "voter5", PollID: "1", VotedFor: 2}
 	store.AddVote(vote)
-	vote = poll.Vote{"6", "voter6", "1", 0}
+	vote = poll.Vote{ID: "6", VoterID: "voter6", PollID: "1", VotedFor: 0}
 	store.AddVote(vote)
 	result, err := store.GetResult("1")
 	if err != nil || result[0] != 4 || result[1] != 0 || result[2] != 2 {
@@ -93,19 +98,19 @@ func TestGettingCount(t *testing.T) {
 
 func TestGetVoteDetails(t *testing.T) {
 	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
-	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: []string{"a1", "a2", "a3"}}
+	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2", "a3")}
 	store.AddPoll(testPoll)
-	vote := poll.Vote{"1", "voter", "1", 0}
+	vote := poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0}
 	store.AddVote(vote)
-	vote = poll.Vote{"2", "voter2", "1", 0}
+	vote = poll.Vote{ID: "2", VoterID: "voter2", PollID: "1", VotedFor: 0}
 	store.AddVote(vote)
-	vote = poll.Vote{"3", "voter3", "1", 2}
+	vote = poll.Vote{ID: "3", VoterID: "voter3", PollID: "1", VotedFor: 2}
 	store.AddVote(vote)
-	vote = poll.Vote{"4", "voter4", "1", 0}
+	vote = poll.Vote{ID: "4", VoterID: "voter4", PollID: "1", VotedFor: 0}
 	store.AddVote(vote)
-	vote = poll.Vote{"5", "voter5", "1", 2}
+	vote = poll.Vote{ID: "5", VoterID: "voter5", PollID: "1", VotedFor: 2}
 	store.AddVote(vote)
-	vote = poll.Vote{"6", "voter6", "1", 0}
+	vote = poll.Vote{ID: "6", VoterID: "voter6", PollID: "1", VotedFor: 0}
 	store.AddVote(vote)
 
 	expectedResult := map[string][]string{
@@ -127,7 +132,7 @@ func TestGetVoteDetails(t *testing.T) {
 
 func TestGetVoteDetailsAnonymous(t *testing.T) {
 	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
-	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: []string{"a1", "a2", "a3"}, Anonymous: true}
+	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2", "a3"), Anonymous: true}
 	store.AddPoll(testPoll)
 
 	_, err := store.GetVoteDetails(testPoll.ID)
@@ -138,3 +143,418 @@ func TestGetVoteDetailsAnonymous(t *testing.T) {
 		t.Fatal("Unexpected error was returned!: ", err)
 	}
 }
+
+func TestVotingOnClosedPoll(t *testing.T) {
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	closedPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2"), Closed: true}
+	expiredPoll := poll.Poll{ID: "2", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2"), Deadline: time.Now().Add(-time.Minute)}
+	store.AddPoll(closedPoll)
+	store.AddPoll(expiredPoll)
+	for _, p := range []poll.Poll{closedPoll, expiredPoll} {
+		err := store.AddVote(poll.Vote{ID: "1", VoterID: "voter", PollID: p.ID, VotedFor: 0})
+		if errors.Cause(err) != poll.ErrPollClosed {
+			t.Errorf("Expected ErrPollClosed when voting on poll %v but got: %v", p, err)
+		}
+	}
+}
+
+func TestClosePoll(t *testing.T) {
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2")}
+	store.AddPoll(testPoll)
+	_, err := store.ClosePoll(testPoll.ID, "someoneElse")
+	if errors.Cause(err) != poll.ErrNotPollCreator {
+		t.Fatal("Expected ErrNotPollCreator when closing poll of someone else but got: ", err)
+	}
+	closedPoll, err := store.ClosePoll(testPoll.ID, "creator")
+	if err != nil {
+		t.Fatal("Error closing poll: ", err)
+	}
+	if !closedPoll.Closed {
+		t.Fatal("Poll wasn't closed!")
+	}
+	pollFromStore, _ := store.GetPoll(testPoll.ID)
+	if !pollFromStore.Closed {
+		t.Fatal("Closed state of poll wasn't stored!")
+	}
+}
+
+func TestDeletePoll(t *testing.T) {
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2")}
+	store.AddPoll(testPoll)
+	store.AddVote(poll.Vote{ID: "1", VoterID: "voter", PollID: testPoll.ID, VotedFor: 0})
+	_, err := store.DeletePoll(testPoll.ID, "someoneElse", false)
+	if errors.Cause(err) != poll.ErrNotPollCreator {
+		t.Fatal("Expected ErrNotPollCreator when deleting poll of someone else but got: ", err)
+	}
+	deletedPoll, err := store.DeletePoll(testPoll.ID, "creator", false)
+	if err != nil {
+		t.Fatal("Error deleting poll: ", err)
+	}
+	if !reflect.DeepEqual(testPoll, deletedPoll) {
+		t.Fatalf("Expected deleted poll %v but got %v", testPoll, deletedPoll)
+	}
+	_, err = store.GetPoll(testPoll.ID)
+	if errors.Cause(err) != poll.ErrPollNotFound {
+		t.Fatal("Expected ErrPollNotFound for deleted poll but got: ", err)
+	}
+	polls, err := store.GetPollsByCreator("creator")
+	if err != nil {
+		t.Fatal("Error getting polls of creator: ", err)
+	}
+	if len(polls) != 0 {
+		t.Fatalf("Expected no polls after deleting the poll but got %v", polls)
+	}
+	store.AddPoll(testPoll)
+	_, err = store.DeletePoll(testPoll.ID, "admin", true)
+	if err != nil {
+		t.Fatal("Error deleting poll of other user as admin: ", err)
+	}
+}
+
+func TestDeletePollsCreatedBefore(t *testing.T) {
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	now := time.Now().UTC()
+	store.AddPoll(poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2"), CreatedAt: now.Add(-48 * time.Hour)})
+	store.AddPoll(poll.Poll{ID: "2", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2"), CreatedAt: now})
+	store.AddPoll(poll.Poll{ID: "3", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2")})
+	store.AddVote(poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0})
+	deletedPolls, err := store.DeletePollsCreatedBefore(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatal("Error deleting old polls: ", err)
+	}
+	if len(deletedPolls) != 1 || deletedPolls[0].ID != "1" {
+		t.Fatalf("Expected only poll 1 to be deleted but got %v", deletedPolls)
+	}
+	polls, err := store.GetPollsByCreator("creator")
+	if err != nil || len(polls) != 2 {
+		t.Fatalf("Expected new poll and poll without creation time to remain but got %v, error: %v", polls, err)
+	}
+}
+
+func TestEditPoll(t *testing.T) {
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2", "a3")}
+	store.AddPoll(testPoll)
+	store.AddVote(poll.Vote{ID: "1", VoterID: "voter", PollID: testPoll.ID, VotedFor: 0})
+	store.AddVote(poll.Vote{ID: "2", VoterID: "voter2", PollID: testPoll.ID, VotedFor: 2})
+	edit := poll.PollEdit{Question: "Question?", Labels: map[int]string{0: "A1"}, NewOptions: []string{"a4"}, RemovedOptions: []int{1}}
+	_, err := store.EditPoll(testPoll.ID, "someoneElse", edit)
+	if errors.Cause(err) != poll.ErrNotPollCreator {
+		t.Fatal("Expected ErrNotPollCreator when editing poll of someone else but got: ", err)
+	}
+	_, err = store.EditPoll(testPoll.ID, "creator", poll.PollEdit{Question: "q", Labels: map[int]string{2: "a1"}})
+	if validationErr, ok := err.(*poll.ValidationError); !ok || validationErr.Rule != poll.ErrDuplicateOption {
+		t.Fatal("Expected validation error for duplicate options but got: ", err)
+	}
+	_, err = store.EditPoll(testPoll.ID, "creator", poll.PollEdit{Question: "q", RemovedOptions: []int{5}})
+	if errors.Cause(err) != poll.ErrInvalidChoice {
+		t.Fatal("Expected ErrInvalidChoice when removing unknown option but got: ", err)
+	}
+	editedPoll, err := store.EditPoll(testPoll.ID, "creator", edit)
+	if err != nil {
+		t.Fatal("Error editing poll: ", err)
+	}
+	pollFromStore, _ := store.GetPoll(testPoll.ID)
+	expectedOptions := []poll.Option{{ID: 0, Label: "A1"}, {ID: 2, Label: "a3"}, {ID: 3, Label: "a4"}}
+	for _, p := range []poll.Poll{editedPoll, pollFromStore} {
+		if diff := deep.Equal(expectedOptions, p.Options); p.Question != "Question?" || diff != nil {
+			t.Fatalf("Poll wasn't edited as expected: %v", p)
+		}
+	}
+	result, _ := store.GetResult(testPoll.ID)
+	if diff := deep.Equal(map[int]uint64{0: 1, 2: 1}, result); diff != nil {
+		t.Fatal("Votes don't refer to the same options after the edit: ", diff)
+	}
+	store.ClosePoll(testPoll.ID, "creator")
+	_, err = store.EditPoll(testPoll.ID, "creator", edit)
+	if errors.Cause(err) != poll.ErrPollClosed {
+		t.Fatal("Expected ErrPollClosed when editing closed poll but got: ", err)
+	}
+}
+
+func TestRemoveOptionsFromVote(t *testing.T) {
+	testCases := []struct {
+		vote           poll.Vote
+		removedOptions []int
+		expectedVote   poll.Vote
+		isKept         bool
+	}{
+		{poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 1}, []int{1}, poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 1}, false},
+		{poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 3}, []int{2, 0}, poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 3}, true},
+		{poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 2, Ranking: []int{2, 0, 3}}, []int{2}, poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0, Ranking: []int{0, 3}}, true},
+		{poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 2, Ranking: []int{2}}, []int{2}, poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 2, Ranking: []int{2}}, false},
+	}
+	for _, testCase := range testCases {
+		vote := testCase.vote
+		isKept := poll.RemoveOptionsFromVote(&vote, testCase.removedOptions)
+		if diff := deep.Equal(testCase.expectedVote, vote); isKept != testCase.isKept || diff != nil {
+			t.Errorf("Removing options %v from vote %v returned %t and %v", testCase.removedOptions, testCase.vote, isKept, vote)
+		}
+	}
+}
+
+func TestWriteIns(t *testing.T) {
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2"), AllowWriteIns: true}
+	store.AddPoll(testPoll)
+	store.AddPoll(poll.Poll{ID: "2", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2")})
+	_, err := store.AddWriteIn("2", "voter", "a3")
+	if errors.Cause(err) != poll.ErrWriteInsDisabled {
+		t.Fatal("Expected ErrWriteInsDisabled for poll without write-ins but got: ", err)
+	}
+	updatedPoll, err := store.AddWriteIn(testPoll.ID, "voter", " a3 ")
+	if err != nil {
+		t.Fatal("Error adding write-in: ", err)
+	}
+	expectedOption := poll.Option{ID: 2, Label: "a3", ProposedBy: "voter"}
+	if diff := deep.Equal(expectedOption, updatedPoll.Options[2]); len(updatedPoll.Options) != 3 || diff != nil {
+		t.Fatalf("Write-in wasn't added as expected: %v", updatedPoll.Options)
+	}
+	err = store.AddVote(poll.Vote{ID: "1", VoterID: "voter2", PollID: testPoll.ID, VotedFor: 2})
+	if err != nil {
+		t.Fatal("Error voting for write-in: ", err)
+	}
+	_, err = store.AddWriteIn(testPoll.ID, "voter2", "A1")
+	if validationErr, ok := err.(*poll.ValidationError); !ok || validationErr.Rule != poll.ErrDuplicateOption {
+		t.Fatal("Expected validation error for duplicate write-in but got: ", err)
+	}
+	_, err = store.DisableWriteIns(testPoll.ID, "voter")
+	if errors.Cause(err) != poll.ErrNotPollCreator {
+		t.Fatal("Expected ErrNotPollCreator when disabling write-ins of poll of someone else but got: ", err)
+	}
+	updatedPoll, err = store.DisableWriteIns(testPoll.ID, "creator")
+	if err != nil || updatedPoll.AllowWriteIns || len(updatedPoll.Options) != 3 {
+		t.Fatalf("Expected write-ins to be disabled but got %v and error %v", updatedPoll, err)
+	}
+	_, err = store.AddWriteIn(testPoll.ID, "voter", "a4")
+	if errors.Cause(err) != poll.ErrWriteInsDisabled {
+		t.Fatal("Expected ErrWriteInsDisabled after disabling write-ins but got: ", err)
+	}
+	labels := make([]string, poll.MaxOptions)
+	for i := range labels {
+		labels[i] = fmt.Sprintf("a%d", i)
+	}
+	store.AddPoll(poll.Poll{ID: "3", Question: "q", CreatorID: "creator", Options: poll.NewOptions(labels...), AllowWriteIns: true})
+	_, err = store.AddWriteIn("3", "voter", "another")
+	if validationErr, ok := err.(*poll.ValidationError); !ok || validationErr.Rule != poll.ErrTooManyOptions {
+		t.Fatal("Expected validation error for write-in exceeding the maximum number of options but got: ", err)
+	}
+}
+
+func TestDeadlineScheduler(t *testing.T) {
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	store.AddPoll(poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2"), Deadline: time.Now().Add(-time.Minute)})
+	store.AddPoll(poll.Poll{ID: "2", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2"), Deadline: time.Now().Add(time.Hour)})
+	closedPolls := make(chan poll.Poll, 2)
+	stop := make(chan struct{})
+	defer close(stop)
+	scheduler := poll.NewDeadlineScheduler(store, time.Millisecond, log.New(ioutil.Discard, "", 0), func(p poll.Poll) { closedPolls <- p })
+	go scheduler.Run(stop)
+	select {
+	case closedPoll := <-closedPolls:
+		if closedPoll.ID != "1" || !closedPoll.Closed {
+			t.Fatalf("Expected closed poll 1 but got %v", closedPoll)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expired poll wasn't closed by scheduler!")
+	}
+	pollFromStore, _ := store.GetPoll("2")
+	if pollFromStore.Closed {
+		t.Fatal("Scheduler closed poll whose deadline didn't pass yet!")
+	}
+}
+
+// closingBackend closes the expired polls after finding them, like a
+// concurrent ClosePoll.
+type closingBackend struct {
+	poll.StoreBackend
+}
+
+func (b closingBackend) GetPollsWithExpiredDeadline(now time.Time) ([]poll.Poll, error) {
+	expiredPolls, err := b.StoreBackend.GetPollsWithExpiredDeadline(now)
+	for _, expiredPoll := range expiredPolls {
+		b.UpdatePoll(expiredPoll.ID, func(p *poll.Poll) error {
+			p.Closed = true
+			return nil
+		})
+	}
+	return expiredPolls, err
+}
+
+func TestCloseExpiredPollsClosedMeanwhile(t *testing.T) {
+	store := poll.NewDefaultStore(closingBackend{memstore.NewInMemoryStoreBackend()})
+	store.AddPoll(poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2"), Deadline: time.Now().Add(-time.Minute)})
+	closedPolls, err := store.CloseExpiredPolls(time.Now())
+	if err != nil || len(closedPolls) != 0 {
+		t.Fatalf("Expected no polls closed by the deadline but got %v, error: %v", closedPolls, err)
+	}
+}
+
+func TestRetentionJanitor(t *testing.T) {
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	store.AddPoll(poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2"), CreatedAt: time.Now().Add(-48 * time.Hour)})
+	store.AddPoll(poll.Poll{ID: "2", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2"), CreatedAt: time.Now()})
+	stop := make(chan struct{})
+	defer close(stop)
+	deletedPolls := make(chan poll.Poll, 1)
+	janitor := poll.NewRetentionJanitor(store, 24*time.Hour, time.Millisecond, log.New(ioutil.Discard, "", 0), func(p poll.Poll) { deletedPolls <- p })
+	go janitor.Run(stop)
+	timeout := time.After(time.Second)
+	for {
+		if polls, _ := store.GetPollsByCreator("creator"); len(polls) == 1 {
+			break
+		}
+		select {
+		case <-timeout:
+			t.Fatal("Old poll wasn't deleted by janitor!")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if polls, _ := store.GetPollsByCreator("creator"); polls[0].ID != "2" {
+		t.Fatalf("Expected janitor to keep poll within the retention period but got %v", polls)
+	}
+	if deletedPoll := <-deletedPolls; deletedPoll.ID != "1" {
+		t.Fatalf("Expected janitor to report deleted poll 1 but got %v", deletedPoll)
+	}
+}
+
+func TestMultipleChoiceVoting(t *testing.T) {
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2", "a3"), Type: poll.PollTypeMultipleChoice, MaxChoices: 2}
+	store.AddPoll(testPoll)
+	votes := []poll.Vote{
+		{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0},
+		{ID: "2", VoterID: "voter", PollID: "1", VotedFor: 2},
+		{ID: "3", VoterID: "voter2", PollID: "1", VotedFor: 2},
+		{ID: "4", VoterID: "voter2", PollID: "1", VotedFor: 1},
+		{ID: "5", VoterID: "voter2", PollID: "1", VotedFor: 2},
+	}
+	for _, vote := range votes {
+		err := store.AddVote(vote)
+		if err != nil {
+			t.Fatalf("Error adding vote %v: %v", vote, err)
+		}
+	}
+	err := store.AddVote(poll.Vote{ID: "6", VoterID: "voter", PollID: "1", VotedFor: 1})
+	if errors.Cause(err) != poll.ErrTooManyChoices {
+		t.Fatal("Expected ErrTooManyChoices when selecting too many options but got: ", err)
+	}
+	result, err := store.GetResult("1")
+	if err != nil || result[0] != 1 || result[1] != 1 || result[2] != 1 {
+		t.Fatalf("Counts do not match: %v Error: %v", result, err)
+	}
+	participants, err := store.GetParticipantCount("1")
+	if err != nil || participants != 2 {
+		t.Fatalf("Expected 2 participants but got %d, error: %v", participants, err)
+	}
+	expectedDetails := map[string][]string{
+		"a1": []string{"voter"},
+		"a2": []string{"voter2"},
+		"a3": []string{"voter"},
+	}
+	details, err := store.GetVoteDetails("1")
+	if err != nil {
+		t.Fatal("Error getting vote details: ", err)
+	}
+	if diff := deep.Equal(expectedDetails, details); diff != nil {
+		t.Fatal("Vote details don't match the expected details", diff)
+	}
+}
+
+func TestGetVoterChoices(t *testing.T) {
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	polls := []poll.Poll{
+		{ID: "single", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2", "a3")},
+		{ID: "multi", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2", "a3"), Type: poll.PollTypeMultipleChoice},
+		{ID: "ranked", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2", "a3"), Type: poll.PollTypeRankedChoice},
+	}
+	votes := []poll.Vote{
+		{ID: "1", VoterID: "voter", PollID: "single", VotedFor: 0},
+		{ID: "2", VoterID: "voter", PollID: "single", VotedFor: 1},
+		{ID: "3", VoterID: "voter", PollID: "multi", VotedFor: 2},
+		{ID: "4", VoterID: "voter", PollID: "multi", VotedFor: 0},
+		{ID: "5", VoterID: "voter2", PollID: "multi", VotedFor: 1},
+		{ID: "6", VoterID: "voter", PollID: "ranked", VotedFor: 0, Ranking: []int{2, 0}},
+	}
+	for _, p := range polls {
+		store.AddPoll(p)
+	}
+	for _, vote := range votes {
+		err := store.AddVote(vote)
+		if err != nil {
+			t.Fatalf("Error adding vote %v: %v", vote, err)
+		}
+	}
+	expectedChoices := map[string][]int{"single": {1}, "multi": {0, 2}, "ranked": {2, 0}}
+	for pollID, expected := range expectedChoices {
+		choices, err := store.GetVoterChoices(pollID, "voter")
+		if diff := deep.Equal(expected, choices); err != nil || diff != nil {
+			t.Errorf("Unexpected choices for poll %s: %v, error: %v", pollID, diff, err)
+		}
+	}
+	choices, err := store.GetVoterChoices("single", "voter2")
+	if err != nil || choices == nil || len(choices) != 0 {
+		t.Errorf("Expected no choices for voter without vote but got %v, error: %v", choices, err)
+	}
+}
+
+func TestResultsHidden(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		poll     poll.Poll
+		expected bool
+	}{
+		{poll.Poll{HideResults: true}, true},
+		{poll.Poll{HideResults: true, Deadline: now.Add(time.Hour)}, true},
+		{poll.Poll{HideResults: true, Closed: true}, false},
+		{poll.Poll{HideResults: true, Deadline: now}, false},
+		{poll.Poll{}, false},
+	}
+	for _, testCase := range testCases {
+		if hidden := testCase.poll.ResultsHidden(now); hidden != testCase.expected {
+			t.Errorf("Expected results of %v to be hidden: %v, but got %v", testCase.poll, testCase.expected, hidden)
+		}
+	}
+}
+
+func TestRankedChoiceVoting(t *testing.T) {
+	store := poll.NewDefaultStore(memstore.NewInMemoryStoreBackend())
+	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2", "a3"), Type: poll.PollTypeRankedChoice}
+	store.AddPoll(testPoll)
+	invalidRankings := [][]int{nil, {3}, {0, 0}, {1, -1}}
+	for _, ranking := range invalidRankings {
+		err := store.AddVote(poll.Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0, Ranking: ranking})
+		if errors.Cause(err) != poll.ErrInvalidChoice {
+			t.Errorf("Expected ErrInvalidChoice for ranking %v but got: %v", ranking, err)
+		}
+	}
+	votes := []poll.Vote{
+		{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0, Ranking: []int{0, 1}},
+		{ID: "2", VoterID: "voter2", PollID: "1", VotedFor: 0, Ranking: []int{1, 0}},
+		{ID: "3", VoterID: "voter3", PollID: "1", VotedFor: 0, Ranking: []int{2, 1}},
+		{ID: "4", VoterID: "voter", PollID: "1", VotedFor: 0, Ranking: []int{2, 0}},
+	}
+	for _, vote := range votes {
+		err := store.AddVote(vote)
+		if err != nil {
+			t.Fatalf("Error adding vote %v: %v", vote, err)
+		}
+	}
+	storedVote, err := store.GetVote("4")
+	if err != nil || storedVote.VotedFor != 2 {
+		t.Fatalf("Expected first ranked option as choice of ballot but got %v. Error: %v", storedVote, err)
+	}
+	result, err := store.GetResult("1")
+	if err != nil || result[0] != 0 || result[1] != 1 || result[2] != 2 {
+		t.Fatalf("First preference counts do not match: %v Error: %v", result, err)
+	}
+	runoffResult, err := store.GetRunoffResult("1")
+	if err != nil {
+		t.Fatal("Error getting runoff result: ", err)
+	}
+	if runoffResult.Winner != 2 || len(runoffResult.Rounds) != 1 {
+		t.Fatalf("Unexpected runoff result: %v", runoffResult)
+	}
+}
@@ -0,0 +1,55 @@
+package poll
+
+// PollEdit holds the changes the creator makes to a poll. Labels maps the IDs
+// of existing options to their new labels, NewOptions holds the labels of the
+// options to append and RemovedOptions the IDs of the options which are
+// removed together with their votes.
+type PollEdit struct {
+	Question       string
+	Labels         map[int]string
+	NewOptions     []string
+	RemovedOptions []int
+}
+
+// RemoveOptionsFromPoll removes the options with the given IDs, the remaining
+// options keep their order. The IDs of the removed options stay reserved.
+func RemoveOptionsFromPoll(p *Poll, removedOptions []int) {
+	p.ReserveOptionIDs()
+	remainingOptions := make([]Option, 0, len(p.Options))
+	for _, option := range p.Options {
+		if !containsOption(removedOptions, option.ID) {
+			remainingOptions = append(remainingOptions, option)
+		}
+	}
+	p.Options = remainingOptions
+}
+
+// RemoveOptionsFromVote drops the options with the given IDs from the ranking
+// of a vote. It returns false if the vote was only for removed options and
+// has to be deleted.
+func RemoveOptionsFromVote(v *Vote, removedOptions []int) bool {
+	if v.Ranking == nil {
+		return !containsOption(removedOptions, v.VotedFor)
+	}
+	var ranking []int
+	for _, option := range v.Ranking {
+		if !containsOption(removedOptions, option) {
+			ranking = append(ranking, option)
+		}
+	}
+	if len(ranking) == 0 {
+		return false
+	}
+	v.Ranking = ranking
+	v.VotedFor = ranking[0]
+	return true
+}
+
+func containsOption(options []int, option int) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
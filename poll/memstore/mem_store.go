@@ -2,7 +2,11 @@ package memstore
 
 import (
 	"markusreschke.name/selfhostedchatpolling/poll"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 type InMemoryStore struct {
@@ -43,9 +47,22 @@ func (s *InMemoryStore) AddPoll(p poll.Poll) error {
 
 func (s *InMemoryStore) AddVote(v poll.Vote) error {
 	s.lock.Lock()
+	defer s.lock.Unlock()
+	err := s.checkPollExists(v.PollID)
+	if err != nil {
+		return err
+	}
 	oldVotes := s.voteStore[v.PollID]
 	s.voteStore[v.PollID] = append(oldVotes, v)
-	s.lock.Unlock()
+	return nil
+}
+
+// checkPollExists is called with the lock held, so a vote can't be stored
+// after its poll was deleted.
+func (s *InMemoryStore) checkPollExists(pollId string) error {
+	if _, ok := s.pollStore[pollId]; !ok {
+		return errors.Wrapf(poll.ErrPollNotFound, "Poll %s doesn't exist", pollId)
+	}
 	return nil
 }
 
@@ -58,9 +75,104 @@ func (s *InMemoryStore) GetVotesForPoll(pollId string) ([]poll.Vote, error) {
 
 func (s *InMemoryStore) GetPoll(pollId string) (poll.Poll, error) {
 	s.lock.Lock()
-	poll := s.pollStore[pollId]
+	storedPoll, ok := s.pollStore[pollId]
+	s.lock.Unlock()
+	if !ok {
+		return storedPoll, errors.Wrapf(poll.ErrPollNotFound, "Poll %s doesn't exist", pollId)
+	}
+	return storedPoll, nil
+}
+
+func (s *InMemoryStore) UpdatePoll(pollId string, update func(p *poll.Poll) error) (poll.Poll, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	storedPoll, ok := s.pollStore[pollId]
+	if !ok {
+		return storedPoll, errors.Wrapf(poll.ErrPollNotFound, "Poll %s doesn't exist", pollId)
+	}
+	// Options are copied so a failing update can't change the stored poll
+	if storedPoll.Options != nil {
+		storedPoll.Options = append([]poll.Option{}, storedPoll.Options...)
+	}
+	err := update(&storedPoll)
+	if err != nil {
+		return poll.Poll{}, err
+	}
+	s.pollStore[pollId] = storedPoll
+	return storedPoll, nil
+}
+
+func (s *InMemoryStore) GetPollsWithExpiredDeadline(now time.Time) ([]poll.Poll, error) {
+	s.lock.Lock()
+	expiredPolls := []poll.Poll{}
+	for _, storedPoll := range s.pollStore {
+		if !storedPoll.Closed && !storedPoll.Deadline.IsZero() && !storedPoll.Deadline.After(now) {
+			expiredPolls = append(expiredPolls, storedPoll)
+		}
+	}
+	s.lock.Unlock()
+	return expiredPolls, nil
+}
+
+func (s *InMemoryStore) GetPollsByCreator(creatorID string) ([]poll.Poll, error) {
+	s.lock.Lock()
+	createdPolls := []poll.Poll{}
+	for _, storedPoll := range s.pollStore {
+		if storedPoll.CreatorID == creatorID {
+			createdPolls = append(createdPolls, storedPoll)
+		}
+	}
 	s.lock.Unlock()
-	return poll, nil
+	sort.Slice(createdPolls, func(i, j int) bool { return createdPolls[i].CreatedAt.After(createdPolls[j].CreatedAt) })
+	return createdPolls, nil
+}
+
+func (s *InMemoryStore) GetPollsCreatedBefore(cutoff time.Time) ([]poll.Poll, error) {
+	s.lock.Lock()
+	oldPolls := []poll.Poll{}
+	for _, storedPoll := range s.pollStore {
+		if !storedPoll.CreatedAt.IsZero() && storedPoll.CreatedAt.Before(cutoff) {
+			oldPolls = append(oldPolls, storedPoll)
+		}
+	}
+	s.lock.Unlock()
+	return oldPolls, nil
+}
+
+func (s *InMemoryStore) DeletePoll(pollId string) error {
+	s.lock.Lock()
+	delete(s.pollStore, pollId)
+	delete(s.voteStore, pollId)
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *InMemoryStore) RemoveOptions(pollId string, update func(p *poll.Poll) error, removedOptions []int) (poll.Poll, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	storedPoll, ok := s.pollStore[pollId]
+	if !ok {
+		return storedPoll, errors.Wrapf(poll.ErrPollNotFound, "Poll %s doesn't exist", pollId)
+	}
+	// Options are copied so a failing update can't change the stored poll
+	if storedPoll.Options != nil {
+		storedPoll.Options = append([]poll.Option{}, storedPoll.Options...)
+	}
+	err := update(&storedPoll)
+	if err != nil {
+		return poll.Poll{}, err
+	}
+	poll.RemoveOptionsFromPoll(&storedPoll, removedOptions)
+	s.pollStore[pollId] = storedPoll
+	// The votes are copied, slices handed out by GetVotesForPoll must not change
+	remainingVotes := make([]poll.Vote, 0, len(s.voteStore[pollId]))
+	for _, vote := range s.voteStore[pollId] {
+		if poll.RemoveOptionsFromVote(&vote, removedOptions) {
+			remainingVotes = append(remainingVotes, vote)
+		}
+	}
+	s.voteStore[pollId] = remainingVotes
+	return storedPoll, nil
 }
 
 func (s *InMemoryStore) GetVote(voteId string) (poll.Vote, error) {
@@ -80,7 +192,90 @@ func (s *InMemoryStore) GetVote(voteId string) (poll.Vote, error) {
 
 func (s *InMemoryStore) RemoveVote(voteId string) error {
 	s.lock.Lock()
-	delete(s.voteStore, voteId)
+	for pollID, votes := range s.voteStore {
+		s.voteStore[pollID] = removeVotes(votes, func(vote poll.Vote) bool { return vote.ID == voteId })
+	}
 	s.lock.Unlock()
 	return nil
 }
+
+func (s *InMemoryStore) RemoveVotesOfVoter(pollID, voterID string) error {
+	s.lock.Lock()
+	s.voteStore[pollID] = removeVotes(s.voteStore[pollID], func(vote poll.Vote) bool { return vote.VoterID == voterID })
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *InMemoryStore) ReplaceVote(v poll.Vote) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	err := s.checkPollExists(v.PollID)
+	if err != nil {
+		return err
+	}
+	votes := removeVotes(s.voteStore[v.PollID], func(vote poll.Vote) bool { return vote.VoterID == v.VoterID })
+	s.voteStore[v.PollID] = append(votes, v)
+	return nil
+}
+
+func (s *InMemoryStore) ToggleSingleVote(v poll.Vote) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	err := s.checkPollExists(v.PollID)
+	if err != nil {
+		return false, err
+	}
+	votedForOption := false
+	for _, vote := range s.voteStore[v.PollID] {
+		if vote.VoterID == v.VoterID {
+			votedForOption = vote.VotedFor == v.VotedFor
+		}
+	}
+	votes := removeVotes(s.voteStore[v.PollID], func(vote poll.Vote) bool { return vote.VoterID == v.VoterID })
+	if votedForOption {
+		s.voteStore[v.PollID] = votes
+		return false, nil
+	}
+	s.voteStore[v.PollID] = append(votes, v)
+	return true, nil
+}
+
+func (s *InMemoryStore) ToggleVote(v poll.Vote, maxChoices int) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	err := s.checkPollExists(v.PollID)
+	if err != nil {
+		return false, err
+	}
+	votes := s.voteStore[v.PollID]
+	votesOfVoter := 0
+	for _, vote := range votes {
+		if vote.VoterID != v.VoterID {
+			continue
+		}
+		if vote.VotedFor == v.VotedFor {
+			s.voteStore[v.PollID] = removeVotes(votes, func(vote poll.Vote) bool {
+				return vote.VoterID == v.VoterID && vote.VotedFor == v.VotedFor
+			})
+			return false, nil
+		}
+		votesOfVoter++
+	}
+	if maxChoices > 0 && votesOfVoter >= maxChoices {
+		return false, poll.ErrTooManyChoices
+	}
+	s.voteStore[v.PollID] = append(votes, v)
+	return true, nil
+}
+
+// removeVotes returns a copy of votes without the votes matching shouldRemove.
+// A copy is needed because slices handed out by GetVotesForPoll must not change.
+func removeVotes(votes []poll.Vote, shouldRemove func(poll.Vote) bool) []poll.Vote {
+	remainingVotes := make([]poll.Vote, 0, len(votes))
+	for _, vote := range votes {
+		if !shouldRemove(vote) {
+			remainingVotes = append(remainingVotes, vote)
+		}
+	}
+	return remainingVotes
+}
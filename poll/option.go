@@ -0,0 +1,74 @@
+package poll
+
+// Option is one of the answers of a poll. Its ID doesn't change when other
+// options are added or removed, so votes refer to options by their ID.
+type Option struct {
+	ID    int
+	Label string
+	// Emoji is shown in front of the label, e.g. ":pizza:", Description
+	// below it. Both are optional.
+	Emoji       string `json:",omitempty"`
+	Description string `json:",omitempty"`
+	// ProposedBy is the ID of the participant who added the option as a
+	// write-in. It is empty for options added by the creator.
+	ProposedBy string `json:",omitempty"`
+}
+
+// NewOptions creates options with the given labels and the IDs 0, 1, 2, ...
+// which are the IDs of the options of polls created before options had IDs.
+func NewOptions(labels ...string) []Option {
+	options := make([]Option, len(labels))
+	for i, label := range labels {
+		options[i] = Option{ID: i, Label: label}
+	}
+	return options
+}
+
+// Text returns the label of the option with its emoji in front, if it has
+// one.
+func (o Option) Text() string {
+	if o.Emoji == "" {
+		return o.Label
+	}
+	return o.Emoji + " " + o.Label
+}
+
+// FindOption returns the option with the given ID.
+func (p Poll) FindOption(id int) (Option, bool) {
+	for _, option := range p.Options {
+		if option.ID == id {
+			return option, true
+		}
+	}
+	return Option{}, false
+}
+
+// OptionIDs returns the IDs of the options in the order of the poll.
+func (p Poll) OptionIDs() []int {
+	ids := make([]int, len(p.Options))
+	for i, option := range p.Options {
+		ids[i] = option.ID
+	}
+	return ids
+}
+
+// AddOption appends an option with a new ID and returns it. The ID is above
+// the IDs of all current and removed options.
+func (p *Poll) AddOption(label string) Option {
+	p.ReserveOptionIDs()
+	option := Option{ID: p.NextOptionID, Label: label}
+	p.NextOptionID++
+	p.Options = append(p.Options, option)
+	return option
+}
+
+// ReserveOptionIDs raises NextOptionID above the IDs of the current options,
+// so they aren't used again once the options are removed. NextOptionID is only
+// set once options are added to or removed from a poll.
+func (p *Poll) ReserveOptionIDs() {
+	for _, option := range p.Options {
+		if option.ID >= p.NextOptionID {
+			p.NextOptionID = option.ID + 1
+		}
+	}
+}
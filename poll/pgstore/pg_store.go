@@ -0,0 +1,613 @@
+package pgstore
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// schemaMigrations are applied in order on startup and tracked in the
+// schema_version table, so new schema changes must always be appended to the
+// end of this list.
+var schemaMigrations = []string{
+	`CREATE TABLE polls (
+		id         TEXT PRIMARY KEY,
+		question   TEXT NOT NULL,
+		creator_id TEXT NOT NULL,
+		anonymous  BOOLEAN NOT NULL DEFAULT FALSE
+	)`,
+	`CREATE TABLE options (
+		poll_id  TEXT NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		text     TEXT NOT NULL,
+		PRIMARY KEY (poll_id, position)
+	)`,
+	`CREATE TABLE votes (
+		id        TEXT PRIMARY KEY,
+		poll_id   TEXT NOT NULL,
+		voter_id  TEXT NOT NULL,
+		voted_for INTEGER NOT NULL,
+		CONSTRAINT votes_one_per_voter UNIQUE (poll_id, voter_id)
+	)`,
+	`CREATE INDEX votes_voter_id ON votes(voter_id)`,
+	`ALTER TABLE polls
+		ADD COLUMN closed       BOOLEAN NOT NULL DEFAULT FALSE,
+		ADD COLUMN deadline     TIMESTAMPTZ,
+		ADD COLUMN response_url TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX polls_open_deadline ON polls(deadline) WHERE NOT closed`,
+	`ALTER TABLE polls
+		ADD COLUMN type        TEXT NOT NULL DEFAULT '',
+		ADD COLUMN max_choices INTEGER NOT NULL DEFAULT 0`,
+	// Votes of multiple choice polls aren't exclusive, only one exclusive vote
+	// per voter is allowed.
+	`ALTER TABLE votes
+		ADD COLUMN exclusive BOOLEAN NOT NULL DEFAULT TRUE,
+		DROP CONSTRAINT votes_one_per_voter`,
+	`CREATE UNIQUE INDEX votes_one_per_voter ON votes(poll_id, voter_id) WHERE exclusive`,
+	`CREATE UNIQUE INDEX votes_one_per_option ON votes(poll_id, voter_id, voted_for)`,
+	`ALTER TABLE votes ADD COLUMN ranking INTEGER[]`,
+	`ALTER TABLE polls ADD COLUMN platform TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE polls ADD COLUMN created_at TIMESTAMPTZ`,
+	`CREATE INDEX polls_creator_id ON polls(creator_id)`,
+	`ALTER TABLE polls ADD COLUMN hide_results BOOLEAN NOT NULL DEFAULT FALSE`,
+	// Votes refer to the stable IDs of options, which are the positions of
+	// the options for polls created before options had IDs.
+	`ALTER TABLE options
+		ADD COLUMN option_id   INTEGER NOT NULL DEFAULT 0,
+		ADD COLUMN emoji       TEXT NOT NULL DEFAULT '',
+		ADD COLUMN description TEXT NOT NULL DEFAULT ''`,
+	`UPDATE options SET option_id = position`,
+	`ALTER TABLE polls ADD COLUMN allow_write_ins BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE options ADD COLUMN proposed_by TEXT NOT NULL DEFAULT ''`,
+	// IDs of removed options aren't used again, the counter starts above the
+	// highest remaining option of existing polls
+	`ALTER TABLE polls ADD COLUMN next_option_id INTEGER NOT NULL DEFAULT 0`,
+	`UPDATE polls SET next_option_id = (SELECT COALESCE(MAX(option_id), -1) + 1 FROM options WHERE options.poll_id = polls.id)`,
+}
+
+const pollColumns = "id, question, creator_id, anonymous, type, max_choices, closed, deadline, response_url, platform, created_at, hide_results, allow_write_ins, next_option_id"
+
+const voteColumns = "id, voter_id, poll_id, voted_for, ranking"
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func NewPostgresStoreBackend(dataSourceName string) (poll.StoreBackend, error) {
+	db, err := sql.Open("postgres", dataSourceName)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error opening postgres db!")
+	}
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "Error connecting to postgres db!")
+	}
+	err = migrateSchema(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &PostgresStore{db}, nil
+}
+
+func migrateSchema(db *sql.DB) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)")
+	if err != nil {
+		return errors.Wrap(err, "Error creating schema version table!")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Error starting schema migration!")
+	}
+	// Serializes migrations of several instances starting at the same time
+	_, err = tx.Exec("LOCK TABLE schema_version IN EXCLUSIVE MODE")
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Error locking schema version table!")
+	}
+	var schemaVersion int
+	err = tx.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&schemaVersion)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Error reading postgres schema version!")
+	}
+	for i := schemaVersion; i < len(schemaMigrations); i++ {
+		_, err = tx.Exec(schemaMigrations[i])
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "Error applying schema migration %d!", i)
+		}
+		_, err = tx.Exec("INSERT INTO schema_version (version) VALUES ($1)", i+1)
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrap(err, "Error updating postgres schema version!")
+		}
+	}
+	return errors.Wrap(tx.Commit(), "Error committing schema migration!")
+}
+
+func (s *PostgresStore) AddPoll(p poll.Poll) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Error starting transaction for poll!")
+	}
+	_, err = tx.Exec("INSERT INTO polls ("+pollColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)",
+		p.ID, p.Question, p.CreatorID, p.Anonymous, p.Type, p.MaxChoices, p.Closed, timeToColumn(p.Deadline), p.ResponseURL, p.Platform, timeToColumn(p.CreatedAt), p.HideResults, p.AllowWriteIns, p.NextOptionID)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Error inserting poll!")
+	}
+	err = insertOptions(tx, p)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return errors.Wrap(tx.Commit(), "Error committing poll!")
+}
+
+func insertOptions(q queryer, p poll.Poll) error {
+	for position, option := range p.Options {
+		_, err := q.Exec("INSERT INTO options (poll_id, position, option_id, text, emoji, description, proposed_by) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+			p.ID, position, option.ID, option.Label, option.Emoji, option.Description, option.ProposedBy)
+		if err != nil {
+			return errors.Wrapf(err, "Error inserting option %d for poll!", position)
+		}
+	}
+	return nil
+}
+
+func timeToColumn(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func scanPoll(row rowScanner) (poll.Poll, error) {
+	var p poll.Poll
+	var deadline, createdAt pq.NullTime
+	err := row.Scan(&p.ID, &p.Question, &p.CreatorID, &p.Anonymous, &p.Type, &p.MaxChoices, &p.Closed, &deadline, &p.ResponseURL, &p.Platform, &createdAt, &p.HideResults, &p.AllowWriteIns, &p.NextOptionID)
+	if deadline.Valid {
+		p.Deadline = deadline.Time.UTC()
+	}
+	if createdAt.Valid {
+		p.CreatedAt = createdAt.Time.UTC()
+	}
+	return p, err
+}
+
+// getPoll reads a poll including its options. The lock suffix can be used to
+// lock the poll row when reading inside of a transaction.
+func getPoll(q queryer, pollId string, lock string) (poll.Poll, error) {
+	p, err := scanPoll(q.QueryRow("SELECT "+pollColumns+" FROM polls WHERE id = $1"+lock, pollId))
+	if err == sql.ErrNoRows {
+		return p, errors.Wrapf(poll.ErrPollNotFound, "Poll %s doesn't exist", pollId)
+	}
+	if err != nil {
+		return p, errors.Wrapf(err, "Error getting poll %s!", pollId)
+	}
+	err = loadOptions(q, &p)
+	return p, err
+}
+
+func loadOptions(q queryer, p *poll.Poll) error {
+	rows, err := q.Query("SELECT option_id, text, emoji, description, proposed_by FROM options WHERE poll_id = $1 ORDER BY position", p.ID)
+	if err != nil {
+		return errors.Wrapf(err, "Error getting options for poll %s!", p.ID)
+	}
+	defer rows.Close()
+	p.Options = []poll.Option{}
+	for rows.Next() {
+		var option poll.Option
+		err = rows.Scan(&option.ID, &option.Label, &option.Emoji, &option.Description, &option.ProposedBy)
+		if err != nil {
+			return errors.Wrapf(err, "Error reading option for poll %s!", p.ID)
+		}
+		p.Options = append(p.Options, option)
+	}
+	return errors.Wrapf(rows.Err(), "Error reading options for poll %s!", p.ID)
+}
+
+func rankingToColumn(ranking []int) interface{} {
+	if ranking == nil {
+		return nil
+	}
+	rankedOptions := make([]int64, len(ranking))
+	for i, option := range ranking {
+		rankedOptions[i] = int64(option)
+	}
+	return pq.Array(rankedOptions)
+}
+
+func scanVote(row rowScanner) (poll.Vote, error) {
+	var v poll.Vote
+	var ranking []int64
+	err := row.Scan(&v.ID, &v.VoterID, &v.PollID, &v.VotedFor, pq.Array(&ranking))
+	for _, option := range ranking {
+		v.Ranking = append(v.Ranking, int(option))
+	}
+	return v, err
+}
+
+// AddVote inserts the vote as not exclusive, so it's added to the other votes
+// of the voter instead of conflicting with them.
+func (s *PostgresStore) AddVote(v poll.Vote) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Error starting transaction for vote!")
+	}
+	err = lockPollForVote(tx, v.PollID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO votes ("+voteColumns+", exclusive) VALUES ($1, $2, $3, $4, $5, FALSE)",
+		v.ID, v.VoterID, v.PollID, v.VotedFor, rankingToColumn(v.Ranking))
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Error inserting vote!")
+	}
+	return errors.Wrap(tx.Commit(), "Error committing vote!")
+}
+
+// lockPollForVote makes votes for deleted polls fail. The shared lock on the
+// row of the poll makes DeletePoll wait until the vote is committed, so the
+// vote is deleted together with the poll.
+func lockPollForVote(tx *sql.Tx, pollId string) error {
+	var id string
+	err := tx.QueryRow("SELECT id FROM polls WHERE id = $1 FOR SHARE", pollId).Scan(&id)
+	if err == sql.ErrNoRows {
+		return errors.Wrapf(poll.ErrPollNotFound, "Poll %s doesn't exist", pollId)
+	}
+	return errors.Wrapf(err, "Error locking poll %s!", pollId)
+}
+
+// ReplaceVote runs as a single upsert against the votes_one_per_voter
+// index, so concurrent requests of the same voter can never leave two votes
+// behind.
+func (s *PostgresStore) ReplaceVote(v poll.Vote) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Error starting transaction for vote!")
+	}
+	err = lockPollForVote(tx, v.PollID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO votes (`+voteColumns+`, exclusive) VALUES ($1, $2, $3, $4, $5, TRUE)
+		ON CONFLICT (poll_id, voter_id) WHERE exclusive
+		DO UPDATE SET id = EXCLUDED.id, voted_for = EXCLUDED.voted_for, ranking = EXCLUDED.ranking`,
+		v.ID, v.VoterID, v.PollID, v.VotedFor, rankingToColumn(v.Ranking))
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "Error replacing vote of voter %s for poll %s!", v.VoterID, v.PollID)
+	}
+	return errors.Wrap(tx.Commit(), "Error committing vote!")
+}
+
+// ToggleSingleVote holds the same advisory lock as ToggleVote, so concurrent
+// toggles of a voter are applied one after the other.
+func (s *PostgresStore) ToggleSingleVote(v poll.Vote) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, errors.Wrap(err, "Error starting transaction for vote!")
+	}
+	err = lockPollForVote(tx, v.PollID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	_, err = tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1), hashtext($2))", v.PollID, v.VoterID)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrapf(err, "Error locking votes of voter %s for poll %s!", v.VoterID, v.PollID)
+	}
+	var votesForOption int
+	err = tx.QueryRow("SELECT COUNT(*) FROM votes WHERE poll_id = $1 AND voter_id = $2 AND voted_for = $3", v.PollID, v.VoterID, v.VotedFor).Scan(&votesForOption)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrapf(err, "Error finding vote of voter %s for poll %s!", v.VoterID, v.PollID)
+	}
+	_, err = tx.Exec("DELETE FROM votes WHERE poll_id = $1 AND voter_id = $2", v.PollID, v.VoterID)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrapf(err, "Error removing previous vote of voter %s for poll %s!", v.VoterID, v.PollID)
+	}
+	if votesForOption > 0 {
+		return false, errors.Wrap(tx.Commit(), "Error committing vote removal!")
+	}
+	_, err = tx.Exec("INSERT INTO votes ("+voteColumns+", exclusive) VALUES ($1, $2, $3, $4, $5, TRUE)",
+		v.ID, v.VoterID, v.PollID, v.VotedFor, rankingToColumn(v.Ranking))
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrap(err, "Error inserting vote!")
+	}
+	return true, errors.Wrap(tx.Commit(), "Error committing vote!")
+}
+
+// ToggleVote holds an advisory lock for the voter, so concurrent toggles can't
+// exceed maxChoices.
+func (s *PostgresStore) ToggleVote(v poll.Vote, maxChoices int) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, errors.Wrap(err, "Error starting transaction for vote!")
+	}
+	err = lockPollForVote(tx, v.PollID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	_, err = tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1), hashtext($2))", v.PollID, v.VoterID)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrapf(err, "Error locking votes of voter %s for poll %s!", v.VoterID, v.PollID)
+	}
+	result, err := tx.Exec("DELETE FROM votes WHERE poll_id = $1 AND voter_id = $2 AND voted_for = $3", v.PollID, v.VoterID, v.VotedFor)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrapf(err, "Error removing vote of voter %s for poll %s!", v.VoterID, v.PollID)
+	}
+	removedVotes, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrap(err, "Error checking for removed vote!")
+	}
+	if removedVotes > 0 {
+		return false, errors.Wrap(tx.Commit(), "Error committing vote removal!")
+	}
+	if maxChoices > 0 {
+		var votesOfVoter int
+		err = tx.QueryRow("SELECT COUNT(*) FROM votes WHERE poll_id = $1 AND voter_id = $2", v.PollID, v.VoterID).Scan(&votesOfVoter)
+		if err != nil {
+			tx.Rollback()
+			return false, errors.Wrapf(err, "Error counting votes of voter %s for poll %s!", v.VoterID, v.PollID)
+		}
+		if votesOfVoter >= maxChoices {
+			tx.Rollback()
+			return false, poll.ErrTooManyChoices
+		}
+	}
+	_, err = tx.Exec("INSERT INTO votes ("+voteColumns+", exclusive) VALUES ($1, $2, $3, $4, $5, FALSE)",
+		v.ID, v.VoterID, v.PollID, v.VotedFor, rankingToColumn(v.Ranking))
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrap(err, "Error inserting vote!")
+	}
+	return true, errors.Wrap(tx.Commit(), "Error committing vote!")
+}
+
+func (s *PostgresStore) GetPoll(pollId string) (poll.Poll, error) {
+	return getPoll(s.db, pollId, "")
+}
+
+func (s *PostgresStore) UpdatePoll(pollId string, update func(p *poll.Poll) error) (poll.Poll, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return poll.Poll{}, errors.Wrap(err, "Error starting transaction for poll update!")
+	}
+	p, err := updatePoll(tx, pollId, update)
+	if err != nil {
+		tx.Rollback()
+		return poll.Poll{}, err
+	}
+	return p, errors.Wrap(tx.Commit(), "Error committing poll update!")
+}
+
+// updatePoll applies update to the poll and stores it in the transaction.
+func updatePoll(tx *sql.Tx, pollId string, update func(p *poll.Poll) error) (poll.Poll, error) {
+	p, err := getPoll(tx, pollId, " FOR UPDATE")
+	if err != nil {
+		return poll.Poll{}, err
+	}
+	err = update(&p)
+	if err != nil {
+		return poll.Poll{}, err
+	}
+	_, err = tx.Exec("UPDATE polls SET question = $1, creator_id = $2, anonymous = $3, type = $4, max_choices = $5, closed = $6, deadline = $7, response_url = $8, platform = $9, created_at = $10, hide_results = $11, allow_write_ins = $12, next_option_id = $13 WHERE id = $14",
+		p.Question, p.CreatorID, p.Anonymous, p.Type, p.MaxChoices, p.Closed, timeToColumn(p.Deadline), p.ResponseURL, p.Platform, timeToColumn(p.CreatedAt), p.HideResults, p.AllowWriteIns, p.NextOptionID, pollId)
+	if err != nil {
+		return poll.Poll{}, errors.Wrapf(err, "Error updating poll %s!", pollId)
+	}
+	_, err = tx.Exec("DELETE FROM options WHERE poll_id = $1", pollId)
+	if err != nil {
+		return poll.Poll{}, errors.Wrapf(err, "Error removing options of poll %s!", pollId)
+	}
+	err = insertOptions(tx, p)
+	if err != nil {
+		return poll.Poll{}, err
+	}
+	return p, nil
+}
+
+func (s *PostgresStore) GetPollsWithExpiredDeadline(now time.Time) ([]poll.Poll, error) {
+	expiredPolls, err := s.queryPolls("SELECT "+pollColumns+" FROM polls WHERE NOT closed AND deadline <= $1", now)
+	return expiredPolls, errors.Wrap(err, "Error finding polls with expired deadline!")
+}
+
+func (s *PostgresStore) GetPollsByCreator(creatorID string) ([]poll.Poll, error) {
+	createdPolls, err := s.queryPolls("SELECT "+pollColumns+" FROM polls WHERE creator_id = $1 ORDER BY created_at DESC NULLS LAST", creatorID)
+	return createdPolls, errors.Wrapf(err, "Error finding polls of creator %s!", creatorID)
+}
+
+// GetPollsCreatedBefore skips polls without creation time, whose created_at
+// is NULL.
+func (s *PostgresStore) GetPollsCreatedBefore(cutoff time.Time) ([]poll.Poll, error) {
+	oldPolls, err := s.queryPolls("SELECT "+pollColumns+" FROM polls WHERE created_at < $1", cutoff)
+	return oldPolls, errors.Wrap(err, "Error finding polls created before cutoff!")
+}
+
+// queryPolls reads all polls returned by the query including their options.
+func (s *PostgresStore) queryPolls(query string, args ...interface{}) ([]poll.Poll, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	polls := []poll.Poll{}
+	for rows.Next() {
+		p, err := scanPoll(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		polls = append(polls, p)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range polls {
+		err = loadOptions(s.db, &polls[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return polls, nil
+}
+
+func (s *PostgresStore) DeletePoll(pollId string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Error starting transaction for poll deletion!")
+	}
+	// The poll is deleted first, which waits for votes holding the lock of
+	// lockPollForVote, so the votes committed meanwhile are deleted as well
+	_, err = tx.Exec("DELETE FROM polls WHERE id = $1", pollId)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "Error deleting poll %s!", pollId)
+	}
+	_, err = tx.Exec("DELETE FROM votes WHERE poll_id = $1", pollId)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "Error deleting votes of poll %s!", pollId)
+	}
+	return errors.Wrap(tx.Commit(), "Error committing poll deletion!")
+}
+
+func (s *PostgresStore) RemoveOptions(pollId string, update func(p *poll.Poll) error, removedOptions []int) (poll.Poll, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return poll.Poll{}, errors.Wrap(err, "Error starting transaction for option removal!")
+	}
+	p, err := updatePoll(tx, pollId, func(p *poll.Poll) error {
+		err := update(p)
+		if err != nil {
+			return err
+		}
+		poll.RemoveOptionsFromPoll(p, removedOptions)
+		return nil
+	})
+	if err != nil {
+		tx.Rollback()
+		return poll.Poll{}, err
+	}
+	votes, err := queryVotes(tx, "SELECT "+voteColumns+" FROM votes WHERE poll_id = $1", pollId)
+	if err != nil {
+		tx.Rollback()
+		return poll.Poll{}, errors.Wrapf(err, "Error reading votes of poll %s!", pollId)
+	}
+	for _, v := range votes {
+		if poll.RemoveOptionsFromVote(&v, removedOptions) {
+			if v.Ranking == nil {
+				continue
+			}
+			_, err = tx.Exec("UPDATE votes SET voted_for = $1, ranking = $2 WHERE id = $3", v.VotedFor, rankingToColumn(v.Ranking), v.ID)
+		} else {
+			_, err = tx.Exec("DELETE FROM votes WHERE id = $1", v.ID)
+		}
+		if err != nil {
+			tx.Rollback()
+			return poll.Poll{}, errors.Wrapf(err, "Error updating vote %s for removed options!", v.ID)
+		}
+	}
+	return p, errors.Wrap(tx.Commit(), "Error committing option removal!")
+}
+
+// queryVotes reads all votes returned by the query.
+func queryVotes(q queryer, query string, args ...interface{}) ([]poll.Vote, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	votes := []poll.Vote{}
+	for rows.Next() {
+		v, err := scanVote(rows)
+		if err != nil {
+			return nil, err
+		}
+		votes = append(votes, v)
+	}
+	return votes, rows.Err()
+}
+
+func (s *PostgresStore) GetVote(voteId string) (poll.Vote, error) {
+	v, err := scanVote(s.db.QueryRow("SELECT "+voteColumns+" FROM votes WHERE id = $1", voteId))
+	if err != nil {
+		return v, errors.Wrapf(err, "Error getting vote %s!", voteId)
+	}
+	return v, nil
+}
+
+func (s *PostgresStore) GetVotesForPoll(pollId string) ([]poll.Vote, error) {
+	rows, err := s.db.Query("SELECT "+voteColumns+" FROM votes WHERE poll_id = $1", pollId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error finding votes for poll %s!", pollId)
+	}
+	defer rows.Close()
+	votes := []poll.Vote{}
+	for rows.Next() {
+		v, err := scanVote(rows)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error reading vote for poll %s!", pollId)
+		}
+		votes = append(votes, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "Error reading votes for poll %s!", pollId)
+	}
+	return votes, nil
+}
+
+func (s *PostgresStore) PollHasVoteFromVoter(pollID, voterID string) (bool, poll.Vote, error) {
+	v, err := scanVote(s.db.QueryRow("SELECT "+voteColumns+" FROM votes WHERE poll_id = $1 AND voter_id = $2 LIMIT 1", pollID, voterID))
+	if err == sql.ErrNoRows {
+		return false, poll.Vote{}, nil
+	}
+	if err != nil {
+		return false, poll.Vote{}, errors.Wrapf(err, "Error searching vote from voter %s for poll %s!", voterID, pollID)
+	}
+	return true, v, nil
+}
+
+func (s *PostgresStore) RemoveVote(voteId string) error {
+	_, err := s.db.Exec("DELETE FROM votes WHERE id = $1", voteId)
+	if err != nil {
+		return errors.Wrapf(err, "Error deleting vote %s", voteId)
+	}
+	return nil
+}
+
+func (s *PostgresStore) RemoveVotesOfVoter(pollID, voterID string) error {
+	_, err := s.db.Exec("DELETE FROM votes WHERE poll_id = $1 AND voter_id = $2", pollID, voterID)
+	if err != nil {
+		return errors.Wrapf(err, "Error deleting votes of voter %s for poll %s", voterID, pollID)
+	}
+	return nil
+}
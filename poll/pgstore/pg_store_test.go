@@ -0,0 +1,52 @@
+package pgstore
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+
+	"markusreschke.name/selfhostedchatpolling/poll"
+	"markusreschke.name/selfhostedchatpolling/poll/testlib"
+)
+
+var testDSN string
+
+func TestMain(m *testing.M) {
+	integrationTest := flag.Bool("integration", false, "run integration tests")
+	flag.Parse()
+	if !*integrationTest {
+		fmt.Printf("Skipping postgres test because -integration was not used!\n")
+		os.Exit(0)
+	}
+	testDSN = os.Getenv("SHCP_POSTGRES_TEST_DSN")
+	if testDSN == "" {
+		fmt.Printf("SHCP_POSTGRES_TEST_DSN must be set for the postgres test!\n")
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+func getCleanStore() poll.StoreBackend {
+	db, err := sql.Open("postgres", testDSN)
+	if err != nil {
+		fmt.Printf("Error connecting to postgres: %v\n", err)
+		os.Exit(1)
+	}
+	_, err = db.Exec("DROP TABLE IF EXISTS votes, options, polls, schema_version")
+	if err != nil {
+		fmt.Printf("Error dropping tables: %v\n", err)
+	}
+	db.Close()
+	store, err := NewPostgresStoreBackend(testDSN)
+	if err != nil {
+		fmt.Printf("Error creating store: %v\n", err)
+		os.Exit(1)
+	}
+	return store
+}
+
+func TestAllCasesInTestLib(t *testing.T) {
+	testlib.RunTests(t, getCleanStore)
+}
@@ -1,35 +1,165 @@
 package poll
 
+import (
+	"time"
+)
+
+type PollType string
+
+const (
+	// PollTypeSingleChoice polls allow one vote per voter, a new vote replaces
+	// the previous one.
+	PollTypeSingleChoice PollType = ""
+	// PollTypeMultipleChoice polls allow voters to select several options.
+	// Voting for an option again removes the vote for it.
+	PollTypeMultipleChoice PollType = "multiple"
+	// PollTypeRankedChoice polls let voters rank the options. The winner is
+	// determined by an instant-runoff.
+	PollTypeRankedChoice PollType = "ranked"
+)
+
 type Poll struct {
 	ID        string `json:"_id"`
 	Question  string
 	CreatorID string
-	Options   []string
+	Options   []Option
 	Anonymous bool
+	Type      PollType
+	// MaxChoices limits the number of options a voter can select in multiple
+	// choice polls. Zero means no limit.
+	MaxChoices int
+	Closed     bool
+	// Deadline is the time after which the poll doesn't accept votes anymore.
+	// The zero value means that the poll stays open until it is closed.
+	Deadline time.Time
+	// ResponseURL is used to update the poll message once the poll is closed.
+	ResponseURL string
+	// Platform is the name of the chat platform the poll was created on. It is
+	// empty for polls created before other platforms than Slack were supported.
+	Platform string
+	// CreatedAt is the zero value for polls created before it was recorded.
+	CreatedAt time.Time
+	// HideResults hides the results from everyone but the creator until the
+	// poll is closed, so the running tallies don't bias later voters.
+	HideResults bool
+	// AllowWriteIns lets every participant add options to the open poll
+	// until the creator disables it.
+	AllowWriteIns bool
+	// NextOptionID is the ID of the next option added to the poll, so IDs of
+	// removed options aren't given to other options.
+	NextOptionID int `json:",omitempty"`
 }
 
+// Vote refers to options by their ID, not by their position in the poll.
 type Vote struct {
 	ID       string `json:"_id"`
 	VoterID  string
 	PollID   string
 	VotedFor int
+	// Ranking holds the ranked options of a ranked choice ballot, starting
+	// with the most preferred one. VotedFor is always the first ranked option.
+	Ranking []int `json:",omitempty"`
 }
 
 type Store interface {
 	AddPoll(p Poll) error
+	// AddVote stores v in addition to the other votes of v.VoterID, use
+	// ReplaceVote to replace them.
 	AddVote(v Vote) error
+	// ReplaceVote stores a vote like AddVote, but voting for the selected
+	// option of a single choice poll again keeps the vote.
+	ReplaceVote(v Vote) error
+	// GetResult returns the number of votes for every option ID.
 	GetResult(pollId string) (map[int]uint64, error)
+	// GetParticipantCount returns the number of voters who voted in the poll.
+	GetParticipantCount(pollId string) (int, error)
+	// GetVoterChoices returns the options voterID currently votes for.
+	GetVoterChoices(pollId, voterID string) ([]int, error)
+	// GetPoll returns an error caused by ErrPollNotFound if there is no poll
+	// with the ID, e.g. because it was deleted.
 	GetPoll(pollId string) (Poll, error)
 	GetVote(voteId string) (Vote, error)
 	GetVoteDetails(pollId string) (map[string][]string, error)
+	GetRunoffResult(pollId string) (RunoffResult, error)
+	ClosePoll(pollId, userID string) (Poll, error)
+	CloseExpiredPolls(now time.Time) ([]Poll, error)
+	SetResponseURL(pollId, responseURL string) error
+	// RetractVote removes all votes of voterID for an open poll, so the voter
+	// abstains.
+	RetractVote(pollId, voterID string) error
+	GetPollsByCreator(creatorID string) ([]Poll, error)
+	// DeletePoll removes a poll and its votes and returns the deleted poll.
+	// Only its creator and workspace admins, as told by the chat platform, can
+	// delete it.
+	DeletePoll(pollId, userID string, isAdmin bool) (Poll, error)
+	// DeletePollsCreatedBefore removes all polls created before cutoff
+	// together with their votes and returns the deleted polls.
+	DeletePollsCreatedBefore(cutoff time.Time) ([]Poll, error)
+	// EditPoll applies the changes of the creator to an open poll and returns
+	// the edited poll.
+	EditPoll(pollId, userID string, edit PollEdit) (Poll, error)
+	// AddWriteIn appends an option proposed by userID to an open poll which
+	// allows write-ins and returns the poll.
+	AddWriteIn(pollId, userID, label string) (Poll, error)
+	// DisableWriteIns stops further write-ins for a poll, only the creator
+	// can do this.
+	DisableWriteIns(pollId, userID string) (Poll, error)
 }
 
 type StoreBackend interface {
 	AddPoll(p Poll) error
 	AddVote(v Vote) error
+	// GetPoll returns an error caused by ErrPollNotFound for unknown polls,
+	// as does UpdatePoll.
 	GetPoll(pollId string) (Poll, error)
 	GetVote(voteId string) (Vote, error)
 	GetVotesForPoll(pollId string) ([]Vote, error)
 	PollHasVoteFromVoter(pollID, voterID string) (bool, Vote, error)
 	RemoveVote(voteId string) error
+	// RemoveVotesOfVoter removes all votes of voterID for a poll. It succeeds
+	// if the voter didn't vote.
+	RemoveVotesOfVoter(pollID, voterID string) error
+	// ReplaceVote atomically replaces any vote of v.VoterID for v.PollID with
+	// v, so concurrent calls for the same voter always leave exactly one vote.
+	ReplaceVote(v Vote) error
+	// ToggleVote atomically removes the vote of v.VoterID for v.VotedFor if
+	// there is one and adds v otherwise. It returns whether v was added. If
+	// maxChoices is positive and the voter already voted for as many options
+	// ErrTooManyChoices is returned.
+	ToggleVote(v Vote, maxChoices int) (bool, error)
+	// ToggleSingleVote atomically removes all votes of v.VoterID if the voter
+	// currently votes for v.VotedFor and replaces them with v otherwise, like
+	// ReplaceVote. It returns whether v was added.
+	ToggleSingleVote(v Vote) (bool, error)
+	// UpdatePoll atomically applies update to the stored poll and returns the
+	// updated poll. If update returns an error nothing is stored.
+	UpdatePoll(pollId string, update func(p *Poll) error) (Poll, error)
+	// GetPollsWithExpiredDeadline returns all polls which aren't closed yet
+	// although their deadline is before or at now.
+	GetPollsWithExpiredDeadline(now time.Time) ([]Poll, error)
+	// GetPollsByCreator returns all polls created by creatorID, the most
+	// recently created first.
+	GetPollsByCreator(creatorID string) ([]Poll, error)
+	// GetPollsCreatedBefore returns all polls created before cutoff. Polls
+	// created before the creation time was recorded are never returned, as
+	// their age is unknown.
+	GetPollsCreatedBefore(cutoff time.Time) ([]Poll, error)
+	// DeletePoll removes a poll together with all of its votes.
+	DeletePoll(pollId string) error
+	// RemoveOptions atomically applies update to a poll like UpdatePoll and
+	// removes the options with the given IDs from it together with the votes
+	// for them. Removed options are dropped from the rankings of the remaining
+	// votes as done by RemoveOptionsFromVote. If update returns an error
+	// nothing is changed. It returns the updated poll.
+	RemoveOptions(pollId string, update func(p *Poll) error, removedOptions []int) (Poll, error)
+}
+
+func (p Poll) IsOpen(now time.Time) bool {
+	return !p.Closed && (p.Deadline.IsZero() || now.Before(p.Deadline))
+}
+
+// ResultsHidden reports whether the results of the poll are only shown to
+// its creator at now.
+func (p Poll) ResultsHidden(now time.Time) bool {
+	return p.HideResults && p.IsOpen(now)
 }
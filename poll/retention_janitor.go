@@ -0,0 +1,48 @@
+package poll
+
+import (
+	"log"
+	"time"
+)
+
+// RetentionJanitor periodically deletes polls and their votes once they are
+// older than the retention period, so the store doesn't grow forever. onDelete
+// is called for every deleted poll, e.g. to replace its message.
+type RetentionJanitor struct {
+	store     Store
+	retention time.Duration
+	interval  time.Duration
+	logger    *log.Logger
+	onDelete  func(p Poll)
+}
+
+func NewRetentionJanitor(store Store, retention, interval time.Duration, logger *log.Logger, onDelete func(p Poll)) *RetentionJanitor {
+	return &RetentionJanitor{store, retention, interval, logger, onDelete}
+}
+
+// Run deletes old polls every interval until stop is closed.
+func (j *RetentionJanitor) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			j.deleteOldPolls(now)
+		}
+	}
+}
+
+func (j *RetentionJanitor) deleteOldPolls(now time.Time) {
+	deletedPolls, err := j.store.DeletePollsCreatedBefore(now.Add(-j.retention))
+	if err != nil {
+		j.logger.Println("Error deleting old polls: ", err)
+	}
+	if len(deletedPolls) > 0 {
+		j.logger.Printf("Deleted %d polls older than the retention period", len(deletedPolls))
+	}
+	for _, deletedPoll := range deletedPolls {
+		j.onDelete(deletedPoll)
+	}
+}
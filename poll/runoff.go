@@ -0,0 +1,93 @@
+package poll
+
+// RunoffRound holds the votes of all options which weren't eliminated before
+// the round, keyed by option ID. Eliminated is the option eliminated at the
+// end of the round or -1 if the round decided the runoff.
+type RunoffRound struct {
+	Counts     map[int]uint64
+	Eliminated int
+}
+
+// RunoffResult holds all rounds of an instant-runoff. Winner is -1 if there
+// were no ballots.
+type RunoffResult struct {
+	Rounds []RunoffRound
+	Winner int
+}
+
+// RunInstantRunoff counts every ballot for its highest ranked option which
+// is still in the runoff, until an option has more than half of the counted
+// ballots or is the last option left. In every other round the option with
+// the fewest votes is eliminated. Ties are broken by the fewest votes in the
+// previous rounds, starting with the latest one. If the options are still
+// tied, the one with the highest ID is eliminated, which is the one listed
+// last in the poll as options are appended with increasing IDs.
+func RunInstantRunoff(options []int, ballots [][]int) RunoffResult {
+	result := RunoffResult{Rounds: []RunoffRound{}, Winner: -1}
+	remaining := make(map[int]bool)
+	for _, option := range options {
+		remaining[option] = true
+	}
+	for len(remaining) > 0 {
+		round := RunoffRound{Counts: countHighestRankedOptions(remaining, ballots), Eliminated: -1}
+		var totalVotes uint64
+		for _, count := range round.Counts {
+			totalVotes += count
+		}
+		if totalVotes == 0 {
+			result.Rounds = append(result.Rounds, round)
+			return result
+		}
+		for option, count := range round.Counts {
+			if count*2 > totalVotes || len(remaining) == 1 {
+				result.Rounds = append(result.Rounds, round)
+				result.Winner = option
+				return result
+			}
+		}
+		round.Eliminated = findOptionToEliminate(round.Counts, result.Rounds)
+		delete(remaining, round.Eliminated)
+		result.Rounds = append(result.Rounds, round)
+	}
+	return result
+}
+
+func countHighestRankedOptions(remaining map[int]bool, ballots [][]int) map[int]uint64 {
+	counts := make(map[int]uint64)
+	for option := range remaining {
+		counts[option] = 0
+	}
+	for _, ranking := range ballots {
+		for _, option := range ranking {
+			if remaining[option] {
+				counts[option]++
+				break
+			}
+		}
+	}
+	return counts
+}
+
+func findOptionToEliminate(counts map[int]uint64, previousRounds []RunoffRound) int {
+	eliminated := -1
+	for option := range counts {
+		if eliminated == -1 || hasFewerVotes(option, eliminated, counts, previousRounds) {
+			eliminated = option
+		}
+	}
+	return eliminated
+}
+
+// hasFewerVotes tells whether option a should rather be eliminated than b
+func hasFewerVotes(a, b int, counts map[int]uint64, previousRounds []RunoffRound) bool {
+	if counts[a] != counts[b] {
+		return counts[a] < counts[b]
+	}
+	for i := len(previousRounds) - 1; i >= 0; i-- {
+		previousCounts := previousRounds[i].Counts
+		if previousCounts[a] != previousCounts[b] {
+			return previousCounts[a] < previousCounts[b]
+		}
+	}
+	return a > b
+}
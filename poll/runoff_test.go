@@ -0,0 +1,86 @@
+package poll_test
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+func repeatBallot(ranking []int, times int) [][]int {
+	ballots := [][]int{}
+	for i := 0; i < times; i++ {
+		ballots = append(ballots, ranking)
+	}
+	return ballots
+}
+
+func joinBallots(ballotGroups ...[][]int) [][]int {
+	ballots := [][]int{}
+	for _, group := range ballotGroups {
+		ballots = append(ballots, group...)
+	}
+	return ballots
+}
+
+func TestRunInstantRunoff(t *testing.T) {
+	testCases := []struct {
+		name        string
+		optionCount int
+		ballots     [][]int
+		expected    poll.RunoffResult
+	}{
+		{
+			"NoBallots",
+			2,
+			[][]int{},
+			poll.RunoffResult{Rounds: []poll.RunoffRound{{map[int]uint64{0: 0, 1: 0}, -1}}, Winner: -1},
+		},
+		{
+			"MajorityInFirstRound",
+			3,
+			joinBallots(repeatBallot([]int{1, 0}, 3), repeatBallot([]int{0}, 2)),
+			poll.RunoffResult{Rounds: []poll.RunoffRound{{map[int]uint64{0: 2, 1: 3, 2: 0}, -1}}, Winner: 1},
+		},
+		{
+			"TieBrokenByPollOrder",
+			3,
+			joinBallots(repeatBallot([]int{0, 1}, 3), repeatBallot([]int{1, 0}, 2), repeatBallot([]int{2, 1}, 2)),
+			poll.RunoffResult{Rounds: []poll.RunoffRound{
+				{map[int]uint64{0: 3, 1: 2, 2: 2}, 2},
+				{map[int]uint64{0: 3, 1: 4}, -1},
+			}, Winner: 1},
+		},
+		{
+			"TieBrokenByPreviousRound",
+			4,
+			joinBallots(repeatBallot([]int{0}, 4), repeatBallot([]int{1}, 3), repeatBallot([]int{2, 1}, 2), repeatBallot([]int{3, 2, 1}, 1)),
+			poll.RunoffResult{Rounds: []poll.RunoffRound{
+				{map[int]uint64{0: 4, 1: 3, 2: 2, 3: 1}, 3},
+				{map[int]uint64{0: 4, 1: 3, 2: 3}, 2},
+				{map[int]uint64{0: 4, 1: 6}, -1},
+			}, Winner: 1},
+		},
+		{
+			"ExhaustedBallotsAreNotCounted",
+			3,
+			joinBallots(repeatBallot([]int{0}, 3), repeatBallot([]int{1}, 2), repeatBallot([]int{2}, 2)),
+			poll.RunoffResult{Rounds: []poll.RunoffRound{
+				{map[int]uint64{0: 3, 1: 2, 2: 2}, 2},
+				{map[int]uint64{0: 3, 1: 2}, -1},
+			}, Winner: 0},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			options := make([]int, testCase.optionCount)
+			for i := range options {
+				options[i] = i
+			}
+			result := poll.RunInstantRunoff(options, testCase.ballots)
+			if diff := deep.Equal(testCase.expected, result); diff != nil {
+				t.Errorf("Runoff result is not as expected: %v", diff)
+			}
+		})
+	}
+}
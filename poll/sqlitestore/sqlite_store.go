@@ -0,0 +1,593 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// schemaMigrations are applied in order on startup. The index of the last
+// applied migration is tracked with SQLite's user_version pragma, so new
+// schema changes must always be appended to the end of this list.
+var schemaMigrations = []string{
+	`CREATE TABLE polls (
+		id         TEXT PRIMARY KEY,
+		question   TEXT NOT NULL,
+		creator_id TEXT NOT NULL,
+		anonymous  INTEGER NOT NULL DEFAULT 0
+	)`,
+	`CREATE TABLE options (
+		poll_id  TEXT NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		text     TEXT NOT NULL,
+		PRIMARY KEY (poll_id, position)
+	)`,
+	`CREATE TABLE votes (
+		id        TEXT PRIMARY KEY,
+		poll_id   TEXT NOT NULL,
+		voter_id  TEXT NOT NULL,
+		voted_for INTEGER NOT NULL
+	)`,
+	`CREATE INDEX votes_poll_id ON votes(poll_id)`,
+	`CREATE INDEX votes_poll_id_voter_id ON votes(poll_id, voter_id)`,
+	`ALTER TABLE polls ADD COLUMN closed INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE polls ADD COLUMN deadline INTEGER`,
+	`ALTER TABLE polls ADD COLUMN response_url TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX polls_open_deadline ON polls(deadline) WHERE closed = 0`,
+	`ALTER TABLE polls ADD COLUMN type TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE polls ADD COLUMN max_choices INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE votes ADD COLUMN ranking TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE polls ADD COLUMN platform TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE polls ADD COLUMN created_at INTEGER`,
+	`CREATE INDEX polls_creator_id ON polls(creator_id)`,
+	`ALTER TABLE polls ADD COLUMN hide_results INTEGER NOT NULL DEFAULT 0`,
+	// Votes refer to the stable IDs of options, which are the positions of
+	// the options for polls created before options had IDs.
+	`ALTER TABLE options ADD COLUMN option_id INTEGER NOT NULL DEFAULT 0`,
+	`UPDATE options SET option_id = position`,
+	`ALTER TABLE options ADD COLUMN emoji TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE options ADD COLUMN description TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE polls ADD COLUMN allow_write_ins INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE options ADD COLUMN proposed_by TEXT NOT NULL DEFAULT ''`,
+	// IDs of removed options aren't used again, the counter starts above the
+	// highest remaining option of existing polls
+	`ALTER TABLE polls ADD COLUMN next_option_id INTEGER NOT NULL DEFAULT 0`,
+	`UPDATE polls SET next_option_id = (SELECT COALESCE(MAX(option_id), -1) + 1 FROM options WHERE options.poll_id = polls.id)`,
+}
+
+const pollColumns = "id, question, creator_id, anonymous, type, max_choices, closed, deadline, response_url, platform, created_at, hide_results, allow_write_ins, next_option_id"
+
+const voteColumns = "id, voter_id, poll_id, voted_for, ranking"
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func NewSQLiteStoreBackend(dataSourceName string) (poll.StoreBackend, error) {
+	db, err := sql.Open("sqlite3", dataSourceName)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error opening sqlite db!")
+	}
+	// SQLite only allows a single writer at a time, so sharing one connection
+	// avoids "database is locked" errors and keeps :memory: databases intact.
+	db.SetMaxOpenConns(1)
+	err = migrateSchema(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db}, nil
+}
+
+func migrateSchema(db *sql.DB) error {
+	var schemaVersion int
+	err := db.QueryRow("PRAGMA user_version").Scan(&schemaVersion)
+	if err != nil {
+		return errors.Wrap(err, "Error reading sqlite schema version!")
+	}
+	if schemaVersion >= len(schemaMigrations) {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Error starting schema migration!")
+	}
+	for i, migration := range schemaMigrations[schemaVersion:] {
+		_, err = tx.Exec(migration)
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "Error applying schema migration %d!", schemaVersion+i)
+		}
+	}
+	// PRAGMA statements don't support bind parameters
+	_, err = tx.Exec("PRAGMA user_version = " + strconv.Itoa(len(schemaMigrations)))
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Error updating sqlite schema version!")
+	}
+	return errors.Wrap(tx.Commit(), "Error committing schema migration!")
+}
+
+func (s *SQLiteStore) AddPoll(p poll.Poll) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Error starting transaction for poll!")
+	}
+	_, err = tx.Exec("INSERT INTO polls ("+pollColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		p.ID, p.Question, p.CreatorID, p.Anonymous, p.Type, p.MaxChoices, p.Closed, timeToColumn(p.Deadline), p.ResponseURL, p.Platform, timeToColumn(p.CreatedAt), p.HideResults, p.AllowWriteIns, p.NextOptionID)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Error inserting poll!")
+	}
+	err = insertOptions(tx, p)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return errors.Wrap(tx.Commit(), "Error committing poll!")
+}
+
+func insertOptions(q queryer, p poll.Poll) error {
+	for position, option := range p.Options {
+		_, err := q.Exec("INSERT INTO options (poll_id, position, option_id, text, emoji, description, proposed_by) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			p.ID, position, option.ID, option.Label, option.Emoji, option.Description, option.ProposedBy)
+		if err != nil {
+			return errors.Wrapf(err, "Error inserting option %d for poll!", position)
+		}
+	}
+	return nil
+}
+
+func timeToColumn(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Unix()
+}
+
+func scanPoll(row rowScanner) (poll.Poll, error) {
+	var p poll.Poll
+	var deadline, createdAt sql.NullInt64
+	err := row.Scan(&p.ID, &p.Question, &p.CreatorID, &p.Anonymous, &p.Type, &p.MaxChoices, &p.Closed, &deadline, &p.ResponseURL, &p.Platform, &createdAt, &p.HideResults, &p.AllowWriteIns, &p.NextOptionID)
+	if deadline.Valid {
+		p.Deadline = time.Unix(deadline.Int64, 0).UTC()
+	}
+	if createdAt.Valid {
+		p.CreatedAt = time.Unix(createdAt.Int64, 0).UTC()
+	}
+	return p, err
+}
+
+func getPoll(q queryer, pollId string) (poll.Poll, error) {
+	p, err := scanPoll(q.QueryRow("SELECT "+pollColumns+" FROM polls WHERE id = ?", pollId))
+	if err == sql.ErrNoRows {
+		return p, errors.Wrapf(poll.ErrPollNotFound, "Poll %s doesn't exist", pollId)
+	}
+	if err != nil {
+		return p, errors.Wrapf(err, "Error getting poll %s!", pollId)
+	}
+	err = loadOptions(q, &p)
+	return p, err
+}
+
+func loadOptions(q queryer, p *poll.Poll) error {
+	rows, err := q.Query("SELECT option_id, text, emoji, description, proposed_by FROM options WHERE poll_id = ? ORDER BY position", p.ID)
+	if err != nil {
+		return errors.Wrapf(err, "Error getting options for poll %s!", p.ID)
+	}
+	defer rows.Close()
+	p.Options = []poll.Option{}
+	for rows.Next() {
+		var option poll.Option
+		err = rows.Scan(&option.ID, &option.Label, &option.Emoji, &option.Description, &option.ProposedBy)
+		if err != nil {
+			return errors.Wrapf(err, "Error reading option for poll %s!", p.ID)
+		}
+		p.Options = append(p.Options, option)
+	}
+	return errors.Wrapf(rows.Err(), "Error reading options for poll %s!", p.ID)
+}
+
+func rankingToColumn(ranking []int) string {
+	rankedOptions := make([]string, len(ranking))
+	for i, option := range ranking {
+		rankedOptions[i] = strconv.Itoa(option)
+	}
+	return strings.Join(rankedOptions, ",")
+}
+
+func scanVote(row rowScanner) (poll.Vote, error) {
+	var v poll.Vote
+	var ranking string
+	err := row.Scan(&v.ID, &v.VoterID, &v.PollID, &v.VotedFor, &ranking)
+	if err != nil || ranking == "" {
+		return v, err
+	}
+	for _, rankedOption := range strings.Split(ranking, ",") {
+		option, err := strconv.Atoi(rankedOption)
+		if err != nil {
+			return v, errors.Wrapf(err, "Error reading ranking of vote %s!", v.ID)
+		}
+		v.Ranking = append(v.Ranking, option)
+	}
+	return v, nil
+}
+
+func (s *SQLiteStore) AddVote(v poll.Vote) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Error starting transaction for vote!")
+	}
+	err = checkPollExists(tx, v.PollID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO votes ("+voteColumns+") VALUES (?, ?, ?, ?, ?)",
+		v.ID, v.VoterID, v.PollID, v.VotedFor, rankingToColumn(v.Ranking))
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Error inserting vote!")
+	}
+	return errors.Wrap(tx.Commit(), "Error committing vote!")
+}
+
+// checkPollExists makes votes for deleted polls fail. It's called in the
+// transaction of the vote, which the single connection of the store
+// serializes with the deletion of the poll.
+func checkPollExists(q queryer, pollId string) error {
+	var polls int
+	err := q.QueryRow("SELECT COUNT(*) FROM polls WHERE id = ?", pollId).Scan(&polls)
+	if err != nil {
+		return errors.Wrapf(err, "Error checking for poll %s!", pollId)
+	}
+	if polls == 0 {
+		return errors.Wrapf(poll.ErrPollNotFound, "Poll %s doesn't exist", pollId)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ReplaceVote(v poll.Vote) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Error starting transaction for vote!")
+	}
+	err = checkPollExists(tx, v.PollID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM votes WHERE poll_id = ? AND voter_id = ?", v.PollID, v.VoterID)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "Error removing previous vote of voter %s for poll %s!", v.VoterID, v.PollID)
+	}
+	_, err = tx.Exec("INSERT INTO votes ("+voteColumns+") VALUES (?, ?, ?, ?, ?)",
+		v.ID, v.VoterID, v.PollID, v.VotedFor, rankingToColumn(v.Ranking))
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Error inserting vote!")
+	}
+	return errors.Wrap(tx.Commit(), "Error committing vote!")
+}
+
+// ToggleSingleVote relies on the single connection of the store, which
+// serializes the transactions.
+func (s *SQLiteStore) ToggleSingleVote(v poll.Vote) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, errors.Wrap(err, "Error starting transaction for vote!")
+	}
+	err = checkPollExists(tx, v.PollID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	var votesForOption int
+	err = tx.QueryRow("SELECT COUNT(*) FROM votes WHERE poll_id = ? AND voter_id = ? AND voted_for = ?", v.PollID, v.VoterID, v.VotedFor).Scan(&votesForOption)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrapf(err, "Error finding vote of voter %s for poll %s!", v.VoterID, v.PollID)
+	}
+	_, err = tx.Exec("DELETE FROM votes WHERE poll_id = ? AND voter_id = ?", v.PollID, v.VoterID)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrapf(err, "Error removing previous vote of voter %s for poll %s!", v.VoterID, v.PollID)
+	}
+	if votesForOption > 0 {
+		return false, errors.Wrap(tx.Commit(), "Error committing vote removal!")
+	}
+	_, err = tx.Exec("INSERT INTO votes ("+voteColumns+") VALUES (?, ?, ?, ?, ?)",
+		v.ID, v.VoterID, v.PollID, v.VotedFor, rankingToColumn(v.Ranking))
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrap(err, "Error inserting vote!")
+	}
+	return true, errors.Wrap(tx.Commit(), "Error committing vote!")
+}
+
+func (s *SQLiteStore) ToggleVote(v poll.Vote, maxChoices int) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, errors.Wrap(err, "Error starting transaction for vote!")
+	}
+	err = checkPollExists(tx, v.PollID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	result, err := tx.Exec("DELETE FROM votes WHERE poll_id = ? AND voter_id = ? AND voted_for = ?", v.PollID, v.VoterID, v.VotedFor)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrapf(err, "Error removing vote of voter %s for poll %s!", v.VoterID, v.PollID)
+	}
+	removedVotes, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrap(err, "Error checking for removed vote!")
+	}
+	if removedVotes > 0 {
+		return false, errors.Wrap(tx.Commit(), "Error committing vote removal!")
+	}
+	if maxChoices > 0 {
+		var votesOfVoter int
+		err = tx.QueryRow("SELECT COUNT(*) FROM votes WHERE poll_id = ? AND voter_id = ?", v.PollID, v.VoterID).Scan(&votesOfVoter)
+		if err != nil {
+			tx.Rollback()
+			return false, errors.Wrapf(err, "Error counting votes of voter %s for poll %s!", v.VoterID, v.PollID)
+		}
+		if votesOfVoter >= maxChoices {
+			tx.Rollback()
+			return false, poll.ErrTooManyChoices
+		}
+	}
+	_, err = tx.Exec("INSERT INTO votes ("+voteColumns+") VALUES (?, ?, ?, ?, ?)",
+		v.ID, v.VoterID, v.PollID, v.VotedFor, rankingToColumn(v.Ranking))
+	if err != nil {
+		tx.Rollback()
+		return false, errors.Wrap(err, "Error inserting vote!")
+	}
+	return true, errors.Wrap(tx.Commit(), "Error committing vote!")
+}
+
+func (s *SQLiteStore) GetPoll(pollId string) (poll.Poll, error) {
+	return getPoll(s.db, pollId)
+}
+
+func (s *SQLiteStore) UpdatePoll(pollId string, update func(p *poll.Poll) error) (poll.Poll, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return poll.Poll{}, errors.Wrap(err, "Error starting transaction for poll update!")
+	}
+	p, err := updatePoll(tx, pollId, update)
+	if err != nil {
+		tx.Rollback()
+		return poll.Poll{}, err
+	}
+	return p, errors.Wrap(tx.Commit(), "Error committing poll update!")
+}
+
+// updatePoll applies update to the poll and stores it in the transaction.
+func updatePoll(tx *sql.Tx, pollId string, update func(p *poll.Poll) error) (poll.Poll, error) {
+	p, err := getPoll(tx, pollId)
+	if err != nil {
+		return poll.Poll{}, err
+	}
+	err = update(&p)
+	if err != nil {
+		return poll.Poll{}, err
+	}
+	_, err = tx.Exec("UPDATE polls SET question = ?, creator_id = ?, anonymous = ?, type = ?, max_choices = ?, closed = ?, deadline = ?, response_url = ?, platform = ?, created_at = ?, hide_results = ?, allow_write_ins = ?, next_option_id = ? WHERE id = ?",
+		p.Question, p.CreatorID, p.Anonymous, p.Type, p.MaxChoices, p.Closed, timeToColumn(p.Deadline), p.ResponseURL, p.Platform, timeToColumn(p.CreatedAt), p.HideResults, p.AllowWriteIns, p.NextOptionID, pollId)
+	if err != nil {
+		return poll.Poll{}, errors.Wrapf(err, "Error updating poll %s!", pollId)
+	}
+	_, err = tx.Exec("DELETE FROM options WHERE poll_id = ?", pollId)
+	if err != nil {
+		return poll.Poll{}, errors.Wrapf(err, "Error removing options of poll %s!", pollId)
+	}
+	err = insertOptions(tx, p)
+	if err != nil {
+		return poll.Poll{}, err
+	}
+	return p, nil
+}
+
+func (s *SQLiteStore) GetPollsWithExpiredDeadline(now time.Time) ([]poll.Poll, error) {
+	expiredPolls, err := s.queryPolls("SELECT "+pollColumns+" FROM polls WHERE closed = 0 AND deadline <= ?", now.Unix())
+	return expiredPolls, errors.Wrap(err, "Error finding polls with expired deadline!")
+}
+
+func (s *SQLiteStore) GetPollsByCreator(creatorID string) ([]poll.Poll, error) {
+	createdPolls, err := s.queryPolls("SELECT "+pollColumns+" FROM polls WHERE creator_id = ? ORDER BY created_at DESC", creatorID)
+	return createdPolls, errors.Wrapf(err, "Error finding polls of creator %s!", creatorID)
+}
+
+// GetPollsCreatedBefore skips polls without creation time, whose created_at
+// is NULL.
+func (s *SQLiteStore) GetPollsCreatedBefore(cutoff time.Time) ([]poll.Poll, error) {
+	oldPolls, err := s.queryPolls("SELECT "+pollColumns+" FROM polls WHERE created_at < ?", cutoff.Unix())
+	return oldPolls, errors.Wrap(err, "Error finding polls created before cutoff!")
+}
+
+// queryPolls reads all polls returned by the query including their options.
+func (s *SQLiteStore) queryPolls(query string, args ...interface{}) ([]poll.Poll, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	polls := []poll.Poll{}
+	for rows.Next() {
+		p, err := scanPoll(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		polls = append(polls, p)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	// Options can only be loaded after closing rows as there is only one connection
+	for i := range polls {
+		err = loadOptions(s.db, &polls[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return polls, nil
+}
+
+func (s *SQLiteStore) DeletePoll(pollId string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "Error starting transaction for poll deletion!")
+	}
+	_, err = tx.Exec("DELETE FROM votes WHERE poll_id = ?", pollId)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "Error deleting votes of poll %s!", pollId)
+	}
+	// Foreign keys aren't enforced by SQLite by default, so options aren't
+	// removed by the cascade
+	_, err = tx.Exec("DELETE FROM options WHERE poll_id = ?", pollId)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "Error deleting options of poll %s!", pollId)
+	}
+	_, err = tx.Exec("DELETE FROM polls WHERE id = ?", pollId)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrapf(err, "Error deleting poll %s!", pollId)
+	}
+	return errors.Wrap(tx.Commit(), "Error committing poll deletion!")
+}
+
+func (s *SQLiteStore) RemoveOptions(pollId string, update func(p *poll.Poll) error, removedOptions []int) (poll.Poll, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return poll.Poll{}, errors.Wrap(err, "Error starting transaction for option removal!")
+	}
+	p, err := updatePoll(tx, pollId, func(p *poll.Poll) error {
+		err := update(p)
+		if err != nil {
+			return err
+		}
+		poll.RemoveOptionsFromPoll(p, removedOptions)
+		return nil
+	})
+	if err != nil {
+		tx.Rollback()
+		return poll.Poll{}, err
+	}
+	votes, err := queryVotes(tx, "SELECT "+voteColumns+" FROM votes WHERE poll_id = ?", pollId)
+	if err != nil {
+		tx.Rollback()
+		return poll.Poll{}, errors.Wrapf(err, "Error reading votes of poll %s!", pollId)
+	}
+	for _, v := range votes {
+		if poll.RemoveOptionsFromVote(&v, removedOptions) {
+			if v.Ranking == nil {
+				continue
+			}
+			_, err = tx.Exec("UPDATE votes SET voted_for = ?, ranking = ? WHERE id = ?", v.VotedFor, rankingToColumn(v.Ranking), v.ID)
+		} else {
+			_, err = tx.Exec("DELETE FROM votes WHERE id = ?", v.ID)
+		}
+		if err != nil {
+			tx.Rollback()
+			return poll.Poll{}, errors.Wrapf(err, "Error updating vote %s for removed options!", v.ID)
+		}
+	}
+	return p, errors.Wrap(tx.Commit(), "Error committing option removal!")
+}
+
+// queryVotes reads all votes returned by the query.
+func queryVotes(q queryer, query string, args ...interface{}) ([]poll.Vote, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	votes := []poll.Vote{}
+	for rows.Next() {
+		v, err := scanVote(rows)
+		if err != nil {
+			return nil, err
+		}
+		votes = append(votes, v)
+	}
+	return votes, rows.Err()
+}
+
+func (s *SQLiteStore) GetVote(voteId string) (poll.Vote, error) {
+	v, err := scanVote(s.db.QueryRow("SELECT "+voteColumns+" FROM votes WHERE id = ?", voteId))
+	if err != nil {
+		return v, errors.Wrapf(err, "Error getting vote %s!", voteId)
+	}
+	return v, nil
+}
+
+func (s *SQLiteStore) GetVotesForPoll(pollId string) ([]poll.Vote, error) {
+	rows, err := s.db.Query("SELECT "+voteColumns+" FROM votes WHERE poll_id = ?", pollId)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error finding votes for poll %s!", pollId)
+	}
+	defer rows.Close()
+	votes := []poll.Vote{}
+	for rows.Next() {
+		v, err := scanVote(rows)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error reading vote for poll %s!", pollId)
+		}
+		votes = append(votes, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "Error reading votes for poll %s!", pollId)
+	}
+	return votes, nil
+}
+
+func (s *SQLiteStore) PollHasVoteFromVoter(pollID, voterID string) (bool, poll.Vote, error) {
+	v, err := scanVote(s.db.QueryRow("SELECT "+voteColumns+" FROM votes WHERE poll_id = ? AND voter_id = ? LIMIT 1", pollID, voterID))
+	if err == sql.ErrNoRows {
+		return false, poll.Vote{}, nil
+	}
+	if err != nil {
+		return false, poll.Vote{}, errors.Wrapf(err, "Error searching vote from voter %s for poll %s!", voterID, pollID)
+	}
+	return true, v, nil
+}
+
+func (s *SQLiteStore) RemoveVote(voteId string) error {
+	_, err := s.db.Exec("DELETE FROM votes WHERE id = ?", voteId)
+	if err != nil {
+		return errors.Wrapf(err, "Error deleting vote %s", voteId)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) RemoveVotesOfVoter(pollID, voterID string) error {
+	_, err := s.db.Exec("DELETE FROM votes WHERE poll_id = ? AND voter_id = ?", pollID, voterID)
+	if err != nil {
+		return errors.Wrapf(err, "Error deleting votes of voter %s for poll %s", voterID, pollID)
+	}
+	return nil
+}
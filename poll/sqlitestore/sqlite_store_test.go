@@ -0,0 +1,63 @@
+package sqlitestore
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"markusreschke.name/selfhostedchatpolling/poll"
+	"markusreschke.name/selfhostedchatpolling/poll/testlib"
+)
+
+func TestMain(m *testing.M) {
+	flag.Bool("integration", false, "run integration tests")
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+func getCleanStore() poll.StoreBackend {
+	store, err := NewSQLiteStoreBackend(":memory:")
+	if err != nil {
+		fmt.Printf("Error creating store: %v\n", err)
+		os.Exit(1)
+	}
+	return store
+}
+
+func TestAllCasesInTestLib(t *testing.T) {
+	testlib.RunTests(t, getCleanStore)
+}
+
+func TestSchemaMigrationIsIdempotent(t *testing.T) {
+	dbFile, err := ioutil.TempFile("", "shcp_sqlite_test")
+	if err != nil {
+		t.Fatal("Error creating temporary db file: ", err)
+	}
+	dbFile.Close()
+	defer os.Remove(dbFile.Name())
+
+	store, err := NewSQLiteStoreBackend(dbFile.Name())
+	if err != nil {
+		t.Fatal("Error creating store: ", err)
+	}
+	testPoll := poll.Poll{ID: "1", Question: "q", CreatorID: "creator", Options: poll.NewOptions("a1", "a2")}
+	err = store.AddPoll(testPoll)
+	if err != nil {
+		t.Fatal("Error adding poll to store: ", err)
+	}
+	store.(*SQLiteStore).db.Close()
+
+	reopenedStore, err := NewSQLiteStoreBackend(dbFile.Name())
+	if err != nil {
+		t.Fatal("Error reopening store: ", err)
+	}
+	pollFromStore, err := reopenedStore.GetPoll(testPoll.ID)
+	if err != nil {
+		t.Fatal("Error getting poll from reopened store: ", err)
+	}
+	if pollFromStore.Question != testPoll.Question || len(pollFromStore.Options) != len(testPoll.Options) {
+		t.Fatalf("Expected %v but got %v", testPoll, pollFromStore)
+	}
+}
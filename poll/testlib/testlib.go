@@ -2,8 +2,12 @@ package testlib
 
 import (
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	. "markusreschke.name/selfhostedchatpolling/poll"
 )
 
@@ -13,16 +17,38 @@ func RunTests(t *testing.T, storeFactory StoreBackendFactory) {
 	t.Run("TestAddingAndRetrievingData", func(t *testing.T) { TestAddingAndRetrievingData(t, storeFactory()) })
 	t.Run("TestGettingVotesForPoll", func(t *testing.T) { TestGettingVotesForPoll(t, storeFactory()) })
 	t.Run("TestPollHasVoteFromVoter", func(t *testing.T) { TestPollHasVoteFromVoter(t, storeFactory()) })
+	t.Run("TestAddVoteOfSameVoter", func(t *testing.T) { TestAddVoteOfSameVoter(t, storeFactory()) })
 	t.Run("TestRemoveVote", func(t *testing.T) { TestRemoveVote(t, storeFactory()) })
+	t.Run("TestRemoveVotesOfVoter", func(t *testing.T) { TestRemoveVotesOfVoter(t, storeFactory()) })
+	t.Run("TestRetractVote", func(t *testing.T) { TestRetractVote(t, storeFactory()) })
+	t.Run("TestStoringRankedVote", func(t *testing.T) { TestStoringRankedVote(t, storeFactory()) })
+	t.Run("TestReplaceVote", func(t *testing.T) { TestReplaceVote(t, storeFactory()) })
+	t.Run("TestConcurrentReplaceVote", func(t *testing.T) { TestConcurrentReplaceVote(t, storeFactory()) })
+	t.Run("TestToggleVote", func(t *testing.T) { TestToggleVote(t, storeFactory()) })
+	t.Run("TestConcurrentToggleVote", func(t *testing.T) { TestConcurrentToggleVote(t, storeFactory()) })
+	t.Run("TestToggleSingleVote", func(t *testing.T) { TestToggleSingleVote(t, storeFactory()) })
+	t.Run("TestConcurrentToggleSingleVote", func(t *testing.T) { TestConcurrentToggleSingleVote(t, storeFactory()) })
+	t.Run("TestUpdatePoll", func(t *testing.T) { TestUpdatePoll(t, storeFactory()) })
+	t.Run("TestFailedUpdatePoll", func(t *testing.T) { TestFailedUpdatePoll(t, storeFactory()) })
+	t.Run("TestGetPollsWithExpiredDeadline", func(t *testing.T) { TestGetPollsWithExpiredDeadline(t, storeFactory()) })
+	t.Run("TestGetPollsByCreator", func(t *testing.T) { TestGetPollsByCreator(t, storeFactory()) })
+	t.Run("TestDeletePoll", func(t *testing.T) { TestDeletePoll(t, storeFactory()) })
+	t.Run("TestVoteForDeletedPoll", func(t *testing.T) { TestVoteForDeletedPoll(t, storeFactory()) })
+	t.Run("TestGetPollsCreatedBefore", func(t *testing.T) { TestGetPollsCreatedBefore(t, storeFactory()) })
+	t.Run("TestRemoveOptions", func(t *testing.T) { TestRemoveOptions(t, storeFactory()) })
+	t.Run("TestRemoveOptionsWithUpdate", func(t *testing.T) { TestRemoveOptionsWithUpdate(t, storeFactory()) })
+	t.Run("TestAddOptionAfterRemovingHighestOption", func(t *testing.T) { TestAddOptionAfterRemovingHighestOption(t, storeFactory()) })
 }
 
 func TestAddingAndRetrievingData(t *testing.T, store StoreBackend) {
-	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: []string{"a1", "a2", "a3"}}
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3"), Platform: "slack", HideResults: true}
+	poll.Options[1].Emoji = ":pizza:"
+	poll.Options[2] = Option{ID: 5, Label: "a3", Description: "d", ProposedBy: "voter"}
 	err := store.AddPoll(poll)
 	if err != nil {
 		t.Fatalf("Error creating poll: %v", err)
 	}
-	vote := Vote{"1", "voter", "1", 0}
+	vote := Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0}
 	err = store.AddVote(vote)
 	if err != nil {
 		t.Fatalf("Error creating vote: %v", err)
@@ -38,12 +64,12 @@ func TestAddingAndRetrievingData(t *testing.T, store StoreBackend) {
 }
 
 func TestRemoveVote(t *testing.T, store StoreBackend) {
-	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: []string{"a1", "a2", "a3"}}
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3")}
 	err := store.AddPoll(poll)
 	if err != nil {
 		t.Fatal("Error adding poll to store!: ", err)
 	}
-	vote := Vote{"1", "voter", "1", 0}
+	vote := Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0}
 	err = store.AddVote(vote)
 	if err != nil {
 		t.Fatal("Error adding vote to store!: ", err)
@@ -63,8 +89,350 @@ func TestRemoveVote(t *testing.T, store StoreBackend) {
 	}
 }
 
+// TestAddVoteOfSameVoter checks that AddVote keeps the other votes of the
+// voter, only ReplaceVote replaces them.
+func TestAddVoteOfSameVoter(t *testing.T, store StoreBackend) {
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3")}
+	err := store.AddPoll(poll)
+	if err != nil {
+		t.Fatal("Error adding poll to store!: ", err)
+	}
+	votes := []Vote{{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0}, {ID: "2", VoterID: "voter", PollID: "1", VotedFor: 2}}
+	for _, vote := range votes {
+		err = store.AddVote(vote)
+		if err != nil {
+			t.Fatalf("Error adding vote %v to store!: %v", vote, err)
+		}
+	}
+	result, err := store.GetVotesForPoll(poll.ID)
+	if err != nil {
+		t.Fatalf("Error while fetching votes for Poll %s: %v", poll.ID, err)
+	}
+	if len(result) != len(votes) {
+		t.Fatalf("Expected votes %v but got %v", votes, result)
+	}
+	compareVotes(t, votes, result)
+}
+
+func TestRemoveVotesOfVoter(t *testing.T, store StoreBackend) {
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3"), Type: PollTypeMultipleChoice}
+	err := store.AddPoll(poll)
+	if err != nil {
+		t.Fatal("Error adding poll to store!: ", err)
+	}
+	for _, vote := range []Vote{{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0}, {ID: "2", VoterID: "voter", PollID: "1", VotedFor: 2}, {ID: "3", VoterID: "voter2", PollID: "1", VotedFor: 0}} {
+		_, err = store.ToggleVote(vote, 0)
+		if err != nil {
+			t.Fatalf("Error adding vote %v to store!: %v", vote, err)
+		}
+	}
+	err = store.RemoveVotesOfVoter(poll.ID, "voter")
+	if err != nil {
+		t.Fatal("Error removing votes of voter!: ", err)
+	}
+	err = store.RemoveVotesOfVoter(poll.ID, "voter3")
+	if err != nil {
+		t.Fatal("Error removing votes of voter without votes!: ", err)
+	}
+	result, err := store.GetVotesForPoll(poll.ID)
+	if err != nil {
+		t.Fatalf("Error while fetching votes for Poll %s: %v", poll.ID, err)
+	}
+	expectedVotes := []Vote{{ID: "3", VoterID: "voter2", PollID: "1", VotedFor: 0}}
+	if len(result) != len(expectedVotes) {
+		t.Fatalf("Expected votes %v but got %v", expectedVotes, result)
+	}
+	compareVotes(t, expectedVotes, result)
+	// The voter can vote again after the retraction
+	added, err := store.ToggleVote(Vote{ID: "4", VoterID: "voter", PollID: "1", VotedFor: 0}, 1)
+	if err != nil || !added {
+		t.Fatalf("Expected vote after removing the votes of the voter to be added, got %t and error %v", added, err)
+	}
+}
+
+// TestRetractVote checks the retraction of votes through the DefaultStore, for
+// single choice polls also by voting for the selected option again.
+func TestRetractVote(t *testing.T, backend StoreBackend) {
+	store := NewDefaultStore(backend)
+	polls := []Poll{
+		{ID: "single", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2")},
+		{ID: "ranked", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2"), Type: PollTypeRankedChoice},
+		{ID: "closed", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2")},
+	}
+	for _, poll := range polls {
+		err := store.AddPoll(poll)
+		if err != nil {
+			t.Fatal("Error adding poll to store!: ", err)
+		}
+	}
+	votes := []Vote{
+		{ID: "1", VoterID: "voter", PollID: "single", VotedFor: 0},
+		{ID: "2", VoterID: "voter2", PollID: "single", VotedFor: 1},
+		{ID: "3", VoterID: "voter", PollID: "ranked", VotedFor: 0, Ranking: []int{1, 0}},
+		{ID: "4", VoterID: "voter", PollID: "closed", VotedFor: 0},
+	}
+	for _, vote := range votes {
+		err := store.AddVote(vote)
+		if err != nil {
+			t.Fatalf("Error adding vote %v to store!: %v", vote, err)
+		}
+	}
+	_, err := store.ClosePoll("closed", "creator")
+	if err != nil {
+		t.Fatal("Error closing poll!: ", err)
+	}
+	for _, pollID := range []string{"single", "ranked"} {
+		err = store.RetractVote(pollID, "voter")
+		if err != nil {
+			t.Fatalf("Error retracting vote for poll %s!: %v", pollID, err)
+		}
+		if isVotePresent, _, _ := backend.PollHasVoteFromVoter(pollID, "voter"); isVotePresent {
+			t.Fatalf("Vote for poll %s is still present after retraction!", pollID)
+		}
+	}
+	if isVotePresent, _, _ := backend.PollHasVoteFromVoter("single", "voter2"); !isVotePresent {
+		t.Fatal("Retraction removed the vote of another voter!")
+	}
+	err = store.AddVote(Vote{ID: "5", VoterID: "voter2", PollID: "single", VotedFor: 1})
+	if err != nil {
+		t.Fatal("Error voting for the selected option again!: ", err)
+	}
+	if isVotePresent, _, _ := backend.PollHasVoteFromVoter("single", "voter2"); isVotePresent {
+		t.Fatal("Voting for the selected option again didn't retract the vote!")
+	}
+	err = store.RetractVote("closed", "voter")
+	if err == nil {
+		t.Fatal("Expected error when retracting vote for closed poll!")
+	}
+	if isVotePresent, _, _ := backend.PollHasVoteFromVoter("closed", "voter"); !isVotePresent {
+		t.Fatal("Vote for closed poll was retracted!")
+	}
+}
+
+func TestStoringRankedVote(t *testing.T, store StoreBackend) {
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3"), Type: PollTypeRankedChoice}
+	err := store.AddPoll(poll)
+	if err != nil {
+		t.Fatal("Error adding poll to store!: ", err)
+	}
+	vote := Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 2, Ranking: []int{2, 0}}
+	err = store.ReplaceVote(vote)
+	if err != nil {
+		t.Fatal("Error adding vote to store!: ", err)
+	}
+	voteFromStore, err := store.GetVote("1")
+	if err != nil {
+		t.Fatal("Error fetching vote from store!: ", err)
+	}
+	if !reflect.DeepEqual(vote, voteFromStore) {
+		t.Fatalf("Expected %v but got %v", vote, voteFromStore)
+	}
+	votes, err := store.GetVotesForPoll("1")
+	if err != nil {
+		t.Fatal("Error fetching votes from store!: ", err)
+	}
+	compareVotes(t, []Vote{vote}, votes)
+}
+
+func TestReplaceVote(t *testing.T, store StoreBackend) {
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3")}
+	err := store.AddPoll(poll)
+	if err != nil {
+		t.Fatal("Error adding poll to store!: ", err)
+	}
+	otherVote := Vote{ID: "1", VoterID: "voter2", PollID: "1", VotedFor: 2}
+	err = store.ReplaceVote(otherVote)
+	if err != nil {
+		t.Fatal("Error adding vote of other voter to store!: ", err)
+	}
+	err = store.ReplaceVote(Vote{ID: "2", VoterID: "voter", PollID: "1", VotedFor: 0})
+	if err != nil {
+		t.Fatal("Error adding first vote to store!: ", err)
+	}
+	changedVote := Vote{ID: "3", VoterID: "voter", PollID: "1", VotedFor: 1}
+	err = store.ReplaceVote(changedVote)
+	if err != nil {
+		t.Fatal("Error replacing vote in store!: ", err)
+	}
+	result, err := store.GetVotesForPoll(poll.ID)
+	if err != nil {
+		t.Fatalf("Error while fetching votes for Poll %s: %v", poll.ID, err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 votes after replacing a vote but got %v", result)
+	}
+	compareVotes(t, []Vote{otherVote, changedVote}, result)
+}
+
+// TestConcurrentReplaceVote simulates a voter clicking on several options in
+// rapid succession.
+func TestConcurrentReplaceVote(t *testing.T, store StoreBackend) {
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3")}
+	err := store.AddPoll(poll)
+	if err != nil {
+		t.Fatal("Error adding poll to store!: ", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := store.ReplaceVote(Vote{ID: strconv.Itoa(i), VoterID: "voter", PollID: poll.ID, VotedFor: i % len(poll.Options)})
+			if err != nil {
+				t.Error("Error replacing vote in store!: ", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	result, err := store.GetVotesForPoll(poll.ID)
+	if err != nil {
+		t.Fatalf("Error while fetching votes for Poll %s: %v", poll.ID, err)
+	}
+	if len(result) != 1 {
+		t.Fatalf("Expected exactly one vote to survive but got %v", result)
+	}
+}
+
+func TestToggleVote(t *testing.T, store StoreBackend) {
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3"), Type: PollTypeMultipleChoice, MaxChoices: 2}
+	err := store.AddPoll(poll)
+	if err != nil {
+		t.Fatal("Error adding poll to store!: ", err)
+	}
+	toggles := []struct {
+		vote          Vote
+		expectedAdded bool
+		expectedErr   error
+	}{
+		{Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0}, true, nil},
+		{Vote{ID: "2", VoterID: "voter", PollID: "1", VotedFor: 1}, true, nil},
+		{Vote{ID: "3", VoterID: "voter", PollID: "1", VotedFor: 2}, false, ErrTooManyChoices},
+		{Vote{ID: "4", VoterID: "voter2", PollID: "1", VotedFor: 2}, true, nil},
+		{Vote{ID: "5", VoterID: "voter", PollID: "1", VotedFor: 0}, false, nil},
+		{Vote{ID: "6", VoterID: "voter", PollID: "1", VotedFor: 2}, true, nil},
+	}
+	for _, toggle := range toggles {
+		added, err := store.ToggleVote(toggle.vote, poll.MaxChoices)
+		if err != toggle.expectedErr {
+			t.Fatalf("Expected error %v when toggling vote %v but got %v", toggle.expectedErr, toggle.vote, err)
+		}
+		if added != toggle.expectedAdded {
+			t.Fatalf("Expected added to be %t when toggling vote %v", toggle.expectedAdded, toggle.vote)
+		}
+	}
+	result, err := store.GetVotesForPoll(poll.ID)
+	if err != nil {
+		t.Fatalf("Error while fetching votes for Poll %s: %v", poll.ID, err)
+	}
+	expectedVotes := []Vote{
+		{ID: "2", VoterID: "voter", PollID: "1", VotedFor: 1},
+		{ID: "4", VoterID: "voter2", PollID: "1", VotedFor: 2},
+		{ID: "6", VoterID: "voter", PollID: "1", VotedFor: 2},
+	}
+	if len(result) != len(expectedVotes) {
+		t.Fatalf("Expected votes %v but got %v", expectedVotes, result)
+	}
+	compareVotes(t, expectedVotes, result)
+}
+
+// TestConcurrentToggleVote simulates a voter selecting many options of a
+// multiple choice poll in rapid succession.
+func TestConcurrentToggleVote(t *testing.T, store StoreBackend) {
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3", "a4", "a5", "a6"), Type: PollTypeMultipleChoice, MaxChoices: 3}
+	err := store.AddPoll(poll)
+	if err != nil {
+		t.Fatal("Error adding poll to store!: ", err)
+	}
+	var wg sync.WaitGroup
+	for i := range poll.Options {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := store.ToggleVote(Vote{ID: strconv.Itoa(i), VoterID: "voter", PollID: poll.ID, VotedFor: i}, poll.MaxChoices)
+			if err != nil && err != ErrTooManyChoices {
+				t.Error("Error toggling vote in store!: ", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	result, err := store.GetVotesForPoll(poll.ID)
+	if err != nil {
+		t.Fatalf("Error while fetching votes for Poll %s: %v", poll.ID, err)
+	}
+	if len(result) != poll.MaxChoices {
+		t.Fatalf("Expected exactly %d votes but got %v", poll.MaxChoices, result)
+	}
+}
+
+func TestToggleSingleVote(t *testing.T, store StoreBackend) {
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3")}
+	err := store.AddPoll(poll)
+	if err != nil {
+		t.Fatal("Error adding poll to store!: ", err)
+	}
+	toggles := []struct {
+		vote          Vote
+		expectedAdded bool
+	}{
+		{Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0}, true},
+		{Vote{ID: "2", VoterID: "voter", PollID: "1", VotedFor: 1}, true},
+		{Vote{ID: "3", VoterID: "voter2", PollID: "1", VotedFor: 2}, true},
+		{Vote{ID: "4", VoterID: "voter", PollID: "1", VotedFor: 1}, false},
+		{Vote{ID: "5", VoterID: "voter", PollID: "1", VotedFor: 2}, true},
+	}
+	for _, toggle := range toggles {
+		added, err := store.ToggleSingleVote(toggle.vote)
+		if err != nil {
+			t.Fatalf("Error toggling vote %v: %v", toggle.vote, err)
+		}
+		if added != toggle.expectedAdded {
+			t.Fatalf("Expected added to be %t when toggling vote %v", toggle.expectedAdded, toggle.vote)
+		}
+	}
+	result, err := store.GetVotesForPoll(poll.ID)
+	if err != nil {
+		t.Fatalf("Error while fetching votes for Poll %s: %v", poll.ID, err)
+	}
+	expectedVotes := []Vote{{ID: "3", VoterID: "voter2", PollID: "1", VotedFor: 2}, {ID: "5", VoterID: "voter", PollID: "1", VotedFor: 2}}
+	if len(result) != len(expectedVotes) {
+		t.Fatalf("Expected votes %v but got %v", expectedVotes, result)
+	}
+	compareVotes(t, expectedVotes, result)
+}
+
+// TestConcurrentToggleSingleVote simulates a voter clicking the same option
+// of a single choice poll several times in rapid succession. Every click
+// toggles the vote, so an even number of clicks leaves no vote.
+func TestConcurrentToggleSingleVote(t *testing.T, store StoreBackend) {
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2")}
+	err := store.AddPoll(poll)
+	if err != nil {
+		t.Fatal("Error adding poll to store!: ", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := store.ToggleSingleVote(Vote{ID: strconv.Itoa(i), VoterID: "voter", PollID: poll.ID, VotedFor: 0})
+			if err != nil {
+				t.Error("Error toggling vote in store!: ", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	result, err := store.GetVotesForPoll(poll.ID)
+	if err != nil {
+		t.Fatalf("Error while fetching votes for Poll %s: %v", poll.ID, err)
+	}
+	if len(result) != 0 {
+		t.Fatalf("Expected no vote after an even number of toggles but got %v", result)
+	}
+}
+
 func TestPollHasVoteFromVoter(t *testing.T, store StoreBackend) {
-	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: []string{"a1", "a2", "a3"}}
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3")}
+	store.AddPoll(poll)
 	voterID := "voter"
 	isVotePresent, storedVote, err := store.PollHasVoteFromVoter(poll.ID, voterID)
 	if err != nil || isVotePresent {
@@ -74,7 +442,7 @@ func TestPollHasVoteFromVoter(t *testing.T, store StoreBackend) {
 			t.Fatalf("Vote found for Voter %s in empty store!", voterID)
 		}
 	}
-	vote := Vote{"1", voterID, "1", 0}
+	vote := Vote{ID: "1", VoterID: voterID, PollID: "1", VotedFor: 0}
 	err = store.AddVote(vote)
 	if err != nil {
 		t.Fatalf("Error creating vote: %v", err)
@@ -87,18 +455,18 @@ func TestPollHasVoteFromVoter(t *testing.T, store StoreBackend) {
 			t.Fatalf("Vote not found for Voter %s after adding it!", voterID)
 		}
 	}
-	if vote != storedVote {
+	if !reflect.DeepEqual(vote, storedVote) {
 		t.Fatalf("Stored Vote (%v) not the same as the one found in store (%v)!", storedVote, vote)
 
 	}
 }
 
 func TestGettingVotesForPoll(t *testing.T, store StoreBackend) {
-	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: []string{"a1", "a2", "a3"}}
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3")}
 	votes := []Vote{
-		{"1", "voter", "1", 0},
-		{"2", "voter2", "1", 0},
-		{"3", "voter3", "1", 2},
+		{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0},
+		{ID: "2", VoterID: "voter2", PollID: "1", VotedFor: 0},
+		{ID: "3", VoterID: "voter3", PollID: "1", VotedFor: 2},
 	}
 	store.AddPoll(poll)
 	for _, vote := range votes {
@@ -116,7 +484,7 @@ expectedLoop:
 	for _, expectedVote := range expected {
 		for _, actualVote := range actual {
 			if expectedVote.ID == actualVote.ID {
-				if expectedVote == actualVote {
+				if reflect.DeepEqual(expectedVote, actualVote) {
 					continue expectedLoop
 				} else {
 					t.Fatalf("Expected %v but got %v", expectedVote, actualVote)
@@ -126,3 +494,378 @@ expectedLoop:
 		t.Fatalf("No matching vote found for ID %s", expectedVote.ID)
 	}
 }
+
+func TestUpdatePoll(t *testing.T, store StoreBackend) {
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3")}
+	err := store.AddPoll(poll)
+	if err != nil {
+		t.Fatal("Error adding poll to store!: ", err)
+	}
+	deadline := time.Now().Add(time.Hour).Truncate(time.Second)
+	updatedPoll, err := store.UpdatePoll(poll.ID, func(p *Poll) error {
+		p.Closed = true
+		p.Deadline = deadline
+		p.ResponseURL = "https://example.com/response"
+		p.HideResults = true
+		p.AllowWriteIns = true
+		p.Options = append(p.Options, Option{ID: 3, Label: "a4", ProposedBy: "voter"})
+		return nil
+	})
+	if err != nil {
+		t.Fatal("Error updating poll!: ", err)
+	}
+	pollFromStore, err := store.GetPoll(poll.ID)
+	if err != nil {
+		t.Fatal("Error getting updated poll from store!: ", err)
+	}
+	for _, p := range []Poll{updatedPoll, pollFromStore} {
+		if p.ID != poll.ID || !p.Closed || !p.Deadline.Equal(deadline) || p.ResponseURL != "https://example.com/response" || !p.HideResults || !p.AllowWriteIns {
+			t.Fatalf("Poll %v wasn't updated as expected!", p)
+		}
+		expectedOptions := append(NewOptions("a1", "a2", "a3"), Option{ID: 3, Label: "a4", ProposedBy: "voter"})
+		if !reflect.DeepEqual(expectedOptions, p.Options) {
+			t.Fatalf("Expected options %v but got %v", expectedOptions, p.Options)
+		}
+	}
+}
+
+func TestRemoveOptions(t *testing.T, store StoreBackend) {
+	polls := []Poll{
+		{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3", "a4"), Type: PollTypeMultipleChoice},
+		{ID: "2", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3"), Type: PollTypeRankedChoice},
+	}
+	for _, poll := range polls {
+		err := store.AddPoll(poll)
+		if err != nil {
+			t.Fatal("Error adding poll to store!: ", err)
+		}
+	}
+	for _, vote := range []Vote{{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0}, {ID: "2", VoterID: "voter", PollID: "1", VotedFor: 2}, {ID: "3", VoterID: "voter", PollID: "1", VotedFor: 3}, {ID: "4", VoterID: "voter2", PollID: "1", VotedFor: 0}} {
+		_, err := store.ToggleVote(vote, 0)
+		if err != nil {
+			t.Fatalf("Error adding vote %v to store!: %v", vote, err)
+		}
+	}
+	for _, vote := range []Vote{{ID: "5", VoterID: "voter", PollID: "2", VotedFor: 1, Ranking: []int{1, 0, 2}}, {ID: "6", VoterID: "voter2", PollID: "2", VotedFor: 1, Ranking: []int{1}}} {
+		err := store.ReplaceVote(vote)
+		if err != nil {
+			t.Fatalf("Error adding vote %v to store!: %v", vote, err)
+		}
+	}
+	testCases := []struct {
+		pollID          string
+		removedOptions  []int
+		expectedOptions []Option
+		expectedVotes   []Vote
+	}{
+		{"1", []int{1, 0}, []Option{{ID: 2, Label: "a3"}, {ID: 3, Label: "a4"}}, []Vote{{ID: "2", VoterID: "voter", PollID: "1", VotedFor: 2}, {ID: "3", VoterID: "voter", PollID: "1", VotedFor: 3}}},
+		{"2", []int{1}, []Option{{ID: 0, Label: "a1"}, {ID: 2, Label: "a3"}}, []Vote{{ID: "5", VoterID: "voter", PollID: "2", VotedFor: 0, Ranking: []int{0, 2}}}},
+	}
+	for _, testCase := range testCases {
+		updatedPoll, err := store.RemoveOptions(testCase.pollID, func(p *Poll) error { return nil }, testCase.removedOptions)
+		if err != nil {
+			t.Fatalf("Error removing options of poll %s!: %v", testCase.pollID, err)
+		}
+		pollFromStore, err := store.GetPoll(testCase.pollID)
+		if err != nil {
+			t.Fatal("Error getting poll from store!: ", err)
+		}
+		for _, p := range []Poll{updatedPoll, pollFromStore} {
+			if !reflect.DeepEqual(testCase.expectedOptions, p.Options) {
+				t.Fatalf("Expected options %v but got %v", testCase.expectedOptions, p.Options)
+			}
+		}
+		votes, err := store.GetVotesForPoll(testCase.pollID)
+		if err != nil {
+			t.Fatalf("Error while fetching votes for Poll %s: %v", testCase.pollID, err)
+		}
+		if len(votes) != len(testCase.expectedVotes) {
+			t.Fatalf("Expected votes %v but got %v", testCase.expectedVotes, votes)
+		}
+		compareVotes(t, testCase.expectedVotes, votes)
+	}
+	// Toggling a kept vote still removes it
+	added, err := store.ToggleVote(Vote{ID: "7", VoterID: "voter", PollID: "1", VotedFor: 3}, 0)
+	if err != nil || added {
+		t.Fatalf("Expected kept vote to be removed, got %t and error %v", added, err)
+	}
+	votes, err := store.GetVotesForPoll("1")
+	if err != nil || len(votes) != 1 || votes[0].ID != "2" {
+		t.Fatalf("Expected only vote 2 after toggling kept vote but got %v and error %v", votes, err)
+	}
+}
+
+func TestRemoveOptionsWithUpdate(t *testing.T, store StoreBackend) {
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3"), Type: PollTypeMultipleChoice}
+	err := store.AddPoll(poll)
+	if err != nil {
+		t.Fatal("Error adding poll to store!: ", err)
+	}
+	for _, vote := range []Vote{{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0}, {ID: "2", VoterID: "voter", PollID: "1", VotedFor: 1}} {
+		_, err = store.ToggleVote(vote, 0)
+		if err != nil {
+			t.Fatalf("Error adding vote %v to store!: %v", vote, err)
+		}
+	}
+	_, err = store.RemoveOptions(poll.ID, func(p *Poll) error {
+		p.Question = "changed"
+		p.AddOption("a4")
+		return errors.New("failed update")
+	}, []int{0})
+	if err == nil {
+		t.Fatal("Expected error from failed update")
+	}
+	pollFromStore, err := store.GetPoll(poll.ID)
+	if err != nil {
+		t.Fatal("Error getting poll from store!: ", err)
+	}
+	if pollFromStore.Question != "q" || !reflect.DeepEqual(poll.Options, pollFromStore.Options) {
+		t.Fatalf("Expected unchanged poll after failed update but got %v", pollFromStore)
+	}
+	votes, err := store.GetVotesForPoll(poll.ID)
+	if err != nil || len(votes) != 2 {
+		t.Fatalf("Expected both votes after failed update but got %v and error %v", votes, err)
+	}
+	updatedPoll, err := store.RemoveOptions(poll.ID, func(p *Poll) error {
+		p.Question = "changed"
+		p.AddOption("a4")
+		return nil
+	}, []int{0})
+	if err != nil {
+		t.Fatal("Error removing options!: ", err)
+	}
+	pollFromStore, err = store.GetPoll(poll.ID)
+	if err != nil {
+		t.Fatal("Error getting poll from store!: ", err)
+	}
+	expectedOptions := []Option{{ID: 1, Label: "a2"}, {ID: 2, Label: "a3"}, {ID: 3, Label: "a4"}}
+	for _, p := range []Poll{updatedPoll, pollFromStore} {
+		if p.Question != "changed" || !reflect.DeepEqual(expectedOptions, p.Options) {
+			t.Fatalf("Expected question changed and options %v but got %v", expectedOptions, p)
+		}
+	}
+	votes, err = store.GetVotesForPoll(poll.ID)
+	if err != nil || len(votes) != 1 || votes[0].ID != "2" {
+		t.Fatalf("Expected only vote 2 after removing option 0 but got %v and error %v", votes, err)
+	}
+}
+
+func TestAddOptionAfterRemovingHighestOption(t *testing.T, store StoreBackend) {
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3")}
+	err := store.AddPoll(poll)
+	if err != nil {
+		t.Fatal("Error adding poll to store!: ", err)
+	}
+	_, err = store.RemoveOptions(poll.ID, func(p *Poll) error { return nil }, []int{2})
+	if err != nil {
+		t.Fatal("Error removing option!: ", err)
+	}
+	var addedOption Option
+	_, err = store.UpdatePoll(poll.ID, func(p *Poll) error {
+		addedOption = p.AddOption("a4")
+		return nil
+	})
+	if err != nil {
+		t.Fatal("Error adding option!: ", err)
+	}
+	if addedOption.ID != 3 {
+		t.Fatalf("Expected new option to get ID 3 but got %d", addedOption.ID)
+	}
+	pollFromStore, err := store.GetPoll(poll.ID)
+	if err != nil {
+		t.Fatal("Error getting poll from store!: ", err)
+	}
+	expectedOptions := []Option{{ID: 0, Label: "a1"}, {ID: 1, Label: "a2"}, {ID: 3, Label: "a4"}}
+	if !reflect.DeepEqual(expectedOptions, pollFromStore.Options) || pollFromStore.NextOptionID != 4 {
+		t.Fatalf("Expected options %v and next option ID 4 but got %v and %d", expectedOptions, pollFromStore.Options, pollFromStore.NextOptionID)
+	}
+}
+
+func TestFailedUpdatePoll(t *testing.T, store StoreBackend) {
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2", "a3")}
+	err := store.AddPoll(poll)
+	if err != nil {
+		t.Fatal("Error adding poll to store!: ", err)
+	}
+	updateErr := errors.New("update failed")
+	_, err = store.UpdatePoll(poll.ID, func(p *Poll) error {
+		p.Closed = true
+		return updateErr
+	})
+	if err != updateErr {
+		t.Fatalf("Expected error %v but got %v", updateErr, err)
+	}
+	pollFromStore, err := store.GetPoll(poll.ID)
+	if err != nil {
+		t.Fatal("Error getting poll from store!: ", err)
+	}
+	if pollFromStore.Closed {
+		t.Fatal("Poll was changed although its update failed!")
+	}
+}
+
+func TestGetPollsWithExpiredDeadline(t *testing.T, store StoreBackend) {
+	now := time.Now().Truncate(time.Second)
+	polls := []Poll{
+		{ID: "1", Question: "expired", CreatorID: "creator", Options: NewOptions("a1", "a2"), Deadline: now.Add(-time.Minute)},
+		{ID: "2", Question: "open", CreatorID: "creator", Options: NewOptions("a1", "a2"), Deadline: now.Add(time.Minute)},
+		{ID: "3", Question: "no deadline", CreatorID: "creator", Options: NewOptions("a1", "a2")},
+		{ID: "4", Question: "closed", CreatorID: "creator", Options: NewOptions("a1", "a2"), Deadline: now.Add(-time.Minute), Closed: true},
+	}
+	for _, poll := range polls {
+		err := store.AddPoll(poll)
+		if err != nil {
+			t.Fatal("Error adding poll to store!: ", err)
+		}
+	}
+	expiredPolls, err := store.GetPollsWithExpiredDeadline(now)
+	if err != nil {
+		t.Fatal("Error getting polls with expired deadline!: ", err)
+	}
+	if len(expiredPolls) != 1 || expiredPolls[0].ID != "1" {
+		t.Fatalf("Expected only poll 1 to be expired but got %v", expiredPolls)
+	}
+	if !reflect.DeepEqual(polls[0].Options, expiredPolls[0].Options) {
+		t.Fatalf("Expected options %v but got %v", polls[0].Options, expiredPolls[0].Options)
+	}
+}
+
+func TestGetPollsByCreator(t *testing.T, store StoreBackend) {
+	now := time.Now().Truncate(time.Second)
+	polls := []Poll{
+		{ID: "1", Question: "older", CreatorID: "creator", Options: NewOptions("a1", "a2"), CreatedAt: now.Add(-time.Hour)},
+		{ID: "2", Question: "other creator", CreatorID: "creator2", Options: NewOptions("a1", "a2"), CreatedAt: now},
+		{ID: "3", Question: "newer", CreatorID: "creator", Options: NewOptions("a1", "a2"), CreatedAt: now},
+	}
+	for _, poll := range polls {
+		err := store.AddPoll(poll)
+		if err != nil {
+			t.Fatal("Error adding poll to store!: ", err)
+		}
+	}
+	createdPolls, err := store.GetPollsByCreator("creator")
+	if err != nil {
+		t.Fatal("Error getting polls of creator!: ", err)
+	}
+	if len(createdPolls) != 2 || createdPolls[0].ID != "3" || createdPolls[1].ID != "1" {
+		t.Fatalf("Expected polls 3 and 1 but got %v", createdPolls)
+	}
+	if !createdPolls[0].CreatedAt.Equal(now) || !reflect.DeepEqual(polls[2].Options, createdPolls[0].Options) {
+		t.Fatalf("Expected poll %v but got %v", polls[2], createdPolls[0])
+	}
+}
+
+func TestGetPollsCreatedBefore(t *testing.T, store StoreBackend) {
+	now := time.Now().UTC().Truncate(time.Second)
+	polls := []Poll{
+		{ID: "1", Question: "old", CreatorID: "creator", Options: NewOptions("a1", "a2"), CreatedAt: now.Add(-48 * time.Hour)},
+		{ID: "2", Question: "new", CreatorID: "creator", Options: NewOptions("a1", "a2"), CreatedAt: now},
+		{ID: "3", Question: "without creation time", CreatorID: "creator", Options: NewOptions("a1", "a2")},
+	}
+	for _, poll := range polls {
+		err := store.AddPoll(poll)
+		if err != nil {
+			t.Fatal("Error adding poll to store!: ", err)
+		}
+	}
+	oldPolls, err := store.GetPollsCreatedBefore(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatal("Error getting old polls!: ", err)
+	}
+	if len(oldPolls) != 1 || oldPolls[0].ID != "1" || !reflect.DeepEqual(polls[0].Options, oldPolls[0].Options) {
+		t.Fatalf("Expected only poll 1 but got %v", oldPolls)
+	}
+}
+
+func TestDeletePoll(t *testing.T, store StoreBackend) {
+	polls := []Poll{
+		{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2"), Type: PollTypeMultipleChoice},
+		{ID: "2", Question: "q2", CreatorID: "creator", Options: NewOptions("a1", "a2")},
+	}
+	for _, poll := range polls {
+		err := store.AddPoll(poll)
+		if err != nil {
+			t.Fatal("Error adding poll to store!: ", err)
+		}
+	}
+	_, err := store.ToggleVote(Vote{ID: "1", VoterID: "voter", PollID: "1", VotedFor: 0}, 0)
+	if err != nil {
+		t.Fatal("Error adding vote to store!: ", err)
+	}
+	otherVote := Vote{ID: "2", VoterID: "voter", PollID: "2", VotedFor: 1}
+	err = store.ReplaceVote(otherVote)
+	if err != nil {
+		t.Fatal("Error adding vote to store!: ", err)
+	}
+	err = store.DeletePoll("1")
+	if err != nil {
+		t.Fatal("Error deleting poll!: ", err)
+	}
+	_, err = store.GetPoll("1")
+	if errors.Cause(err) != ErrPollNotFound {
+		t.Fatal("Expected ErrPollNotFound for deleted poll but got: ", err)
+	}
+	_, err = store.UpdatePoll("1", func(p *Poll) error { return nil })
+	if errors.Cause(err) != ErrPollNotFound {
+		t.Fatal("Expected ErrPollNotFound when updating deleted poll but got: ", err)
+	}
+	votes, err := store.GetVotesForPoll("1")
+	if err != nil {
+		t.Fatal("Error fetching votes of deleted poll!: ", err)
+	}
+	if len(votes) != 0 {
+		t.Fatalf("Expected votes of deleted poll to be removed but got %v", votes)
+	}
+	remainingPolls, err := store.GetPollsByCreator("creator")
+	if err != nil {
+		t.Fatal("Error getting polls of creator!: ", err)
+	}
+	if len(remainingPolls) != 1 || remainingPolls[0].ID != "2" {
+		t.Fatalf("Expected only poll 2 to remain but got %v", remainingPolls)
+	}
+	votes, err = store.GetVotesForPoll("2")
+	if err != nil {
+		t.Fatal("Error fetching votes of remaining poll!: ", err)
+	}
+	compareVotes(t, []Vote{otherVote}, votes)
+}
+
+// TestVoteForDeletedPoll checks that votes for a deleted poll fail instead of
+// leaving votes without poll behind.
+func TestVoteForDeletedPoll(t *testing.T, store StoreBackend) {
+	poll := Poll{ID: "1", Question: "q", CreatorID: "creator", Options: NewOptions("a1", "a2")}
+	err := store.AddPoll(poll)
+	if err != nil {
+		t.Fatal("Error adding poll to store!: ", err)
+	}
+	err = store.DeletePoll(poll.ID)
+	if err != nil {
+		t.Fatal("Error deleting poll!: ", err)
+	}
+	vote := Vote{ID: "1", VoterID: "voter", PollID: poll.ID, VotedFor: 0}
+	voteFuncs := map[string]func() error{
+		"AddVote":     func() error { return store.AddVote(vote) },
+		"ReplaceVote": func() error { return store.ReplaceVote(vote) },
+		"ToggleVote": func() error {
+			_, err := store.ToggleVote(vote, 0)
+			return err
+		},
+		"ToggleSingleVote": func() error {
+			_, err := store.ToggleSingleVote(vote)
+			return err
+		},
+	}
+	for name, voteFunc := range voteFuncs {
+		err = voteFunc()
+		if errors.Cause(err) != ErrPollNotFound {
+			t.Errorf("Expected ErrPollNotFound from %s for deleted poll but got: %v", name, err)
+		}
+	}
+	votes, err := store.GetVotesForPoll(poll.ID)
+	if err != nil {
+		t.Fatal("Error fetching votes of deleted poll!: ", err)
+	}
+	if len(votes) != 0 {
+		t.Fatalf("Expected no votes for deleted poll but got %v", votes)
+	}
+}
@@ -0,0 +1,84 @@
+package poll
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	MinOptions = 2
+	// MaxOptions keeps poll messages within Slack's limit of 20 attachments
+	// per message, as every option is shown in its own attachment and up to
+	// three more attachments hold the buttons below the options.
+	MaxOptions = 17
+	// MaxOptionLength is the maximum length of button labels on Discord.
+	MaxOptionLength   = 80
+	MaxQuestionLength = 300
+)
+
+var (
+	ErrQuestionMissing  = errors.New("Poll has no question!")
+	ErrQuestionTooLong  = errors.New("Question of poll is too long!")
+	ErrTooFewOptions    = errors.New("Poll has too few options!")
+	ErrTooManyOptions   = errors.New("Poll has too many options!")
+	ErrEmptyOption      = errors.New("Option of poll is empty!")
+	ErrOptionTooLong    = errors.New("Option of poll is too long!")
+	ErrDuplicateOption  = errors.New("Option of poll is given more than once!")
+	ErrInvalidMaxChoice = errors.New("Maximum number of choices exceeds the number of options!")
+)
+
+// ValidationError is returned for polls which can't be created. Rule is one
+// of the validation errors of this package, Message explains the problem to
+// the creator of the poll.
+type ValidationError struct {
+	Rule    error
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func newValidationError(rule error, format string, args ...interface{}) error {
+	return &ValidationError{rule, fmt.Sprintf(format, args...)}
+}
+
+// Validate checks that a new poll has a question and between MinOptions and
+// MaxOptions distinct options, all within the maximum lengths. Options are
+// compared ignoring case and surrounding whitespace.
+func Validate(p Poll) error {
+	question := strings.TrimSpace(p.Question)
+	if question == "" {
+		return newValidationError(ErrQuestionMissing, "Your poll needs a question!")
+	}
+	if utf8.RuneCountInString(question) > MaxQuestionLength {
+		return newValidationError(ErrQuestionTooLong, "The question can have at most %d characters!", MaxQuestionLength)
+	}
+	if len(p.Options) < MinOptions {
+		return newValidationError(ErrTooFewOptions, "Your poll needs at least %d options!", MinOptions)
+	}
+	if len(p.Options) > MaxOptions {
+		return newValidationError(ErrTooManyOptions, "Polls can have at most %d options, yours has %d!", MaxOptions, len(p.Options))
+	}
+	givenOptions := make(map[string]bool)
+	for _, pollOption := range p.Options {
+		option := strings.TrimSpace(pollOption.Label)
+		if option == "" {
+			return newValidationError(ErrEmptyOption, "Options can't be empty!")
+		}
+		if utf8.RuneCountInString(option) > MaxOptionLength {
+			return newValidationError(ErrOptionTooLong, "Options can have at most %d characters, %q is too long!", MaxOptionLength, option)
+		}
+		if givenOptions[strings.ToLower(option)] {
+			return newValidationError(ErrDuplicateOption, "The option %q is given more than once!", option)
+		}
+		givenOptions[strings.ToLower(option)] = true
+	}
+	if p.MaxChoices > len(p.Options) {
+		return newValidationError(ErrInvalidMaxChoice, "Voters can't select %d options in a poll with %d options!", p.MaxChoices, len(p.Options))
+	}
+	return nil
+}
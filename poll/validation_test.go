@@ -0,0 +1,57 @@
+package poll_test
+
+import (
+	"strings"
+	"testing"
+
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+func TestValidate(t *testing.T) {
+	manyOptions := make([]string, poll.MaxOptions+1)
+	for i := range manyOptions {
+		manyOptions[i] = strings.Repeat("a", i+1)
+	}
+	testCases := []struct {
+		name         string
+		poll         poll.Poll
+		expectedRule error
+	}{
+		{"valid", poll.Poll{Question: "q", Options: poll.NewOptions("a", "b")}, nil},
+		{"most options", poll.Poll{Question: "q", Options: poll.NewOptions(manyOptions[:poll.MaxOptions]...)}, nil},
+		{"longest texts", poll.Poll{Question: strings.Repeat("ä", poll.MaxQuestionLength), Options: poll.NewOptions(strings.Repeat("ö", poll.MaxOptionLength), "b")}, nil},
+		{"no question", poll.Poll{Question: "", Options: poll.NewOptions("a", "b")}, poll.ErrQuestionMissing},
+		{"blank question", poll.Poll{Question: "  ", Options: poll.NewOptions("a", "b")}, poll.ErrQuestionMissing},
+		{"long question", poll.Poll{Question: strings.Repeat("q", poll.MaxQuestionLength+1), Options: poll.NewOptions("a", "b")}, poll.ErrQuestionTooLong},
+		{"no options", poll.Poll{Question: "q"}, poll.ErrTooFewOptions},
+		{"one option", poll.Poll{Question: "q", Options: poll.NewOptions("a")}, poll.ErrTooFewOptions},
+		{"too many options", poll.Poll{Question: "q", Options: poll.NewOptions(manyOptions...)}, poll.ErrTooManyOptions},
+		{"empty option", poll.Poll{Question: "q", Options: poll.NewOptions("a", " ")}, poll.ErrEmptyOption},
+		{"long option", poll.Poll{Question: "q", Options: poll.NewOptions("a", strings.Repeat("b", poll.MaxOptionLength+1))}, poll.ErrOptionTooLong},
+		{"duplicate option", poll.Poll{Question: "q", Options: poll.NewOptions("Pizza", "Thai", " pizza")}, poll.ErrDuplicateOption},
+		{"too many choices", poll.Poll{Question: "q", Options: poll.NewOptions("a", "b"), Type: poll.PollTypeMultipleChoice, MaxChoices: 3}, poll.ErrInvalidMaxChoice},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := poll.Validate(testCase.poll)
+			if testCase.expectedRule == nil {
+				if err != nil {
+					t.Fatal("Expected poll to be valid but got: ", err)
+				}
+				return
+			}
+			validationErr, ok := err.(*poll.ValidationError)
+			if !ok || validationErr.Rule != testCase.expectedRule || validationErr.Message == "" {
+				t.Fatalf("Expected validation error for %v but got: %v", testCase.expectedRule, err)
+			}
+		})
+	}
+}
+
+func TestAddingInvalidPoll(t *testing.T) {
+	store := poll.NewDefaultStore(nil)
+	err := store.AddPoll(poll.Poll{ID: "1", Question: "q", Options: poll.NewOptions("a")})
+	if validationErr, ok := err.(*poll.ValidationError); !ok || validationErr.Rule != poll.ErrTooFewOptions {
+		t.Fatal("Expected validation error when adding invalid poll but got: ", err)
+	}
+}
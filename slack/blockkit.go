@@ -0,0 +1,230 @@
+package slack
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+const (
+	BlockTypeSection = "section"
+	BlockTypeActions = "actions"
+	BlockTypeContext = "context"
+	BlockTypeInput   = "input"
+	TextTypeMarkdown = "mrkdwn"
+	TextTypePlain    = "plain_text"
+	// BlockIDSeparator separates the poll ID from the rest of a block ID, so
+	// interactions with blocks can be mapped to their poll.
+	BlockIDSeparator = "|"
+	progressBarWidth = 10
+	// maxButtonTextLength is the limit of Slack for the texts of buttons,
+	// which is below poll.MaxOptionLength.
+	maxButtonTextLength = 75
+)
+
+type Block struct {
+	Type      string         `json:"type"`
+	BlockID   string         `json:"block_id,omitempty"`
+	Text      *TextObject    `json:"text,omitempty"`
+	Accessory *ButtonElement `json:"accessory,omitempty"`
+	// Elements holds ButtonElements and OverflowElements in actions blocks and
+	// TextObjects in context blocks.
+	Elements []interface{} `json:"elements,omitempty"`
+	// Label, Element and Optional are only used by input blocks of modals.
+	Label    *TextObject   `json:"label,omitempty"`
+	Element  *InputElement `json:"element,omitempty"`
+	Optional bool          `json:"optional,omitempty"`
+}
+
+// OverflowElement is a menu of further actions. Choosing an option is handled
+// like clicking a button with the value of the option.
+type OverflowElement struct {
+	Type     string         `json:"type"`
+	ActionID string         `json:"action_id"`
+	Options  []OptionObject `json:"options"`
+}
+
+type TextObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type ButtonElement struct {
+	Type     string     `json:"type"`
+	Text     TextObject `json:"text"`
+	ActionID string     `json:"action_id,omitempty"`
+	Value    string     `json:"value,omitempty"`
+	Style    string     `json:"style,omitempty"`
+}
+
+func (m *SlackMessage) AddBlock(b Block) {
+	m.Blocks = append(m.Blocks, b)
+}
+
+func newMarkdownSection(text string) Block {
+	return Block{Type: BlockTypeSection, Text: &TextObject{TextTypeMarkdown, text}}
+}
+
+func newContext(text string) Block {
+	return Block{Type: BlockTypeContext, Elements: []interface{}{TextObject{TextTypeMarkdown, text}}}
+}
+
+func newButton(text, value string) ButtonElement {
+	return ButtonElement{Type: "button", Text: TextObject{TextTypePlain, text}, ActionID: value + "_button", Value: value}
+}
+
+func newBlockID(pollID, name string) string {
+	return pollID + BlockIDSeparator + name
+}
+
+// BlockKitRenderer creates messages made of Block Kit blocks. The text of the
+// messages is only used as fallback for notifications.
+type BlockKitRenderer struct{}
+
+func (BlockKitRenderer) NewPollMessage(p poll.Poll, results map[int]uint64) SlackMessage {
+	var msg SlackMessage
+	msg.ResponseType = ResponseTypeInChannel
+	msg.Text = p.Question
+	msg.ReplaceOriginal = true
+	msg.AddBlock(newMarkdownSection("*" + p.Question + "*"))
+	if hint := votingHint(p); hint != "" && !p.Closed {
+		msg.AddBlock(newContext(hint))
+	}
+	var totalVotes uint64
+	for _, count := range results {
+		totalVotes += count
+	}
+	for _, option := range p.Options {
+		optionText := "*" + option.Text() + "*"
+		if option.Description != "" {
+			optionText += "\n" + option.Description
+		}
+		if proposer := proposedBy(option); proposer != "" {
+			optionText += "\n_" + proposer + "_"
+		}
+		optionBlock := newMarkdownSection(fmt.Sprintf("%s\n`%s` %s", optionText, progressBar(results[option.ID], totalVotes), formatVoteCount(results[option.ID])))
+		if !p.Closed {
+			optionBlock.BlockID = newBlockID(p.ID, "option_"+strconv.Itoa(option.ID))
+			voteButton := newButton("Vote", optionValue(option))
+			voteButton.ActionID = "vote_" + strconv.Itoa(option.ID)
+			optionBlock.Accessory = &voteButton
+		}
+		msg.AddBlock(optionBlock)
+	}
+	addPollControls(&msg, p, nil)
+	return msg
+}
+
+func (BlockKitRenderer) NewRankedPollMessage(p poll.Poll, runoff poll.RunoffResult) SlackMessage {
+	var msg SlackMessage
+	msg.ResponseType = ResponseTypeInChannel
+	msg.Text = p.Question
+	msg.ReplaceOriginal = true
+	msg.AddBlock(newMarkdownSection("*" + p.Question + "*"))
+	if !p.Closed {
+		msg.AddBlock(newContext(votingHint(p)))
+	}
+	msg.AddBlock(newMarkdownSection(joinOptionTexts(p)))
+	msg.AddBlock(newMarkdownSection("*Instant-runoff*\n" + formatRunoff(p, runoff)))
+	var rankButton *ButtonElement
+	if !p.Closed {
+		button := newButton("Rank options", RankOptionsButtonActionValue)
+		button.Style = "primary"
+		rankButton = &button
+	}
+	addPollControls(&msg, p, rankButton)
+	return msg
+}
+
+func (BlockKitRenderer) NewHiddenResultsPollMessage(p poll.Poll, participants int) SlackMessage {
+	var msg SlackMessage
+	msg.ResponseType = ResponseTypeInChannel
+	msg.Text = p.Question
+	msg.ReplaceOriginal = true
+	msg.AddBlock(newMarkdownSection("*" + p.Question + "*"))
+	if hint := votingHint(p); hint != "" {
+		msg.AddBlock(newContext(hint))
+	}
+	var rankButton *ButtonElement
+	if p.Type == poll.PollTypeRankedChoice {
+		msg.AddBlock(newMarkdownSection(joinOptionTexts(p)))
+		button := newButton("Rank options", RankOptionsButtonActionValue)
+		button.Style = "primary"
+		rankButton = &button
+	} else {
+		var voteButtons []interface{}
+		for _, option := range p.Options {
+			voteButton := newButton(truncate(option.Text(), maxButtonTextLength), optionValue(option))
+			voteButton.ActionID = "vote_" + strconv.Itoa(option.ID)
+			voteButtons = append(voteButtons, voteButton)
+		}
+		msg.AddBlock(Block{Type: BlockTypeActions, BlockID: newBlockID(p.ID, "options"), Elements: voteButtons})
+	}
+	msg.AddBlock(newContext(hiddenResultsHint(participants)))
+	addPollControls(&msg, p, rankButton)
+	return msg
+}
+
+// addPollControls adds the buttons below the options of a poll and a context
+// block telling when voting closes or that the poll is closed.
+func addPollControls(msg *SlackMessage, p poll.Poll, firstButton *ButtonElement) {
+	var buttons []interface{}
+	if firstButton != nil {
+		buttons = append(buttons, *firstButton)
+	}
+	// Vote details would reveal hidden results
+	if !p.Anonymous && !p.ResultsHidden(time.Now()) {
+		buttons = append(buttons, newButton("Show vote details", PollDetailButtonActionValue))
+	}
+	if !p.Closed {
+		closeButton := newButton("Close poll", ClosePollButtonActionValue)
+		closeButton.Style = "danger"
+		buttons = append(buttons, newButton("Refresh", RefreshButtonActionValue), newButton("My vote", MyVoteButtonActionValue), newButton("Withdraw vote", WithdrawVoteButtonActionValue))
+		menuOptions := []OptionObject{{TextObject{TextTypePlain, "Edit poll"}, EditPollActionValue}}
+		if p.AllowWriteIns {
+			buttons = append(buttons, newButton("Add option", AddWriteInButtonActionValue))
+			menuOptions = append(menuOptions, OptionObject{TextObject{TextTypePlain, "Disable write-ins"}, DisableWriteInsActionValue})
+		}
+		menu := OverflowElement{Type: "overflow", ActionID: "poll_menu", Options: menuOptions}
+		buttons = append(buttons, closeButton, menu)
+	}
+	if len(buttons) > 0 {
+		msg.AddBlock(Block{Type: BlockTypeActions, BlockID: newBlockID(p.ID, "controls"), Elements: buttons})
+	}
+	if p.Closed {
+		msg.AddBlock(newContext("This poll is closed."))
+	} else if !p.Deadline.IsZero() {
+		msg.AddBlock(newContext(fmt.Sprintf("Voting closes <!date^%d^{date_short_pretty} at {time}|%s>",
+			p.Deadline.Unix(), p.Deadline.UTC().Format(time.RFC1123))))
+	}
+}
+
+// progressBar shows the share of votes as bar made of unicode blocks.
+func progressBar(votes, totalVotes uint64) string {
+	filled := 0
+	if totalVotes > 0 {
+		filled = int((votes*progressBarWidth + totalVotes/2) / totalVotes)
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled)
+}
+
+func (BlockKitRenderer) NewVoteDetailMessage(results map[string][]string) SlackMessage {
+	var messageText bytes.Buffer
+	buildVoteDetailMessageTest(results, &messageText)
+	slackMsg := SlackMessage{}
+	slackMsg.ResponseType = ResponseTypeEphemeral
+	slackMsg.Text = messageText.String()
+	slackMsg.ReplaceOriginal = false
+	slackMsg.AddBlock(newMarkdownSection(messageText.String()))
+	return slackMsg
+}
+
+func (BlockKitRenderer) NewErrorMessage(message string) SlackMessage {
+	slackMsg := NewSlackErrorMessage(message)
+	slackMsg.AddBlock(newContext(":warning: " + message))
+	return slackMsg
+}
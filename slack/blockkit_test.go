@@ -0,0 +1,153 @@
+package slack
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-test/deep"
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+// compareWithJSONFile compares the JSON of a message with a sample file. Block
+// elements are untyped, so both are compared as generic JSON values.
+func compareWithJSONFile(t *testing.T, fileName string, actualMessage SlackMessage) {
+	dat, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal("Error reading sample file: ", err)
+	}
+	var expectedJSON interface{}
+	err = json.Unmarshal(dat, &expectedJSON)
+	if err != nil {
+		t.Fatal("Error parsing sample file: ", err)
+	}
+	actualMessageJSON, err := actualMessage.ToJSON()
+	if err != nil {
+		t.Fatal("Error creating JSON for message: ", err)
+	}
+	var actualJSON interface{}
+	json.Unmarshal(actualMessageJSON, &actualJSON)
+	if diff := deep.Equal(expectedJSON, actualJSON); diff != nil {
+		t.Logf("Created message is not as expected.\nExpected: %v\nActual:%v\n", expectedJSON, actualJSON)
+		t.Log("Diff: ", diff)
+		t.Fail()
+	}
+}
+
+func TestBlockKitPollMessage(t *testing.T) {
+	testPoll := poll.Poll{ID: "6b57e603-2366-4116-b51d-011837677e33", Question: "Test Question", CreatorID: "foobar", Options: poll.NewOptions("Answer 1", "Answer 2"),
+		Deadline: time.Date(2018, 7, 12, 18, 0, 0, 0, time.UTC)}
+	compareWithJSONFile(t, "exampleBlockKitPollMessage.json", BlockKitRenderer{}.NewPollMessage(testPoll, map[int]uint64{0: 3, 1: 1}))
+}
+
+func TestBlockKitClosedPollMessage(t *testing.T) {
+	testPoll := poll.Poll{ID: "0d1b2b5f-7a4c-4c36-9f0c-5d1e8f6a7b21", Question: "Test Question", CreatorID: "foobar", Options: poll.NewOptions("Answer 1", "Answer 2"),
+		Closed: true, Anonymous: true}
+	compareWithJSONFile(t, "exampleBlockKitClosedPollMessage.json", BlockKitRenderer{}.NewPollMessage(testPoll, map[int]uint64{0: 2, 1: 1}))
+}
+
+func TestBlockKitHiddenResultsPollMessage(t *testing.T) {
+	testPoll := poll.Poll{ID: "9e2f4c1a-5b7d-4e3f-8a6c-1d0b2e4f6a8c", Question: "Test Question", CreatorID: "foobar", Options: poll.NewOptions("Answer 1", "Answer 2"),
+		HideResults: true}
+	compareWithJSONFile(t, "exampleBlockKitHiddenResultsPollMessage.json", BlockKitRenderer{}.NewHiddenResultsPollMessage(testPoll, 3))
+}
+
+func TestBlockKitVoteDetailsAfterDeadline(t *testing.T) {
+	testPoll := poll.Poll{ID: "1", Question: "Test Question", CreatorID: "foobar", Options: poll.NewOptions("Answer 1", "Answer 2"),
+		HideResults: true, Deadline: time.Now().Add(-time.Minute)}
+	msg := BlockKitRenderer{}.NewPollMessage(testPoll, map[int]uint64{0: 1})
+	for _, block := range msg.Blocks {
+		for _, element := range block.Elements {
+			if button, ok := element.(ButtonElement); ok && button.Value == PollDetailButtonActionValue {
+				return
+			}
+		}
+	}
+	t.Error("Expected vote details button for poll whose deadline has passed")
+}
+
+func TestBlockKitHiddenResultsLongOption(t *testing.T) {
+	longOption := strings.Repeat("a", poll.MaxOptionLength)
+	testPoll := poll.Poll{ID: "1", Question: "Test Question", CreatorID: "foobar", Options: poll.NewOptions(longOption, "Answer 2"), HideResults: true}
+	testPoll.Options[0].Emoji = ":pizza:"
+	msg := BlockKitRenderer{}.NewHiddenResultsPollMessage(testPoll, 0)
+	var voteButton ButtonElement
+	for _, block := range msg.Blocks {
+		if block.Type == BlockTypeActions {
+			voteButton = block.Elements[0].(ButtonElement)
+			break
+		}
+	}
+	if length := utf8.RuneCountInString(voteButton.Text.Text); length > maxButtonTextLength || !strings.HasSuffix(voteButton.Text.Text, "…") {
+		t.Errorf("Expected button text truncated to %d characters but got %q with %d characters", maxButtonTextLength, voteButton.Text.Text, length)
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	testCases := []struct {
+		votes, totalVotes uint64
+		expectedBar       string
+	}{
+		{0, 0, "░░░░░░░░░░"},
+		{1, 3, "███░░░░░░░"},
+		{2, 3, "███████░░░"},
+		{5, 5, "██████████"},
+	}
+	for _, testCase := range testCases {
+		if bar := progressBar(testCase.votes, testCase.totalVotes); bar != testCase.expectedBar {
+			t.Errorf("Expected progress bar %s for %d of %d votes but got %s", testCase.expectedBar, testCase.votes, testCase.totalVotes, bar)
+		}
+	}
+}
+
+func TestNewActionResponseFromBlockActionsPayload(t *testing.T) {
+	dat, err := ioutil.ReadFile("exampleBlockActionsPayload.json")
+	if err != nil {
+		t.Fatal("Error reading sample file: ", err)
+	}
+	actionResponse, err := NewActionResponseFromPayload(string(dat))
+	if err != nil {
+		t.Fatal("Error parsing block_actions payload: ", err)
+	}
+	expectedResponse := ActionResponse{
+		Type:        BlockActionsType,
+		Actions:     []Action{{Name: "vote_1", Type: "button", Value: "1"}},
+		TriggerID:   "398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c",
+		CallbackID:  "6b57e603-2366-4116-b51d-011837677e33",
+		Team:        Team{"T1DC2JH3J", "testteamnow"},
+		Channel:     Channel{"G8PSS9T3V", "foobar"},
+		User:        User{"U2CERLKJA", "roadrunner"},
+		Token:       "xyzz0WbapA4vBCDEFasx0q6G",
+		ResponseURL: "https://hooks.slack.com/actions/T1DC2JH3J/397700885554/96rGlfmibIGlgcZRskXaIFfN",
+	}
+	if diff := deep.Equal(expectedResponse, actionResponse); diff != nil {
+		t.Error("Parsed action response is not as expected: ", diff)
+	}
+}
+
+func TestNewActionResponseFromOverflowMenu(t *testing.T) {
+	dat, err := ioutil.ReadFile("exampleBlockActionsPayload.json")
+	if err != nil {
+		t.Fatal("Error reading sample file: ", err)
+	}
+	var payload map[string]interface{}
+	json.Unmarshal(dat, &payload)
+	payload["actions"] = []interface{}{map[string]interface{}{
+		"type":            "overflow",
+		"action_id":       "poll_menu",
+		"block_id":        "6b57e603-2366-4116-b51d-011837677e33|controls",
+		"selected_option": map[string]interface{}{"text": map[string]interface{}{"type": "plain_text", "text": "Edit poll"}, "value": EditPollActionValue},
+	}}
+	payloadJSON, _ := json.Marshal(payload)
+	actionResponse, err := NewActionResponseFromPayload(string(payloadJSON))
+	if err != nil {
+		t.Fatal("Error parsing block_actions payload: ", err)
+	}
+	expectedActions := []Action{{Name: "poll_menu", Type: "overflow", Value: EditPollActionValue}}
+	if diff := deep.Equal(expectedActions, actionResponse.Actions); diff != nil || actionResponse.CallbackID != "6b57e603-2366-4116-b51d-011837677e33" {
+		t.Error("Parsed overflow action is not as expected: ", diff)
+	}
+}
@@ -1,5 +1,26 @@
 package slack
 
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+const (
+	flagPrefix      = "--"
+	flagAnonymous   = "anonymous"
+	flagMulti       = "multi"
+	flagMax         = "max"
+	flagCloses      = "closes"
+	flagHideResults = "hide-results"
+	flagWriteIns    = "write-ins"
+)
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -7,39 +28,328 @@ func min(a, b int) int {
 	return b
 }
 
+// Token is an argument of a slash command. Quoted tokens are never
+// interpreted as flags.
+type Token struct {
+	Text   string
+	Quoted bool
+}
+
+// quoteClosers maps the quotes which can start a quoted argument to the
+// quotes which can end it. Besides straight quotes the typographic quotes
+// inserted by the autocorrection of many clients are accepted.
+var quoteClosers = map[rune]string{
+	'"':  `"`,
+	'“':  `”“"`,
+	'”':  `”`,
+	'„':  `“”`,
+	'«':  `»`,
+	'‘':  `’`,
+	'\'': `'`,
+}
+
+// isSingleQuote reports whether c starts an argument quoted with single
+// quotes. As these are also used as apostrophes, they only count as quotes
+// if a closing quote follows at the end of a word.
+func isSingleQuote(c rune) bool {
+	return c == '‘' || c == '\''
+}
+
+func isSeparator(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// isEscapable reports whether a backslash before c makes c a literal
+// character.
+func isEscapable(c rune) bool {
+	if c == '\\' || c == '»' || c == '’' {
+		return true
+	}
+	_, isQuote := quoteClosers[c]
+	return isQuote
+}
+
 func ParseSlashCommand(commandArguments string) []string {
-	tokens := []string{}
-	curTokenStart := 0
-	inQuotedToken := false
-	inUnquotedToken := false
+	tokens := TokenizeSlashCommand(commandArguments)
+	texts := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		texts = append(texts, token.Text)
+	}
+	return texts
+}
+
+// TokenizeSlashCommand splits the arguments of a slash command like
+// ParseSlashCommand, but keeps whether an argument was quoted.
+//
+// Arguments are separated by whitespace and can be quoted with straight,
+// typographic or single quotes. A backslash before a quote or another
+// backslash makes it a literal character. Alternatively every line can hold
+// one argument, e.g. the question on the first line followed by one option
+// per line. This is used if there are several lines and the first line
+// doesn't start with a quote or a flag. Lines starting with a quote or a flag
+// are split into arguments in this case, too.
+func TokenizeSlashCommand(commandArguments string) []Token {
+	lines := nonBlankLines(commandArguments)
+	if len(lines) < 2 || !isArgumentLine(lines[0]) {
+		return tokenizeArguments(commandArguments)
+	}
+	tokens := []Token{}
+	for _, line := range lines {
+		if isArgumentLine(line) {
+			tokens = append(tokens, Token{line, true})
+		} else {
+			tokens = append(tokens, tokenizeArguments(line)...)
+		}
+	}
+	return tokens
+}
 
-	if commandArguments == "" {
-		return tokens
+func nonBlankLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
 	}
+	return lines
+}
+
+// isArgumentLine reports whether a line of a multi-line command is a single
+// argument, which is the case unless it starts with a quote or a flag.
+func isArgumentLine(line string) bool {
+	firstChar, _ := utf8.DecodeRuneInString(line)
+	_, startsWithQuote := quoteClosers[firstChar]
+	return !startsWithQuote && !strings.HasPrefix(line, flagPrefix)
+}
 
-	for i, c := range commandArguments {
-		if c == '"' {
-			if !inQuotedToken && !inUnquotedToken {
-				curTokenStart = i + 1
-				inQuotedToken = true
-			} else if inQuotedToken {
-				tokens = append(tokens, commandArguments[curTokenStart:i])
+func tokenizeArguments(commandArguments string) []Token {
+	tokens := []Token{}
+	chars := []rune(commandArguments)
+	var currentToken strings.Builder
+	inQuotedToken := false
+	inUnquotedToken := false
+	var quote rune
+	for i := 0; i < len(chars); i++ {
+		c := chars[i]
+		if isEscape(chars, i) {
+			if !inQuotedToken {
+				inUnquotedToken = true
+			}
+			currentToken.WriteRune(chars[i+1])
+			i++
+		} else if inQuotedToken {
+			if closesQuote(chars, i, quote) {
+				tokens = append(tokens, Token{currentToken.String(), true})
+				currentToken.Reset()
 				inQuotedToken = false
+			} else {
+				currentToken.WriteRune(c)
 			}
-		} else if c == ' ' {
+		} else if isSeparator(c) {
 			if inUnquotedToken {
-				tokens = append(tokens, commandArguments[curTokenStart:i])
+				tokens = append(tokens, Token{currentToken.String(), false})
+				currentToken.Reset()
 				inUnquotedToken = false
 			}
+		} else if _, isQuote := quoteClosers[c]; isQuote && !inUnquotedToken && (!isSingleQuote(c) || hasClosingQuote(chars, i, c)) {
+			quote = c
+			inQuotedToken = true
 		} else {
-			if !inQuotedToken && !inUnquotedToken {
-				curTokenStart = i
-				inUnquotedToken = true
-			}
+			currentToken.WriteRune(c)
+			inUnquotedToken = true
 		}
 	}
-	if (inQuotedToken || inUnquotedToken) && commandArguments[curTokenStart:] != "" {
-		tokens = append(tokens, commandArguments[curTokenStart:])
+	if (inQuotedToken || inUnquotedToken) && currentToken.Len() > 0 {
+		tokens = append(tokens, Token{currentToken.String(), inQuotedToken})
 	}
 	return tokens
 }
+
+func isEscape(chars []rune, i int) bool {
+	return chars[i] == '\\' && i+1 < len(chars) && isEscapable(chars[i+1])
+}
+
+// closesQuote reports whether the character at i ends an argument started
+// with the given quote. Single quotes only end an argument at the end of a
+// word, so that apostrophes can be used within quoted arguments.
+func closesQuote(chars []rune, i int, quote rune) bool {
+	if !strings.ContainsRune(quoteClosers[quote], chars[i]) {
+		return false
+	}
+	return !isSingleQuote(quote) || i+1 == len(chars) || isSeparator(chars[i+1])
+}
+
+// hasClosingQuote reports whether the single quote at start is closed later
+// on, otherwise it is an apostrophe.
+func hasClosingQuote(chars []rune, start int, quote rune) bool {
+	for i := start + 1; i < len(chars); i++ {
+		if isEscape(chars, i) {
+			i++
+		} else if closesQuote(chars, i, quote) {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitCommandName splits text at the first whitespace into the name of a
+// command or subcommand and its arguments, keeping the line breaks in the
+// arguments.
+func SplitCommandName(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	nameEnd := strings.IndexFunc(text, unicode.IsSpace)
+	if nameEnd < 0 {
+		return text, ""
+	}
+	return text[:nameEnd], text[nameEnd:]
+}
+
+// QuoteSlashCommandArgument quotes text, so that TokenizeSlashCommand reads
+// it as a single argument.
+func QuoteSlashCommandArgument(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+}
+
+// PollSpec is a parsed poll command: the question, the options and the
+// settings given by the kind of command and its flags.
+type PollSpec struct {
+	Question   string
+	Options    []string
+	Anonymous  bool
+	Type       poll.PollType
+	MaxChoices int
+	// ClosesIn is the time after which the poll is closed, zero means that the
+	// poll has no deadline.
+	ClosesIn      time.Duration
+	HideResults   bool
+	AllowWriteIns bool
+}
+
+// ParsePollCommand creates a poll of the given kind from the text of a poll
+// command, which starts with the question followed by the options.
+func ParsePollCommand(commandText string, anonymous bool, pollType poll.PollType) (poll.Poll, error) {
+	spec, err := ParsePollSpec(TokenizeSlashCommand(commandText), anonymous, pollType)
+	if err != nil {
+		return poll.Poll{}, err
+	}
+	return spec.NewPoll(time.Now())
+}
+
+// ParsePollSpec reads a poll command of the given kind. The first argument
+// which isn't a flag is the question, all others are options. Flags may
+// appear anywhere:
+//
+//	--anonymous      hides who voted for which option
+//	--multi          allows voters to select several options
+//	--max N          limits the number of options of a multiple choice poll
+//	--closes 48h     closes the poll after the duration, days can be given as 2d
+//	--hide-results   hides the results until the poll is closed
+//	--write-ins      lets every participant add options
+//
+// Values can also be given as --max=2. Quoted arguments and all arguments
+// after "--" are never flags, so they can be options starting with dashes.
+func ParsePollSpec(tokens []Token, anonymous bool, pollType poll.PollType) (PollSpec, error) {
+	spec := PollSpec{Anonymous: anonymous, Type: pollType}
+	var arguments []string
+	maxChoicesGiven := false
+	multiGiven := false
+	onlyArguments := false
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if onlyArguments || token.Quoted || !strings.HasPrefix(token.Text, flagPrefix) {
+			arguments = append(arguments, token.Text)
+			continue
+		}
+		if token.Text == flagPrefix {
+			onlyArguments = true
+			continue
+		}
+		flagParts := strings.SplitN(strings.TrimPrefix(token.Text, flagPrefix), "=", 2)
+		name := flagParts[0]
+		switch name {
+		case flagAnonymous, flagMulti, flagHideResults, flagWriteIns:
+			if len(flagParts) > 1 {
+				return PollSpec{}, errors.Errorf("--%s doesn't take a value", name)
+			}
+			switch name {
+			case flagAnonymous:
+				spec.Anonymous = true
+			case flagMulti:
+				multiGiven = true
+			case flagHideResults:
+				spec.HideResults = true
+			case flagWriteIns:
+				spec.AllowWriteIns = true
+			}
+		case flagMax, flagCloses:
+			var value string
+			if len(flagParts) > 1 {
+				value = flagParts[1]
+			} else if i+1 < len(tokens) {
+				i++
+				value = tokens[i].Text
+			} else if name == flagMax {
+				return PollSpec{}, errors.New("--max needs the maximum number of choices as value")
+			} else {
+				return PollSpec{}, errors.New("--closes needs the time until the poll closes as value, e.g. 48h")
+			}
+			var err error
+			if name == flagMax {
+				maxChoicesGiven = true
+				spec.MaxChoices, err = strconv.Atoi(value)
+				if err != nil || spec.MaxChoices < 1 {
+					return PollSpec{}, errors.Errorf("Invalid maximum number of choices %q", value)
+				}
+			} else {
+				spec.ClosesIn, err = parseClosesIn(value)
+				if err != nil {
+					return PollSpec{}, err
+				}
+			}
+		default:
+			return PollSpec{}, errors.Errorf("Unknown flag %s, put options starting with -- in quotes", token.Text)
+		}
+	}
+	if multiGiven {
+		if spec.Type == poll.PollTypeRankedChoice {
+			return PollSpec{}, errors.New("--multi can't be used for ranked choice polls")
+		}
+		spec.Type = poll.PollTypeMultipleChoice
+	}
+	if maxChoicesGiven && spec.Type != poll.PollTypeMultipleChoice {
+		return PollSpec{}, errors.New("--max can only be used for multiple choice polls, add --multi")
+	}
+	if len(arguments) == 0 {
+		return PollSpec{}, errors.New("The poll command needs a question followed by the options")
+	}
+	spec.Question = arguments[0]
+	spec.Options = arguments[1:]
+	return spec, nil
+}
+
+// parseClosesIn reads a positive duration like 30m or 48h. Whole days can be
+// given as 2d, because time.ParseDuration doesn't support days.
+func parseClosesIn(value string) (time.Duration, error) {
+	var closesIn time.Duration
+	var err error
+	if days, dayErr := strconv.Atoi(strings.TrimSuffix(value, "d")); strings.HasSuffix(value, "d") && dayErr == nil {
+		closesIn = time.Duration(days) * 24 * time.Hour
+	} else {
+		closesIn, err = time.ParseDuration(value)
+	}
+	if err != nil || closesIn <= 0 {
+		return 0, errors.Errorf("Invalid time until the poll closes %q, use e.g. 30m, 48h or 2d", value)
+	}
+	return closesIn, nil
+}
+
+// NewPoll creates the poll described by the spec. Its deadline is calculated
+// from now.
+func (s PollSpec) NewPoll(now time.Time) (poll.Poll, error) {
+	newPoll := poll.Poll{Question: s.Question, Options: poll.NewOptions(s.Options...), Anonymous: s.Anonymous, Type: s.Type, MaxChoices: s.MaxChoices, HideResults: s.HideResults, AllowWriteIns: s.AllowWriteIns}
+	if s.ClosesIn > 0 {
+		newPoll.Deadline = now.Add(s.ClosesIn).UTC().Truncate(time.Second)
+	}
+	return newPoll, nil
+}
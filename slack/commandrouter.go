@@ -0,0 +1,199 @@
+package slack
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"markusreschke.name/selfhostedchatpolling/chat"
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+const (
+	defaultPollCommandName = "/poll"
+	maxListedPolls         = 20
+	pollFlagsHelp          = "Flags for new polls: `--anonymous`, `--multi`, `--max N`, `--closes 48h`, `--hide-results` and `--write-ins`. Put options starting with `--` in quotes.\n"
+)
+
+// subcommand describes a subcommand of the /poll command for help and usage
+// messages.
+type subcommand struct {
+	name        string
+	arguments   string
+	description string
+}
+
+var pollSubcommands = []subcommand{
+	{"create", `"Question" Option1 "Option 2" ... [flags]`, "Create a new poll, without arguments a form is opened"},
+	{"anon", `"Question" Option1 "Option 2" ... [flags]`, "Create a new anonymous poll"},
+	{"multi", `"Question" Option1 "Option 2" ... [--max N] [flags]`, "Create a new poll where several options can be selected"},
+	{"ranked", `"Question" Option1 "Option 2" ... [flags]`, "Create a new ranked choice poll"},
+	{"close", "<poll ID>", "Close one of your polls and post its final results"},
+	{"results", "<poll ID>", "Show the current results of a poll only to you"},
+	{"list", "", "List the polls you created together with their IDs"},
+	{"delete", "<poll ID>", "Delete one of your polls with all of its votes, workspace admins can delete any poll"},
+	{"help", "", "Show this help"},
+}
+
+// pollCommandPlatform routes the subcommands of a single /poll command. The
+// subcommand is the first word, the remaining text holds the arguments of the
+// subcommand as split by TokenizeSlashCommand.
+type pollCommandPlatform struct {
+	*Platform
+}
+
+func (p pollCommandPlatform) ParseCommand(writer http.ResponseWriter, request *http.Request) (chat.Command, bool) {
+	slackRequest, err := p.parseSlashCommandRequest(writer, request)
+	if err != nil {
+		p.logger.Println("Error reading and parsing poll command request: ", err)
+		return chat.Command{}, false
+	}
+	name, argumentText := SplitCommandName(slackRequest.MsgText)
+	if name == "" {
+		p.writeMessage(writer, "", NewPollCommandHelpMessage(slackRequest.Command, ""))
+		return chat.Command{}, false
+	}
+	name = strings.ToLower(name)
+	arguments := TokenizeSlashCommand(argumentText)
+	command := chat.Command{UserID: slackRequest.UserID, Origin: slackRequest}
+	switch name {
+	case "create":
+		return slashCommandPlatform{p.Platform, false, poll.PollTypeSingleChoice}.newPollCommand(writer, slackRequest, arguments)
+	case "anon":
+		return slashCommandPlatform{p.Platform, true, poll.PollTypeSingleChoice}.newPollCommand(writer, slackRequest, arguments)
+	case "multi":
+		return slashCommandPlatform{p.Platform, false, poll.PollTypeMultipleChoice}.newPollCommand(writer, slackRequest, arguments)
+	case "ranked":
+		return slashCommandPlatform{p.Platform, false, poll.PollTypeRankedChoice}.newPollCommand(writer, slackRequest, arguments)
+	case "close":
+		command.Action = chat.ActionClosePoll
+	case "results":
+		command.Action = chat.ActionShowResults
+	case "delete":
+		command.Action = chat.ActionDeletePoll
+		command.UserIsAdmin = p.isWorkspaceAdmin(slackRequest.UserID)
+	case "list":
+		command.Action = chat.ActionListPolls
+		if len(arguments) > 0 {
+			p.writeMessage(writer, "", NewSubcommandUsageMessage(slackRequest.Command, name))
+			return chat.Command{}, false
+		}
+		return command, true
+	case "help":
+		p.writeMessage(writer, "", NewPollCommandHelpMessage(slackRequest.Command, ""))
+		return chat.Command{}, false
+	default:
+		p.writeMessage(writer, "", NewPollCommandHelpMessage(slackRequest.Command, fmt.Sprintf("Unknown subcommand %q.", name)))
+		return chat.Command{}, false
+	}
+	if len(arguments) != 1 {
+		p.writeMessage(writer, "", NewSubcommandUsageMessage(slackRequest.Command, name))
+		return chat.Command{}, false
+	}
+	command.PollID = arguments[0].Text
+	return command, true
+}
+
+// WriteReply posts created and closed polls to the channel, everything else
+// is only shown to the user of the command.
+func (p pollCommandPlatform) WriteReply(writer http.ResponseWriter, command chat.Command, reply chat.Reply) {
+	switch reply.Kind {
+	case chat.ReplyPoll:
+		pollMessage := p.newPollMessage(reply)
+		if command.Action == chat.ActionShowResults {
+			pollMessage.ResponseType = ResponseTypeEphemeral
+		}
+		// The message of the poll is replaced so it shows the poll as closed
+		// and reveals hidden results
+		if command.Action == chat.ActionClosePoll && reply.Poll.ResponseURL != "" {
+			go p.sendMessage(reply.Poll.ResponseURL, pollMessage)
+		}
+		p.writeMessage(writer, "", pollMessage)
+	case chat.ReplyPollList:
+		p.writeMessage(writer, "", NewPollListMessage(reply.Polls))
+	case chat.ReplyNotice:
+		p.writeMessage(writer, "", NewEphemeralMessage(reply.Text))
+	case chat.ReplyDeletedPoll:
+		if reply.Poll.ResponseURL != "" {
+			go p.sendMessage(reply.Poll.ResponseURL, NewDeletedPollMessage())
+		}
+		p.writeMessage(writer, "", NewEphemeralMessage(reply.Text))
+	default:
+		p.writeMessage(writer, "", p.renderer.NewErrorMessage(reply.Text))
+	}
+}
+
+func formatSubcommandUsage(commandName string, sub subcommand) string {
+	usage := commandName + " " + sub.name
+	if sub.arguments != "" {
+		usage += " " + sub.arguments
+	}
+	return usage
+}
+
+func orDefaultPollCommandName(commandName string) string {
+	if commandName == "" {
+		return defaultPollCommandName
+	}
+	return commandName
+}
+
+// NewPollCommandHelpMessage lists all subcommands of the poll command. The
+// optional problem is shown above the list.
+func NewPollCommandHelpMessage(commandName, problem string) SlackMessage {
+	commandName = orDefaultPollCommandName(commandName)
+	var messageText bytes.Buffer
+	if problem != "" {
+		fmt.Fprintf(&messageText, "%s\n", problem)
+	}
+	fmt.Fprintf(&messageText, "*Usage of %s:*\n", commandName)
+	for _, sub := range pollSubcommands {
+		fmt.Fprintf(&messageText, "• `%s`: %s\n", formatSubcommandUsage(commandName, sub), sub.description)
+	}
+	messageText.WriteString(pollFlagsHelp)
+	return NewEphemeralMessage(messageText.String())
+}
+
+// NewSubcommandUsageMessage shows the usage of a single subcommand after it
+// was called with the wrong arguments.
+func NewSubcommandUsageMessage(commandName, subcommandName string) SlackMessage {
+	commandName = orDefaultPollCommandName(commandName)
+	for _, sub := range pollSubcommands {
+		if sub.name == subcommandName {
+			return NewEphemeralMessage(fmt.Sprintf("Usage: `%s`\n%s", formatSubcommandUsage(commandName, sub), sub.description))
+		}
+	}
+	return NewPollCommandHelpMessage(commandName, "")
+}
+
+// NewPollListMessage lists polls with their IDs, which are needed for the
+// subcommands working on existing polls.
+func NewPollListMessage(polls []poll.Poll) SlackMessage {
+	if len(polls) == 0 {
+		return NewEphemeralMessage("You haven't created any polls yet.")
+	}
+	var messageText bytes.Buffer
+	messageText.WriteString("*Your polls:*\n")
+	for i, listedPoll := range polls {
+		if i == maxListedPolls {
+			fmt.Fprintf(&messageText, "_… and %d older polls_\n", len(polls)-maxListedPolls)
+			break
+		}
+		state := "open"
+		if listedPoll.Closed {
+			state = "closed"
+		}
+		fmt.Fprintf(&messageText, "• `%s` %s _(%s)_\n", listedPoll.ID, listedPoll.Question, state)
+	}
+	return NewEphemeralMessage(messageText.String())
+}
+
+func NewEphemeralMessage(text string) SlackMessage {
+	return SlackMessage{Text: text, ResponseType: ResponseTypeEphemeral}
+}
+
+// NewDeletedPollMessage replaces the message of a deleted poll.
+func NewDeletedPollMessage() SlackMessage {
+	return SlackMessage{Text: "This poll was deleted.", ResponseType: ResponseTypeInChannel, ReplaceOriginal: true}
+}
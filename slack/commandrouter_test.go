@@ -0,0 +1,141 @@
+package slack
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"markusreschke.name/selfhostedchatpolling/chat"
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+func parsePollCommandRequest(text string) (chat.Command, bool, *httptest.ResponseRecorder) {
+	platform := NewPlatform(PlatformConfig{Verifier: RequestVerifier{LegacyToken: testLegacyToken}}, log.New(ioutil.Discard, "", 0))
+	form := url.Values{"token": {testLegacyToken}, "user_id": {"U2CERLKJA"}, "command": {"/poll"}, "text": {text},
+		"response_url": {"https://hooks.slack.com/commands/T1DC2JH3J/397700885554/96rGlfmibIGlgcZRskXaIFfN"}}
+	recorder := httptest.NewRecorder()
+	command, ok := platform.PollCommand().ParseCommand(recorder, httptest.NewRequest(http.MethodPost, "/poll", strings.NewReader(form.Encode())))
+	return command, ok, recorder
+}
+
+func TestRoutingPollSubcommands(t *testing.T) {
+	testCases := []struct {
+		text           string
+		expectedAction chat.Action
+		expectedPollID string
+		expectedPoll   poll.Poll
+	}{
+		{`create "Lunch?" Pizza "Thai food"`, chat.ActionCreatePoll, "", poll.Poll{Question: "Lunch?", Options: poll.NewOptions("Pizza", "Thai food")}},
+		{`anon "Lunch?" Pizza Burgers`, chat.ActionCreatePoll, "", poll.Poll{Question: "Lunch?", Options: poll.NewOptions("Pizza", "Burgers"), Anonymous: true}},
+		{`multi --max 2 "Days?" Mon Tue Wed`, chat.ActionCreatePoll, "", poll.Poll{Question: "Days?", Options: poll.NewOptions("Mon", "Tue", "Wed"), Type: poll.PollTypeMultipleChoice, MaxChoices: 2}},
+		{`ranked "Lunch?" Pizza Burgers`, chat.ActionCreatePoll, "", poll.Poll{Question: "Lunch?", Options: poll.NewOptions("Pizza", "Burgers"), Type: poll.PollTypeRankedChoice}},
+		{"create\nLunch?\nPizza\nThai food", chat.ActionCreatePoll, "", poll.Poll{Question: "Lunch?", Options: poll.NewOptions("Pizza", "Thai food")}},
+		{"close 1234", chat.ActionClosePoll, "1234", poll.Poll{}},
+		{"RESULTS 1234", chat.ActionShowResults, "1234", poll.Poll{}},
+		{"delete 1234", chat.ActionDeletePoll, "1234", poll.Poll{}},
+		{"list", chat.ActionListPolls, "", poll.Poll{}},
+	}
+	for _, testCase := range testCases {
+		command, ok, recorder := parsePollCommandRequest(testCase.text)
+		if !ok {
+			t.Errorf("Expected %q to be parsed but got response %s", testCase.text, recorder.Body.String())
+			continue
+		}
+		if command.Action != testCase.expectedAction || command.PollID != testCase.expectedPollID || command.UserID != "U2CERLKJA" {
+			t.Errorf("Unexpected command for %q: %v", testCase.text, command)
+		}
+		if diff := deep.Equal(testCase.expectedPoll, command.Poll); diff != nil {
+			t.Errorf("Unexpected poll for %q: %v", testCase.text, diff)
+		}
+	}
+}
+
+func TestPollCommandUsageMessages(t *testing.T) {
+	testCases := []struct {
+		text         string
+		expectedText string
+	}{
+		{"", "*Usage of /poll:*"},
+		{"help", "• `/poll close <poll ID>`: Close one of your polls and post its final results"},
+		{"vote 1234", `Unknown subcommand "vote".`},
+		{"close", "Usage: `/poll close <poll ID>`"},
+		{"results 1234 5678", "Usage: `/poll results <poll ID>`"},
+		{"list everything", "Usage: `/poll list`"},
+	}
+	for _, testCase := range testCases {
+		_, ok, recorder := parsePollCommandRequest(testCase.text)
+		var msg SlackMessage
+		err := json.Unmarshal(recorder.Body.Bytes(), &msg)
+		if ok || err != nil || recorder.Code != http.StatusOK {
+			t.Errorf("Expected usage message for %q but got %v, status %d and body %s", testCase.text, ok, recorder.Code, recorder.Body.String())
+			continue
+		}
+		if msg.ResponseType != ResponseTypeEphemeral || !strings.Contains(msg.Text, testCase.expectedText) {
+			t.Errorf("Expected ephemeral message containing %q for %q but got %v", testCase.expectedText, testCase.text, msg)
+		}
+	}
+}
+
+func TestNewPollListMessage(t *testing.T) {
+	polls := []poll.Poll{
+		{ID: "2", Question: "Lunch?"},
+		{ID: "1", Question: "Days?", Closed: true},
+	}
+	msg := NewPollListMessage(polls)
+	expectedText := "*Your polls:*\n• `2` Lunch? _(open)_\n• `1` Days? _(closed)_\n"
+	if msg.Text != expectedText || msg.ResponseType != ResponseTypeEphemeral {
+		t.Fatalf("Expected ephemeral message %q but got %v", expectedText, msg)
+	}
+	msg = NewPollListMessage(nil)
+	if msg.Text != "You haven't created any polls yet." {
+		t.Fatalf("Unexpected message for empty poll list: %v", msg)
+	}
+}
+
+func TestClosePollReplacesPollMessage(t *testing.T) {
+	sentMessages := make(chan SlackMessage, 1)
+	responseServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var msg SlackMessage
+		json.NewDecoder(request.Body).Decode(&msg)
+		sentMessages <- msg
+	}))
+	defer responseServer.Close()
+	platform := NewPlatform(PlatformConfig{Verifier: RequestVerifier{LegacyToken: testLegacyToken}}, log.New(ioutil.Discard, "", 0))
+	closedPoll := poll.Poll{ID: "1", Question: "q", Options: poll.NewOptions("a1", "a2"), Closed: true, ResponseURL: responseServer.URL}
+	recorder := httptest.NewRecorder()
+	platform.PollCommand().WriteReply(recorder, chat.Command{Action: chat.ActionClosePoll, PollID: "1"}, chat.Reply{Kind: chat.ReplyPoll, Poll: closedPoll})
+	msg := <-sentMessages
+	if !msg.ReplaceOriginal || !strings.Contains(msg.Text, "q") {
+		t.Fatalf("Expected poll message replacing the original but got %v", msg)
+	}
+}
+
+func TestDeletePollReplacesPollMessage(t *testing.T) {
+	sentMessages := make(chan SlackMessage, 1)
+	responseServer := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var msg SlackMessage
+		json.NewDecoder(request.Body).Decode(&msg)
+		sentMessages <- msg
+	}))
+	defer responseServer.Close()
+	platform := NewPlatform(PlatformConfig{Verifier: RequestVerifier{LegacyToken: testLegacyToken}}, log.New(ioutil.Discard, "", 0))
+	deletedPoll := poll.Poll{ID: "1", Question: "q", Options: poll.NewOptions("a1", "a2"), ResponseURL: responseServer.URL}
+	recorder := httptest.NewRecorder()
+	platform.PollCommand().WriteReply(recorder, chat.Command{Action: chat.ActionDeletePoll, PollID: "1"},
+		chat.Reply{Kind: chat.ReplyDeletedPoll, Poll: deletedPoll, Text: "The poll was deleted."})
+	var notice SlackMessage
+	json.NewDecoder(recorder.Body).Decode(&notice)
+	if notice.Text != "The poll was deleted." || notice.ResponseType != ResponseTypeEphemeral {
+		t.Errorf("Expected ephemeral notice for the user but got %v", notice)
+	}
+	msg := <-sentMessages
+	if !msg.ReplaceOriginal || msg.Text != "This poll was deleted." || len(msg.Blocks) != 0 {
+		t.Fatalf("Expected deleted poll message replacing the original but got %v", msg)
+	}
+}
@@ -0,0 +1,179 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+const (
+	DialogSubmissionType  = "dialog_submission"
+	MaxDialogSelectFields = 10
+	maxDialogTitleLength  = 24
+	maxDialogOptionLength = 75
+	rankingFieldPrefix    = "rank_"
+)
+
+var slackAPIURL = "https://slack.com/api/"
+
+type Dialog struct {
+	CallbackID  string          `json:"callback_id"`
+	Title       string          `json:"title"`
+	SubmitLabel string          `json:"submit_label,omitempty"`
+	State       string          `json:"state,omitempty"`
+	Elements    []DialogElement `json:"elements"`
+}
+
+type DialogElement struct {
+	Type     string         `json:"type"`
+	Label    string         `json:"label"`
+	Name     string         `json:"name"`
+	Optional bool           `json:"optional,omitempty"`
+	Options  []DialogOption `json:"options,omitempty"`
+}
+
+type DialogOption struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+type DialogError struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+type DialogErrorResponse struct {
+	Errors []DialogError `json:"errors"`
+}
+
+func (r *DialogErrorResponse) ToJSON() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// NewRankingDialogErrorResponse shows an error which doesn't belong to a
+// single rank at the first rank of a ranking dialog.
+func NewRankingDialogErrorResponse(message string) DialogErrorResponse {
+	return DialogErrorResponse{[]DialogError{{rankingFieldPrefix + "0", message}}}
+}
+
+// NewRankingDialog creates a dialog with one select element per rank. Only
+// the first rank is required, so voters can leave options unranked. The state
+// of the dialog carries the response URL of the poll message, because the
+// dialog submission doesn't contain it.
+func NewRankingDialog(p poll.Poll, responseURL string) Dialog {
+	dialog := Dialog{CallbackID: p.ID, Title: truncate(p.Question, maxDialogTitleLength), SubmitLabel: "Vote", State: responseURL}
+	var options []DialogOption
+	for _, option := range p.Options {
+		options = append(options, DialogOption{truncate(option.Text(), maxDialogOptionLength), strconv.Itoa(option.ID)})
+	}
+	for rank := 0; rank < len(p.Options) && rank < MaxDialogSelectFields; rank++ {
+		dialog.Elements = append(dialog.Elements, DialogElement{
+			Type:     "select",
+			Label:    rankLabel(rank),
+			Name:     rankingFieldPrefix + strconv.Itoa(rank),
+			Optional: rank > 0,
+			Options:  options,
+		})
+	}
+	return dialog
+}
+
+func rankLabel(rank int) string {
+	position := rank + 1
+	suffix := "th"
+	switch {
+	case position%100 >= 11 && position%100 <= 13:
+	case position%10 == 1:
+		suffix = "st"
+	case position%10 == 2:
+		suffix = "nd"
+	case position%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s choice", position, suffix)
+}
+
+func truncate(text string, maxLength int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+	return string(runes[:maxLength-1]) + "…"
+}
+
+// ParseRankingSubmission reads the ranking from the submission of a ranking
+// dialog. Unselected ranks are skipped. If the submission is invalid the
+// returned errors can be shown in the dialog. Whether the ranked options exist
+// is checked by the poll store.
+func ParseRankingSubmission(submission map[string]string) ([]int, []DialogError) {
+	var ranking []int
+	var dialogErrors []DialogError
+	rankedOptions := make(map[int]bool)
+	for rank := 0; rank < MaxDialogSelectFields; rank++ {
+		fieldName := rankingFieldPrefix + strconv.Itoa(rank)
+		value := submission[fieldName]
+		if value == "" {
+			continue
+		}
+		option, err := strconv.Atoi(value)
+		if err != nil || option < 0 {
+			dialogErrors = append(dialogErrors, DialogError{fieldName, "Invalid option"})
+			continue
+		}
+		if rankedOptions[option] {
+			dialogErrors = append(dialogErrors, DialogError{fieldName, "This option is already ranked higher"})
+			continue
+		}
+		rankedOptions[option] = true
+		ranking = append(ranking, option)
+	}
+	if len(ranking) == 0 && len(dialogErrors) == 0 {
+		dialogErrors = append(dialogErrors, DialogError{rankingFieldPrefix + "0", "Select at least one option"})
+	}
+	return ranking, dialogErrors
+}
+
+// OpenDialog opens a dialog for the user who triggered the interaction with
+// the given trigger ID.
+func OpenDialog(oauthToken, triggerID string, dialog Dialog) error {
+	return callSlackAPI(oauthToken, "dialog.open", struct {
+		TriggerID string `json:"trigger_id"`
+		Dialog    Dialog `json:"dialog"`
+	}{triggerID, dialog})
+}
+
+// callSlackAPI posts the payload as JSON to a method of the Slack Web API.
+func callSlackAPI(oauthToken, method string, payload interface{}) error {
+	requestJSON, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrapf(err, "Error creating JSON for %s!", method)
+	}
+	request, err := http.NewRequest(http.MethodPost, slackAPIURL+method, bytes.NewReader(requestJSON))
+	if err != nil {
+		return errors.Wrapf(err, "Error creating request for %s!", method)
+	}
+	request.Header.Set("Content-Type", "application/json; charset=utf-8")
+	request.Header.Set("Authorization", "Bearer "+oauthToken)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return errors.Wrapf(err, "Error calling %s!", method)
+	}
+	defer response.Body.Close()
+	var apiResponse struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&apiResponse)
+	if err != nil {
+		return errors.Wrapf(err, "Error reading response of %s with status %s", method, response.Status)
+	}
+	if !apiResponse.Ok {
+		return errors.Errorf("Calling %s failed: %s", method, apiResponse.Error)
+	}
+	return nil
+}
@@ -1,16 +1,23 @@
 package slack
 
 import (
+	"bytes"
 	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
 )
 
 const (
 	MaxButtonsPerAttachment = 5
+	BlockActionsType        = "block_actions"
 )
 
 type SlackMessage struct {
 	Text            string       `json:"text,omitempty"`
 	Attachments     []Attachment `json:"attachments,omitempty"`
+	Blocks          []Block      `json:"blocks,omitempty"`
 	ResponseType    string       `json:"response_type,omitempty"`
 	ReplaceOriginal bool         `json:"replace_original, omitempty"`
 }
@@ -64,29 +71,127 @@ type User struct {
 }
 
 type ActionResponse struct {
-	Actions      []Action `json:"actions,omitempty"`
-	CallbackID   string   `json:"callback_id,omitempty"`
-	Team         Team     `json:"team,omitempty"`
-	Channel      Channel  `json:"channel,omitempty"`
-	User         User     `json:"user,omitempty,string"`
-	ActionTS     float64  `json:"action_ts,omitempty,string"`
-	MessageTS    float64  `json:"message_ts,omitempty,string"`
-	AttachmentID int      `json:"attachment_id,omitempty,string"`
-	Token        string   `json:"token,omitempty"`
-	AppUnfurl    bool     `json:"is_app_unfurl,omitempty"`
-	ResponseURL  string   `json:"response_url,omitempty"`
+	// Type is DialogSubmissionType for submitted dialogs, which carry a
+	// submission instead of actions, ViewSubmissionType for submitted modals
+	// and BlockActionsType for interactions with Block Kit messages and
+	// modals.
+	Type         string            `json:"type,omitempty"`
+	Actions      []Action          `json:"actions,omitempty"`
+	Submission   map[string]string `json:"submission,omitempty"`
+	State        string            `json:"state,omitempty"`
+	TriggerID    string            `json:"trigger_id,omitempty"`
+	CallbackID   string            `json:"callback_id,omitempty"`
+	Team         Team              `json:"team,omitempty"`
+	Channel      Channel           `json:"channel,omitempty"`
+	User         User              `json:"user,omitempty,string"`
+	ActionTS     float64           `json:"action_ts,omitempty,string"`
+	MessageTS    float64           `json:"message_ts,omitempty,string"`
+	AttachmentID int               `json:"attachment_id,omitempty,string"`
+	Token        string            `json:"token,omitempty"`
+	AppUnfurl    bool              `json:"is_app_unfurl,omitempty"`
+	ResponseURL  string            `json:"response_url,omitempty"`
+	// View is the modal of view submissions and interactions within modals.
+	View *View `json:"view,omitempty"`
+}
+
+type blockActionsPayload struct {
+	Type    string  `json:"type"`
+	Team    Team    `json:"team"`
+	Channel Channel `json:"channel"`
+	User    struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Token       string `json:"token"`
+	TriggerID   string `json:"trigger_id"`
+	ResponseURL string `json:"response_url"`
+	View        *View  `json:"view"`
+	Actions     []struct {
+		ActionID string `json:"action_id"`
+		BlockID  string `json:"block_id"`
+		Type     string `json:"type"`
+		Value    string `json:"value"`
+		// SelectedOption is set instead of the value for overflow menus
+		SelectedOption *OptionObject `json:"selected_option"`
+	} `json:"actions"`
 }
 
 func NewActionResponseFromPayload(jsonPaylaod string) (ActionResponse, error) {
+	var payloadType struct {
+		Type string `json:"type"`
+	}
+	err := json.Unmarshal([]byte(jsonPaylaod), &payloadType)
+	if err != nil {
+		return ActionResponse{}, err
+	}
+	if payloadType.Type == BlockActionsType {
+		return newActionResponseFromBlockActions(jsonPaylaod)
+	}
 	var resp ActionResponse
-	err := json.Unmarshal([]byte(jsonPaylaod), &resp)
+	err = json.Unmarshal([]byte(jsonPaylaod), &resp)
 	return resp, err
 }
 
+// newActionResponseFromBlockActions converts a block_actions payload, so it
+// can be handled like the actions of attachments. The callback ID is taken
+// from the block ID of the first action, except for interactions within
+// modals which don't belong to a poll.
+func newActionResponseFromBlockActions(jsonPaylaod string) (ActionResponse, error) {
+	var payload blockActionsPayload
+	err := json.Unmarshal([]byte(jsonPaylaod), &payload)
+	if err != nil {
+		return ActionResponse{}, err
+	}
+	resp := ActionResponse{
+		Type:        payload.Type,
+		TriggerID:   payload.TriggerID,
+		Team:        payload.Team,
+		Channel:     payload.Channel,
+		User:        User{payload.User.ID, payload.User.Username},
+		Token:       payload.Token,
+		ResponseURL: payload.ResponseURL,
+		View:        payload.View,
+	}
+	for _, action := range payload.Actions {
+		value := action.Value
+		if action.SelectedOption != nil {
+			value = action.SelectedOption.Value
+		}
+		resp.Actions = append(resp.Actions, Action{Name: action.ActionID, Type: action.Type, Value: value})
+	}
+	if len(payload.Actions) > 0 && payload.View == nil {
+		blockID := payload.Actions[0].BlockID
+		separatorIndex := strings.Index(blockID, BlockIDSeparator)
+		if separatorIndex < 0 {
+			return ActionResponse{}, errors.Errorf("Block ID %q doesn't contain a poll ID", blockID)
+		}
+		resp.CallbackID = blockID[:separatorIndex]
+	}
+	return resp, nil
+}
+
 func (m *SlackMessage) ToJSON() ([]byte, error) {
 	return json.Marshal(m)
 }
 
+// SendToResponseURL posts the message to a response URL of a slash command or
+// interactive message, which allows updating the message later on.
+func (m *SlackMessage) SendToResponseURL(responseURL string) error {
+	messageJSON, err := m.ToJSON()
+	if err != nil {
+		return errors.Wrap(err, "Error creating JSON for message!")
+	}
+	response, err := http.Post(responseURL, "application/json", bytes.NewReader(messageJSON))
+	if err != nil {
+		return errors.Wrap(err, "Error sending message to response URL!")
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return errors.Errorf("Sending message to response URL failed with status %s", response.Status)
+	}
+	return nil
+}
+
 func (m *SlackMessage) AddAttachment(a Attachment) {
 	m.Attachments = append(m.Attachments, a)
 }
@@ -0,0 +1,418 @@
+package slack
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+const (
+	ViewSubmissionType      = "view_submission"
+	PollModalCallbackID     = "create_poll"
+	EditModalCallbackID     = "edit_poll"
+	WriteInModalCallbackID  = "write_in"
+	AddOptionActionID       = "add_option"
+	MaxModalOptions         = 10
+	initialModalOptions     = 3
+	modalQuestionBlockID    = "question"
+	modalOptionBlockPrefix  = "option_"
+	modalSettingsBlockID    = "settings"
+	modalDeadlineBlockID    = "deadline"
+	modalInputActionID      = "value"
+	modalSettingAnonymous   = "anonymous"
+	modalSettingMultiple    = "multiple"
+	modalSettingHideResults = "hide_results"
+	modalSettingWriteIns    = "write_ins"
+	modalWriteInBlockID     = "write_in"
+	modalRemoveBlockID      = "remove_options"
+	modalConfirmBlockID     = "confirm_removal"
+	modalSettingConfirm     = "confirm"
+	viewResponseActionError = "errors"
+	// maxOptionObjectTextLength is the limit of Slack for the texts of
+	// option objects, which is below poll.MaxOptionLength.
+	maxOptionObjectTextLength = 75
+)
+
+// View is a modal as sent to views.open and views.update. ID, Hash and State
+// are only set in the views of interaction payloads.
+type View struct {
+	Type            string      `json:"type"`
+	CallbackID      string      `json:"callback_id"`
+	Title           TextObject  `json:"title"`
+	Submit          *TextObject `json:"submit,omitempty"`
+	Close           *TextObject `json:"close,omitempty"`
+	PrivateMetadata string      `json:"private_metadata,omitempty"`
+	Blocks          []Block     `json:"blocks"`
+	ID              string      `json:"id,omitempty"`
+	Hash            string      `json:"hash,omitempty"`
+	State           *ViewState  `json:"state,omitempty"`
+}
+
+type ViewState struct {
+	// Values maps block IDs to the values of their elements by action ID.
+	Values map[string]map[string]ViewStateValue `json:"values"`
+}
+
+type ViewStateValue struct {
+	Type             string         `json:"type"`
+	Value            string         `json:"value,omitempty"`
+	SelectedOptions  []OptionObject `json:"selected_options,omitempty"`
+	SelectedDateTime int64          `json:"selected_date_time,omitempty"`
+}
+
+type InputElement struct {
+	Type           string         `json:"type"`
+	ActionID       string         `json:"action_id"`
+	Placeholder    *TextObject    `json:"placeholder,omitempty"`
+	InitialValue   string         `json:"initial_value,omitempty"`
+	MaxLength      int            `json:"max_length,omitempty"`
+	Options        []OptionObject `json:"options,omitempty"`
+	InitialOptions []OptionObject `json:"initial_options,omitempty"`
+}
+
+type OptionObject struct {
+	Text  TextObject `json:"text"`
+	Value string     `json:"value"`
+}
+
+// ViewErrorResponse keeps a submitted modal open and shows the errors at the
+// blocks with the given IDs.
+type ViewErrorResponse struct {
+	ResponseAction string            `json:"response_action"`
+	Errors         map[string]string `json:"errors"`
+}
+
+func (r *ViewErrorResponse) ToJSON() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// PollModalMetadata is kept in the private metadata of the poll creation
+// modal. The response URL of the slash command is used to post the poll,
+// because the submission of a modal doesn't contain one.
+type PollModalMetadata struct {
+	ResponseURL string        `json:"response_url"`
+	Anonymous   bool          `json:"anonymous,omitempty"`
+	PollType    poll.PollType `json:"poll_type,omitempty"`
+	OptionCount int           `json:"option_count"`
+}
+
+// EditModalMetadata is kept in the private metadata of the modal for editing
+// a poll. The response URL of the interaction which opened the modal is used
+// to update the poll message. OptionIDs are the IDs of the options in the
+// order of their fields, OptionCount is the number of option fields including
+// the fields for new options. The labels aren't kept because Slack limits the
+// private metadata to 3000 characters, the store applies the edit to the
+// current poll.
+type EditModalMetadata struct {
+	PollID      string `json:"poll_id"`
+	ResponseURL string `json:"response_url"`
+	OptionIDs   []int  `json:"option_ids"`
+	OptionCount int    `json:"option_count"`
+}
+
+// WriteInModalMetadata is kept in the private metadata of the modal for
+// adding an option to a poll. The response URL of the interaction which
+// opened the modal is used to update the poll message.
+type WriteInModalMetadata struct {
+	PollID      string `json:"poll_id"`
+	ResponseURL string `json:"response_url"`
+}
+
+func newPlainText(text string) *TextObject {
+	return &TextObject{TextTypePlain, text}
+}
+
+func newTextInput(blockID, label, placeholder string, maxLength int, optional bool) Block {
+	element := &InputElement{Type: "plain_text_input", ActionID: modalInputActionID, Placeholder: newPlainText(placeholder), MaxLength: maxLength}
+	return Block{Type: BlockTypeInput, BlockID: blockID, Label: newPlainText(label), Element: element, Optional: optional}
+}
+
+func newSettingOption(text, value string) OptionObject {
+	return OptionObject{TextObject{TextTypePlain, text}, value}
+}
+
+// NewPollModal creates the modal for creating a poll with the number of
+// option fields given in the metadata. The first two options are required.
+func NewPollModal(metadata PollModalMetadata) View {
+	if metadata.OptionCount < initialModalOptions {
+		metadata.OptionCount = initialModalOptions
+	}
+	metadataJSON, _ := json.Marshal(metadata)
+	view := View{Type: "modal", CallbackID: PollModalCallbackID, Title: TextObject{TextTypePlain, "New poll"},
+		Submit: newPlainText("Create"), Close: newPlainText("Cancel"), PrivateMetadata: string(metadataJSON)}
+	view.Blocks = append(view.Blocks, newTextInput(modalQuestionBlockID, "Question", "Where do we go for lunch?", poll.MaxQuestionLength, false))
+	for index := 0; index < metadata.OptionCount; index++ {
+		view.Blocks = append(view.Blocks, newTextInput(modalOptionBlockPrefix+strconv.Itoa(index), "Option "+strconv.Itoa(index+1), "", poll.MaxOptionLength, index >= 2))
+	}
+	if metadata.OptionCount < MaxModalOptions {
+		view.Blocks = append(view.Blocks, newAddOptionButton())
+	}
+	anonymousOption := newSettingOption("Anonymous votes", modalSettingAnonymous)
+	multipleOption := newSettingOption("Allow selecting several options", modalSettingMultiple)
+	hideResultsOption := newSettingOption("Hide results until the poll is closed", modalSettingHideResults)
+	writeInsOption := newSettingOption("Let everyone add options", modalSettingWriteIns)
+	settings := &InputElement{Type: "checkboxes", ActionID: modalInputActionID, Options: []OptionObject{anonymousOption, multipleOption, hideResultsOption, writeInsOption}}
+	if metadata.Anonymous {
+		settings.InitialOptions = append(settings.InitialOptions, anonymousOption)
+	}
+	if metadata.PollType == poll.PollTypeMultipleChoice {
+		settings.InitialOptions = append(settings.InitialOptions, multipleOption)
+	}
+	view.Blocks = append(view.Blocks,
+		Block{Type: BlockTypeInput, BlockID: modalSettingsBlockID, Label: newPlainText("Settings"), Element: settings, Optional: true},
+		Block{Type: BlockTypeInput, BlockID: modalDeadlineBlockID, Label: newPlainText("Close poll at"),
+			Element: &InputElement{Type: "datetimepicker", ActionID: modalInputActionID}, Optional: true})
+	return view
+}
+
+// ParsePollModalMetadata reads the metadata of a poll creation modal.
+func ParsePollModalMetadata(view View) (PollModalMetadata, error) {
+	var metadata PollModalMetadata
+	err := json.Unmarshal([]byte(view.PrivateMetadata), &metadata)
+	return metadata, err
+}
+
+// ParsePollModalSubmission creates a poll from the values of a submitted poll
+// creation modal. If the values are invalid the returned errors can be shown
+// in the modal.
+func ParsePollModalSubmission(view View, metadata PollModalMetadata, now time.Time) (poll.Poll, map[string]string) {
+	modalErrors := make(map[string]string)
+	var values map[string]map[string]ViewStateValue
+	if view.State != nil {
+		values = view.State.Values
+	}
+	inputValue := func(blockID string) ViewStateValue {
+		return values[blockID][modalInputActionID]
+	}
+	newPoll := poll.Poll{Question: strings.TrimSpace(inputValue(modalQuestionBlockID).Value)}
+	if newPoll.Question == "" {
+		modalErrors[modalQuestionBlockID] = "Enter a question"
+	}
+	var labels []string
+	for index := 0; index < metadata.OptionCount; index++ {
+		if label := strings.TrimSpace(inputValue(modalOptionBlockPrefix + strconv.Itoa(index)).Value); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	newPoll.Options = poll.NewOptions(labels...)
+	if len(newPoll.Options) < 2 {
+		modalErrors[modalOptionBlockPrefix+"0"] = "Enter at least two options"
+	}
+	// The settings only cover multiple choice, ranked choice polls keep the
+	// type of the slash command which opened the modal
+	if metadata.PollType == poll.PollTypeRankedChoice {
+		newPoll.Type = poll.PollTypeRankedChoice
+	}
+	for _, setting := range inputValue(modalSettingsBlockID).SelectedOptions {
+		switch setting.Value {
+		case modalSettingAnonymous:
+			newPoll.Anonymous = true
+		case modalSettingMultiple:
+			newPoll.Type = poll.PollTypeMultipleChoice
+		case modalSettingHideResults:
+			newPoll.HideResults = true
+		case modalSettingWriteIns:
+			newPoll.AllowWriteIns = true
+		}
+	}
+	if deadline := inputValue(modalDeadlineBlockID).SelectedDateTime; deadline != 0 {
+		newPoll.Deadline = time.Unix(deadline, 0).UTC()
+		if !newPoll.Deadline.After(now) {
+			modalErrors[modalDeadlineBlockID] = "The deadline has to be in the future"
+		}
+	}
+	return newPoll, modalErrors
+}
+
+// NewEditModal creates the modal for editing the question and options of a
+// poll. The current options can be renamed or removed and new options can be
+// added until the poll has poll.MaxOptions options.
+func NewEditModal(p poll.Poll, responseURL string) View {
+	metadata := EditModalMetadata{PollID: p.ID, ResponseURL: responseURL, OptionCount: len(p.Options) + 1}
+	for _, option := range p.Options {
+		metadata.OptionIDs = append(metadata.OptionIDs, option.ID)
+	}
+	metadataJSON, _ := json.Marshal(metadata)
+	view := View{Type: "modal", CallbackID: EditModalCallbackID, Title: TextObject{TextTypePlain, "Edit poll"},
+		Submit: newPlainText("Save"), Close: newPlainText("Cancel"), PrivateMetadata: string(metadataJSON)}
+	question := newTextInput(modalQuestionBlockID, "Question", "", poll.MaxQuestionLength, false)
+	question.Element.InitialValue = p.Question
+	view.Blocks = append(view.Blocks, question)
+	var removeOptions []OptionObject
+	for index, existingOption := range p.Options {
+		option := newTextInput(modalOptionBlockPrefix+strconv.Itoa(index), "Option "+strconv.Itoa(index+1), "", poll.MaxOptionLength, false)
+		option.Element.InitialValue = existingOption.Label
+		removeOptions = append(removeOptions, newSettingOption(truncate(existingOption.Label, maxOptionObjectTextLength), strconv.Itoa(existingOption.ID)))
+		view.Blocks = append(view.Blocks, option)
+	}
+	view.Blocks = append(view.Blocks, newEditModalOption(len(p.Options)))
+	if metadata.OptionCount < poll.MaxOptions {
+		view.Blocks = append(view.Blocks, newAddOptionButton())
+	}
+	confirmOption := newSettingOption("Delete the removed options together with their votes", modalSettingConfirm)
+	view.Blocks = append(view.Blocks,
+		Block{Type: BlockTypeInput, BlockID: modalRemoveBlockID, Label: newPlainText("Remove options"), Optional: true,
+			Element: &InputElement{Type: "multi_static_select", ActionID: modalInputActionID, Placeholder: newPlainText("Select options"), Options: removeOptions}},
+		Block{Type: BlockTypeInput, BlockID: modalConfirmBlockID, Label: newPlainText("Confirm removal"), Optional: true,
+			Element: &InputElement{Type: "checkboxes", ActionID: modalInputActionID, Options: []OptionObject{confirmOption}}})
+	return view
+}
+
+// AddEditModalOption returns the edit modal with a further field for a new
+// option. The blocks of the given view are kept because the metadata doesn't
+// hold the labels of the options.
+func AddEditModalOption(view View) (View, error) {
+	metadata, err := ParseEditModalMetadata(view)
+	if err != nil {
+		return View{}, err
+	}
+	newOption := newEditModalOption(metadata.OptionCount)
+	metadata.OptionCount++
+	metadataJSON, _ := json.Marshal(metadata)
+	updatedView := View{Type: view.Type, CallbackID: view.CallbackID, Title: view.Title, Submit: view.Submit, Close: view.Close,
+		PrivateMetadata: string(metadataJSON)}
+	for _, block := range view.Blocks {
+		if block.BlockID != AddOptionActionID {
+			updatedView.Blocks = append(updatedView.Blocks, block)
+			continue
+		}
+		updatedView.Blocks = append(updatedView.Blocks, newOption)
+		if metadata.OptionCount < poll.MaxOptions {
+			updatedView.Blocks = append(updatedView.Blocks, newAddOptionButton())
+		}
+	}
+	return updatedView, nil
+}
+
+func newEditModalOption(index int) Block {
+	return newTextInput(modalOptionBlockPrefix+strconv.Itoa(index), "New option", "", poll.MaxOptionLength, true)
+}
+
+func newAddOptionButton() Block {
+	addButton := ButtonElement{Type: "button", Text: TextObject{TextTypePlain, "Add option"}, ActionID: AddOptionActionID, Value: AddOptionActionID}
+	return Block{Type: BlockTypeActions, BlockID: AddOptionActionID, Elements: []interface{}{addButton}}
+}
+
+// ParseEditModalMetadata reads the metadata of a modal for editing a poll.
+func ParseEditModalMetadata(view View) (EditModalMetadata, error) {
+	var metadata EditModalMetadata
+	err := json.Unmarshal([]byte(view.PrivateMetadata), &metadata)
+	return metadata, err
+}
+
+// ParseEditModalSubmission reads the changes of a poll from a submitted edit
+// modal. The labels of all options which aren't removed are included in the
+// edit, unchanged labels keep the options as they are. Removing options has
+// to be confirmed, otherwise the returned errors ask for the confirmation.
+func ParseEditModalSubmission(view View, metadata EditModalMetadata) (poll.PollEdit, map[string]string) {
+	modalErrors := make(map[string]string)
+	var values map[string]map[string]ViewStateValue
+	if view.State != nil {
+		values = view.State.Values
+	}
+	inputValue := func(blockID string) ViewStateValue {
+		return values[blockID][modalInputActionID]
+	}
+	edit := poll.PollEdit{Question: strings.TrimSpace(inputValue(modalQuestionBlockID).Value)}
+	if edit.Question == "" {
+		modalErrors[modalQuestionBlockID] = "Enter a question"
+	}
+	isRemoved := make(map[int]bool)
+	for _, removedOption := range inputValue(modalRemoveBlockID).SelectedOptions {
+		id, err := strconv.Atoi(removedOption.Value)
+		if err == nil && containsOptionID(metadata.OptionIDs, id) && !isRemoved[id] {
+			edit.RemovedOptions = append(edit.RemovedOptions, id)
+			isRemoved[id] = true
+		}
+	}
+	for index := 0; index < metadata.OptionCount; index++ {
+		blockID := modalOptionBlockPrefix + strconv.Itoa(index)
+		label := strings.TrimSpace(inputValue(blockID).Value)
+		if index >= len(metadata.OptionIDs) {
+			if label != "" {
+				edit.NewOptions = append(edit.NewOptions, label)
+			}
+			continue
+		}
+		optionID := metadata.OptionIDs[index]
+		if isRemoved[optionID] {
+			continue
+		}
+		if label == "" {
+			modalErrors[blockID] = "Enter the option or remove it below"
+			continue
+		}
+		if edit.Labels == nil {
+			edit.Labels = make(map[int]string)
+		}
+		edit.Labels[optionID] = label
+	}
+	if len(edit.RemovedOptions) > 0 && len(inputValue(modalConfirmBlockID).SelectedOptions) == 0 {
+		modalErrors[modalConfirmBlockID] = "Votes for removed options are deleted, confirm the removal"
+	}
+	return edit, modalErrors
+}
+
+// NewWriteInModal creates the modal in which participants propose an option
+// for a poll which allows write-ins.
+func NewWriteInModal(metadata WriteInModalMetadata) View {
+	metadataJSON, _ := json.Marshal(metadata)
+	view := View{Type: "modal", CallbackID: WriteInModalCallbackID, Title: TextObject{TextTypePlain, "Add option"},
+		Submit: newPlainText("Add"), Close: newPlainText("Cancel"), PrivateMetadata: string(metadataJSON)}
+	view.Blocks = append(view.Blocks, newTextInput(modalWriteInBlockID, "Option", "", poll.MaxOptionLength, false))
+	return view
+}
+
+// ParseWriteInModalMetadata reads the metadata of a modal for adding an
+// option.
+func ParseWriteInModalMetadata(view View) (WriteInModalMetadata, error) {
+	var metadata WriteInModalMetadata
+	err := json.Unmarshal([]byte(view.PrivateMetadata), &metadata)
+	return metadata, err
+}
+
+// ParseWriteInModalSubmission reads the proposed option from a submitted
+// write-in modal. Duplicates are rejected by the store, whose error is shown
+// in the modal, too.
+func ParseWriteInModalSubmission(view View) (string, map[string]string) {
+	modalErrors := make(map[string]string)
+	var label string
+	if view.State != nil {
+		label = strings.TrimSpace(view.State.Values[modalWriteInBlockID][modalInputActionID].Value)
+	}
+	if label == "" {
+		modalErrors[modalWriteInBlockID] = "Enter the option"
+	}
+	return label, modalErrors
+}
+
+func containsOptionID(optionIDs []int, id int) bool {
+	for _, optionID := range optionIDs {
+		if optionID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenView opens a modal for the user who triggered the interaction with the
+// given trigger ID.
+func OpenView(oauthToken, triggerID string, view View) error {
+	return callSlackAPI(oauthToken, "views.open", struct {
+		TriggerID string `json:"trigger_id"`
+		View      View   `json:"view"`
+	}{triggerID, view})
+}
+
+// UpdateView replaces an open modal. The hash prevents overwriting a newer
+// version of the modal.
+func UpdateView(oauthToken, viewID, hash string, view View) error {
+	return callSlackAPI(oauthToken, "views.update", struct {
+		ViewID string `json:"view_id"`
+		Hash   string `json:"hash"`
+		View   View   `json:"view"`
+	}{viewID, hash, view})
+}
@@ -0,0 +1,243 @@
+package slack
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+func readViewSubmission(t *testing.T) (View, PollModalMetadata) {
+	dat, err := ioutil.ReadFile("exampleViewSubmissionPayload.json")
+	if err != nil {
+		t.Fatal("Error reading sample file: ", err)
+	}
+	actionResponse, err := NewActionResponseFromPayload(string(dat))
+	if err != nil {
+		t.Fatal("Error parsing view_submission payload: ", err)
+	}
+	if actionResponse.Type != ViewSubmissionType || actionResponse.User.ID != "U2CERLKJA" || actionResponse.View == nil {
+		t.Fatalf("Unexpected action response for view submission: %v", actionResponse)
+	}
+	metadata, err := ParsePollModalMetadata(*actionResponse.View)
+	if err != nil {
+		t.Fatal("Error parsing metadata of view: ", err)
+	}
+	return *actionResponse.View, metadata
+}
+
+func TestPollModal(t *testing.T) {
+	dat, err := ioutil.ReadFile("examplePollModal.json")
+	if err != nil {
+		t.Fatal("Error reading sample file: ", err)
+	}
+	var expectedJSON interface{}
+	err = json.Unmarshal(dat, &expectedJSON)
+	if err != nil {
+		t.Fatal("Error parsing sample file: ", err)
+	}
+	metadata := PollModalMetadata{ResponseURL: "https://hooks.slack.com/commands/T1DC2JH3J/397700885554/96rGlfmibIGlgcZRskXaIFfN", Anonymous: true}
+	viewJSON, _ := json.Marshal(NewPollModal(metadata))
+	var actualJSON interface{}
+	json.Unmarshal(viewJSON, &actualJSON)
+	if diff := deep.Equal(expectedJSON, actualJSON); diff != nil {
+		t.Error("Created modal is not as expected: ", diff)
+	}
+}
+
+func TestParsePollModalSubmission(t *testing.T) {
+	view, metadata := readViewSubmission(t)
+	expectedMetadata := PollModalMetadata{ResponseURL: "https://hooks.slack.com/commands/T1DC2JH3J/397700885554/96rGlfmibIGlgcZRskXaIFfN", OptionCount: 4}
+	if diff := deep.Equal(expectedMetadata, metadata); diff != nil {
+		t.Error("Parsed metadata is not as expected: ", diff)
+	}
+	newPoll, modalErrors := ParsePollModalSubmission(view, metadata, time.Unix(1531420618, 0))
+	expectedPoll := poll.Poll{Question: "Where for lunch?", Options: poll.NewOptions("Thai place", "Burger joint", "Pizza \"Roma\""),
+		Type: poll.PollTypeMultipleChoice, Deadline: time.Unix(1531440000, 0).UTC()}
+	if diff := deep.Equal(expectedPoll, newPoll); diff != nil || len(modalErrors) != 0 {
+		t.Errorf("Parsed poll is not as expected: %v, errors: %v", diff, modalErrors)
+	}
+}
+
+func TestParseInvalidPollModalSubmission(t *testing.T) {
+	testCases := []struct {
+		name          string
+		modify        func(values map[string]map[string]ViewStateValue)
+		now           time.Time
+		expectedError string
+	}{
+		{"MissingQuestion", func(values map[string]map[string]ViewStateValue) {
+			values["question"]["value"] = ViewStateValue{Type: "plain_text_input", Value: "  "}
+		}, time.Unix(1531420618, 0), modalQuestionBlockID},
+		{"SingleOption", func(values map[string]map[string]ViewStateValue) {
+			delete(values, "option_1")
+			delete(values, "option_3")
+		}, time.Unix(1531420618, 0), modalOptionBlockPrefix + "0"},
+		{"DeadlineInPast", func(values map[string]map[string]ViewStateValue) {}, time.Unix(1531440000, 0), modalDeadlineBlockID},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			view, metadata := readViewSubmission(t)
+			testCase.modify(view.State.Values)
+			_, modalErrors := ParsePollModalSubmission(view, metadata, testCase.now)
+			if _, ok := modalErrors[testCase.expectedError]; !ok || len(modalErrors) != 1 {
+				t.Errorf("Expected error for %s but got %v", testCase.expectedError, modalErrors)
+			}
+		})
+	}
+}
+
+func TestEditModal(t *testing.T) {
+	responseURL := "https://hooks.slack.com/actions/T1DC2JH3J/397700885554/96rGlfmibIGlgcZRskXaIFfN"
+	view := NewEditModal(poll.Poll{ID: "1", Question: "Lunch?", Options: poll.NewOptions("Thai place", "Burger joint")}, responseURL)
+	parsedMetadata, err := ParseEditModalMetadata(view)
+	metadata := EditModalMetadata{PollID: "1", ResponseURL: responseURL, OptionIDs: []int{0, 1}, OptionCount: 3}
+	if diff := deep.Equal(metadata, parsedMetadata); err != nil || diff != nil {
+		t.Fatalf("Metadata of edit modal is not as expected: %v, error: %v", diff, err)
+	}
+	var blockIDs []string
+	for _, block := range view.Blocks {
+		blockIDs = append(blockIDs, block.BlockID)
+	}
+	expectedBlockIDs := []string{modalQuestionBlockID, "option_0", "option_1", "option_2", AddOptionActionID, modalRemoveBlockID, modalConfirmBlockID}
+	if diff := deep.Equal(expectedBlockIDs, blockIDs); diff != nil || view.CallbackID != EditModalCallbackID {
+		t.Fatal("Blocks of edit modal are not as expected: ", diff)
+	}
+	if view.Blocks[0].Element.InitialValue != "Lunch?" || view.Blocks[2].Element.InitialValue != "Burger joint" || view.Blocks[2].Optional || !view.Blocks[3].Optional {
+		t.Errorf("Question and option fields of edit modal are not as expected: %v", view.Blocks[:4])
+	}
+	if removeOptions := view.Blocks[5].Element.Options; len(removeOptions) != 2 || removeOptions[1].Value != "1" {
+		t.Errorf("Options for removal are not as expected: %v", removeOptions)
+	}
+	view, err = AddEditModalOption(view)
+	parsedMetadata, _ = ParseEditModalMetadata(view)
+	blockIDs = nil
+	for _, block := range view.Blocks {
+		blockIDs = append(blockIDs, block.BlockID)
+	}
+	expectedBlockIDs = []string{modalQuestionBlockID, "option_0", "option_1", "option_2", "option_3", AddOptionActionID, modalRemoveBlockID, modalConfirmBlockID}
+	if diff := deep.Equal(expectedBlockIDs, blockIDs); err != nil || diff != nil || parsedMetadata.OptionCount != 4 {
+		t.Errorf("Blocks of edit modal with added option are not as expected: %v, error: %v", diff, err)
+	}
+	if view.Blocks[2].Element.InitialValue != "Burger joint" || len(view.Blocks[6].Element.Options) != 2 {
+		t.Errorf("Existing fields of edit modal are not kept: %v", view.Blocks)
+	}
+}
+
+func TestEditModalMetadataLength(t *testing.T) {
+	p := poll.Poll{ID: "1", Question: strings.Repeat("?", poll.MaxQuestionLength)}
+	for i := 0; i < poll.MaxOptions; i++ {
+		p.AddOption(strings.Repeat(strconv.Itoa(i), poll.MaxOptionLength))
+	}
+	view := NewEditModal(p, "https://hooks.slack.com/actions/T1DC2JH3J/397700885554/96rGlfmibIGlgcZRskXaIFfN")
+	if len(view.PrivateMetadata) > 3000 {
+		t.Errorf("Private metadata of edit modal has %d characters", len(view.PrivateMetadata))
+	}
+}
+
+func TestParseEditModalSubmission(t *testing.T) {
+	metadata := EditModalMetadata{PollID: "1", OptionIDs: []int{0, 2}, OptionCount: 4}
+	textValue := func(text string) map[string]ViewStateValue {
+		return map[string]ViewStateValue{modalInputActionID: {Type: "plain_text_input", Value: text}}
+	}
+	selectedValue := func(values ...string) map[string]ViewStateValue {
+		var selected []OptionObject
+		for _, value := range values {
+			selected = append(selected, OptionObject{Value: value})
+		}
+		return map[string]ViewStateValue{modalInputActionID: {SelectedOptions: selected}}
+	}
+	testCases := []struct {
+		name           string
+		values         map[string]map[string]ViewStateValue
+		expectedEdit   poll.PollEdit
+		expectedErrors []string
+	}{
+		{"RenameAndAppend", map[string]map[string]ViewStateValue{"question": textValue(" Where for lunch? "), "option_0": textValue("Thai"),
+			"option_1": textValue("Burger joint"), "option_3": textValue("Pizza")},
+			poll.PollEdit{Question: "Where for lunch?", Labels: map[int]string{0: "Thai", 2: "Burger joint"}, NewOptions: []string{"Pizza"}}, nil},
+		{"RemovalWithoutConfirmation", map[string]map[string]ViewStateValue{"question": textValue("Lunch?"), "option_0": textValue("Thai place"),
+			"option_1": textValue("Burger joint"), "remove_options": selectedValue("2")},
+			poll.PollEdit{Question: "Lunch?", Labels: map[int]string{0: "Thai place"}, RemovedOptions: []int{2}}, []string{modalConfirmBlockID}},
+		{"ConfirmedRemoval", map[string]map[string]ViewStateValue{"question": textValue("Lunch?"), "option_0": textValue(""),
+			"option_1": textValue("Burger joint"), "option_2": textValue("Pizza"), "remove_options": selectedValue("0", "0", "1"),
+			"confirm_removal": selectedValue(modalSettingConfirm)},
+			poll.PollEdit{Question: "Lunch?", Labels: map[int]string{2: "Burger joint"}, NewOptions: []string{"Pizza"}, RemovedOptions: []int{0}}, nil},
+		{"MissingOption", map[string]map[string]ViewStateValue{"question": textValue(""), "option_0": textValue("Thai place")},
+			poll.PollEdit{Question: "", Labels: map[int]string{0: "Thai place"}}, []string{modalQuestionBlockID, "option_1"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			edit, modalErrors := ParseEditModalSubmission(View{State: &ViewState{testCase.values}}, metadata)
+			if diff := deep.Equal(testCase.expectedEdit, edit); diff != nil {
+				t.Error("Parsed edit is not as expected: ", diff)
+			}
+			if len(modalErrors) != len(testCase.expectedErrors) {
+				t.Errorf("Expected errors for %v but got %v", testCase.expectedErrors, modalErrors)
+			}
+			for _, blockID := range testCase.expectedErrors {
+				if _, ok := modalErrors[blockID]; !ok {
+					t.Errorf("Expected error for %s but got %v", blockID, modalErrors)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteInModal(t *testing.T) {
+	metadata := WriteInModalMetadata{PollID: "1", ResponseURL: "https://hooks.slack.com/actions/T1DC2JH3J/397700885554/96rGlfmibIGlgcZRskXaIFfN"}
+	view := NewWriteInModal(metadata)
+	parsedMetadata, err := ParseWriteInModalMetadata(view)
+	if diff := deep.Equal(metadata, parsedMetadata); err != nil || diff != nil || view.CallbackID != WriteInModalCallbackID {
+		t.Fatalf("Metadata of write-in modal is not as expected: %v, error: %v", diff, err)
+	}
+	view.State = &ViewState{map[string]map[string]ViewStateValue{modalWriteInBlockID: {modalInputActionID: {Type: "plain_text_input", Value: " Pizza "}}}}
+	label, modalErrors := ParseWriteInModalSubmission(view)
+	if label != "Pizza" || len(modalErrors) != 0 {
+		t.Errorf("Expected write-in Pizza but got %q, errors: %v", label, modalErrors)
+	}
+	view.State.Values[modalWriteInBlockID][modalInputActionID] = ViewStateValue{Type: "plain_text_input", Value: " "}
+	if _, modalErrors = ParseWriteInModalSubmission(view); modalErrors[modalWriteInBlockID] == "" {
+		t.Errorf("Expected error for empty write-in but got %v", modalErrors)
+	}
+}
+
+func TestOpeningPollModalForEmptyCommand(t *testing.T) {
+	var openedView struct {
+		TriggerID string `json:"trigger_id"`
+		View      View   `json:"view"`
+	}
+	slackAPI := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/views.open" {
+			t.Errorf("Unexpected Slack API call %s", request.URL.Path)
+		}
+		json.NewDecoder(request.Body).Decode(&openedView)
+		writer.Write([]byte(`{"ok":true}`))
+	}))
+	defer slackAPI.Close()
+	originalAPIURL := slackAPIURL
+	slackAPIURL = slackAPI.URL + "/"
+	defer func() { slackAPIURL = originalAPIURL }()
+
+	platform := NewPlatform(PlatformConfig{Verifier: RequestVerifier{LegacyToken: testLegacyToken}}, log.New(ioutil.Discard, "", 0))
+	form := url.Values{"token": {testLegacyToken}, "user_id": {"U2CERLKJA"}, "text": {" "}, "trigger_id": {"398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"},
+		"response_url": {"https://hooks.slack.com/commands/T1DC2JH3J/397700885554/96rGlfmibIGlgcZRskXaIFfN"}}
+	recorder := httptest.NewRecorder()
+	_, ok := platform.SlashCommand(false, poll.PollTypeMultipleChoice).ParseCommand(recorder, httptest.NewRequest(http.MethodPost, "/newpollmulti", strings.NewReader(form.Encode())))
+	if ok || recorder.Code != http.StatusOK || recorder.Body.Len() != 0 {
+		t.Fatalf("Expected empty acknowledgement but got %v, status %d and body %s", ok, recorder.Code, recorder.Body.String())
+	}
+	metadata, err := ParsePollModalMetadata(openedView.View)
+	if openedView.TriggerID != form.Get("trigger_id") || err != nil || metadata.ResponseURL != form.Get("response_url") || metadata.PollType != poll.PollTypeMultipleChoice {
+		t.Errorf("Unexpected modal opened: %v", openedView)
+	}
+}
@@ -0,0 +1,446 @@
+package slack
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	slackApi "github.com/nlopes/slack"
+	"github.com/pkg/errors"
+	"markusreschke.name/selfhostedchatpolling/chat"
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+const (
+	PlatformName          = "slack"
+	contentTypeJSON       = "application/json"
+	httpHeaderContentType = "Content-Type"
+)
+
+type PlatformConfig struct {
+	Verifier    RequestVerifier
+	OAuthToken  string
+	UseBlockKit bool
+	LogTraffic  bool
+}
+
+// Platform is the chat.Platform for Slack. It parses interactions with poll
+// messages, slash commands are parsed by the platforms returned by
+// SlashCommand.
+type Platform struct {
+	config   PlatformConfig
+	renderer MessageRenderer
+	logger   *log.Logger
+}
+
+func NewPlatform(config PlatformConfig, logger *log.Logger) *Platform {
+	return &Platform{config, NewMessageRenderer(config.UseBlockKit), logger}
+}
+
+func (p *Platform) Name() string {
+	return PlatformName
+}
+
+// SlashCommand returns a platform which creates polls of the given kind from
+// slash commands.
+func (p *Platform) SlashCommand(anonymous bool, pollType poll.PollType) chat.Platform {
+	return slashCommandPlatform{p, anonymous, pollType}
+}
+
+// PollCommand returns a platform which parses the subcommands of the /poll
+// command.
+func (p *Platform) PollCommand() chat.Platform {
+	return pollCommandPlatform{p}
+}
+
+func (p *Platform) ParseCommand(writer http.ResponseWriter, request *http.Request) (chat.Command, bool) {
+	actionCallback, err := p.parseButtonActionRequest(writer, request)
+	if err != nil {
+		p.logger.Println("Error reading and parsing request for poll update: ", err)
+		return chat.Command{}, false
+	}
+	if actionCallback.Type == ViewSubmissionType {
+		return p.parseViewSubmission(writer, actionCallback)
+	}
+	if actionCallback.View != nil {
+		p.handleModalAction(writer, actionCallback)
+		return chat.Command{}, false
+	}
+	command := chat.Command{UserID: actionCallback.User.ID, PollID: actionCallback.CallbackID, ResponseURL: actionCallback.ResponseURL, Origin: actionCallback}
+	if actionCallback.Type == DialogSubmissionType {
+		ranking, dialogErrors := ParseRankingSubmission(actionCallback.Submission)
+		if len(dialogErrors) > 0 {
+			writeDialogErrors(writer, dialogErrors)
+			return chat.Command{}, false
+		}
+		command.Action = chat.ActionSubmitRanking
+		command.Ranking = ranking
+		command.Option = ranking[0]
+		command.ResponseURL = actionCallback.State
+		return command, true
+	}
+	if len(actionCallback.Actions) == 0 {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Action Callback without actions")
+		return chat.Command{}, false
+	}
+	switch actionCallback.Actions[0].Value {
+	case PollDetailButtonActionValue:
+		command.Action = chat.ActionShowDetails
+	case RefreshButtonActionValue:
+		command.Action = chat.ActionRefresh
+	case RankOptionsButtonActionValue:
+		command.Action = chat.ActionRequestRanking
+	case ClosePollButtonActionValue:
+		command.Action = chat.ActionClosePoll
+	case MyVoteButtonActionValue:
+		command.Action = chat.ActionShowMyVote
+	case WithdrawVoteButtonActionValue:
+		command.Action = chat.ActionRetractVote
+	case EditPollActionValue:
+		command.Action = chat.ActionRequestEdit
+	case AddWriteInButtonActionValue:
+		command.Action = chat.ActionRequestWriteIn
+	case DisableWriteInsActionValue:
+		command.Action = chat.ActionDisableWriteIns
+	default:
+		command.Action = chat.ActionVote
+		command.Option, command.OptionIsPosition, err = ParseOptionValue(actionCallback.Actions[0].Value)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			p.logger.Println("BadRequest - Value of Action Callback is not a valid vote option index", err)
+			return chat.Command{}, false
+		}
+	}
+	return command, true
+}
+
+// parseViewSubmission creates a poll from a submitted poll creation modal,
+// edits a poll with a submitted edit modal or adds the option of a submitted
+// write-in modal. Invalid submissions are answered with errors, which keeps
+// the modal open.
+func (p *Platform) parseViewSubmission(writer http.ResponseWriter, actionCallback ActionResponse) (chat.Command, bool) {
+	if actionCallback.View != nil && actionCallback.View.CallbackID == EditModalCallbackID {
+		return p.parseEditSubmission(writer, actionCallback)
+	}
+	if actionCallback.View != nil && actionCallback.View.CallbackID == WriteInModalCallbackID {
+		return p.parseWriteInSubmission(writer, actionCallback)
+	}
+	if actionCallback.View == nil || actionCallback.View.CallbackID != PollModalCallbackID {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Submission of unknown view")
+		return chat.Command{}, false
+	}
+	metadata, err := ParsePollModalMetadata(*actionCallback.View)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Invalid metadata of poll modal", err)
+		return chat.Command{}, false
+	}
+	newPoll, modalErrors := ParsePollModalSubmission(*actionCallback.View, metadata, time.Now())
+	if len(modalErrors) > 0 {
+		writeViewErrors(writer, modalErrors)
+		return chat.Command{}, false
+	}
+	return chat.Command{Action: chat.ActionCreatePoll, UserID: actionCallback.User.ID, Poll: newPoll, ResponseURL: metadata.ResponseURL, Origin: actionCallback}, true
+}
+
+func (p *Platform) parseEditSubmission(writer http.ResponseWriter, actionCallback ActionResponse) (chat.Command, bool) {
+	metadata, err := ParseEditModalMetadata(*actionCallback.View)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Invalid metadata of edit modal", err)
+		return chat.Command{}, false
+	}
+	edit, modalErrors := ParseEditModalSubmission(*actionCallback.View, metadata)
+	if len(modalErrors) > 0 {
+		writeViewErrors(writer, modalErrors)
+		return chat.Command{}, false
+	}
+	return chat.Command{Action: chat.ActionEditPoll, UserID: actionCallback.User.ID, PollID: metadata.PollID, Edit: edit, ResponseURL: metadata.ResponseURL, Origin: actionCallback}, true
+}
+
+func (p *Platform) parseWriteInSubmission(writer http.ResponseWriter, actionCallback ActionResponse) (chat.Command, bool) {
+	metadata, err := ParseWriteInModalMetadata(*actionCallback.View)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Invalid metadata of write-in modal", err)
+		return chat.Command{}, false
+	}
+	label, modalErrors := ParseWriteInModalSubmission(*actionCallback.View)
+	if len(modalErrors) > 0 {
+		writeViewErrors(writer, modalErrors)
+		return chat.Command{}, false
+	}
+	return chat.Command{Action: chat.ActionAddWriteIn, UserID: actionCallback.User.ID, PollID: metadata.PollID, WriteIn: label, ResponseURL: metadata.ResponseURL, Origin: actionCallback}, true
+}
+
+// handleModalAction adds an option field to the poll creation or edit modal.
+// The values of the other fields are kept by Slack because their block IDs
+// don't change.
+func (p *Platform) handleModalAction(writer http.ResponseWriter, actionCallback ActionResponse) {
+	writer.WriteHeader(http.StatusOK)
+	if len(actionCallback.Actions) == 0 || actionCallback.Actions[0].Name != AddOptionActionID {
+		return
+	}
+	if actionCallback.View.CallbackID == EditModalCallbackID {
+		p.addEditModalOption(*actionCallback.View)
+		return
+	}
+	metadata, err := ParsePollModalMetadata(*actionCallback.View)
+	if err != nil {
+		p.logger.Println("Invalid metadata of poll modal: ", err)
+		return
+	}
+	metadata.OptionCount++
+	err = UpdateView(p.config.OAuthToken, actionCallback.View.ID, actionCallback.View.Hash, NewPollModal(metadata))
+	if err != nil {
+		p.logger.Println("Error adding option to poll modal: ", err)
+	}
+}
+
+func (p *Platform) addEditModalOption(view View) {
+	updatedView, err := AddEditModalOption(view)
+	if err != nil {
+		p.logger.Println("Invalid metadata of edit modal: ", err)
+		return
+	}
+	err = UpdateView(p.config.OAuthToken, view.ID, view.Hash, updatedView)
+	if err != nil {
+		p.logger.Println("Error adding option to edit modal: ", err)
+	}
+}
+
+// isWorkspaceAdmin asks Slack whether the user is an admin or owner of the
+// workspace. Without OAuth token or if Slack can't be asked, the user isn't
+// treated as admin.
+func (p *Platform) isWorkspaceAdmin(userID string) bool {
+	if p.config.OAuthToken == "" {
+		return false
+	}
+	user, err := slackApi.New(p.config.OAuthToken).GetUserInfo(userID)
+	if err != nil {
+		p.logger.Println("Error getting user from Slack API to check for admin: ", err)
+		return false
+	}
+	return user.IsAdmin || user.IsOwner
+}
+
+func (p *Platform) WriteReply(writer http.ResponseWriter, command chat.Command, reply chat.Reply) {
+	actionCallback, _ := command.Origin.(ActionResponse)
+	if actionCallback.Type == DialogSubmissionType {
+		p.writeDialogSubmissionReply(writer, command, reply)
+		return
+	}
+	if actionCallback.Type == ViewSubmissionType {
+		p.writeViewSubmissionReply(writer, command, reply)
+		return
+	}
+	// Interactions with Block Kit messages ignore the response body
+	responseURL := ""
+	if actionCallback.Type == BlockActionsType {
+		responseURL = actionCallback.ResponseURL
+	}
+	switch reply.Kind {
+	case chat.ReplyPoll:
+		p.writeMessage(writer, responseURL, p.newPollMessage(reply))
+		p.confirmVote(command, reply)
+	case chat.ReplyMyVote:
+		p.writeMessage(writer, responseURL, NewMyVoteMessage(reply.Poll, reply.Choices))
+	case chat.ReplyVoteDetails:
+		err := ResolveVotersForPollDetails(&reply.Details, slackApi.New(p.config.OAuthToken))
+		if err != nil {
+			p.logger.Println("Error getting user names from Slack API: ", err)
+			p.writeMessage(writer, responseURL, p.renderer.NewErrorMessage("Error getting user names for voters!"))
+			return
+		}
+		p.writeMessage(writer, responseURL, p.renderer.NewVoteDetailMessage(reply.Details))
+	case chat.ReplyRankingForm:
+		dialog := NewRankingDialog(reply.Poll, actionCallback.ResponseURL)
+		err := OpenDialog(p.config.OAuthToken, actionCallback.TriggerID, dialog)
+		if err != nil {
+			p.logger.Println("Error opening ranking dialog: ", err)
+			p.writeMessage(writer, responseURL, p.renderer.NewErrorMessage("Error opening ranking dialog!"))
+			return
+		}
+		writer.WriteHeader(http.StatusOK)
+	case chat.ReplyEditForm:
+		err := OpenView(p.config.OAuthToken, actionCallback.TriggerID, NewEditModal(reply.Poll, actionCallback.ResponseURL))
+		if err != nil {
+			p.logger.Println("Error opening edit modal: ", err)
+			p.writeMessage(writer, responseURL, p.renderer.NewErrorMessage("Error opening form for editing the poll!"))
+			return
+		}
+		writer.WriteHeader(http.StatusOK)
+	case chat.ReplyWriteInForm:
+		metadata := WriteInModalMetadata{PollID: reply.Poll.ID, ResponseURL: actionCallback.ResponseURL}
+		err := OpenView(p.config.OAuthToken, actionCallback.TriggerID, NewWriteInModal(metadata))
+		if err != nil {
+			p.logger.Println("Error opening write-in modal: ", err)
+			p.writeMessage(writer, responseURL, p.renderer.NewErrorMessage("Error opening form for adding an option!"))
+			return
+		}
+		writer.WriteHeader(http.StatusOK)
+	default:
+		p.writeMessage(writer, responseURL, p.renderer.NewErrorMessage(reply.Text))
+	}
+}
+
+// writeDialogSubmissionReply closes the dialog by responding with an empty
+// body and updates the poll message through the response URL kept in the
+// dialog state.
+func (p *Platform) writeDialogSubmissionReply(writer http.ResponseWriter, command chat.Command, reply chat.Reply) {
+	if reply.Kind == chat.ReplyError {
+		writeDialogErrors(writer, NewRankingDialogErrorResponse(reply.Text).Errors)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+	if command.ResponseURL != "" {
+		go p.sendMessage(command.ResponseURL, p.newPollMessage(reply))
+	}
+	p.confirmVote(command, reply)
+}
+
+// confirmVote shows voters their current choices after a vote through the
+// response URL, which posts an ephemeral message besides the poll message.
+func (p *Platform) confirmVote(command chat.Command, reply chat.Reply) {
+	if reply.Choices == nil || command.ResponseURL == "" {
+		return
+	}
+	if len(reply.Choices) == 0 {
+		go p.sendMessage(command.ResponseURL, NewEphemeralMessage("Your vote was withdrawn."))
+		return
+	}
+	go p.sendMessage(command.ResponseURL, NewMyVoteMessage(reply.Poll, reply.Choices))
+}
+
+// writeViewSubmissionReply closes the modal and posts the new poll through the
+// response URL of the slash command which opened the modal. For edited polls
+// the response URL of the poll message is used, which updates the message.
+// Errors are shown at the first field of the modal.
+func (p *Platform) writeViewSubmissionReply(writer http.ResponseWriter, command chat.Command, reply chat.Reply) {
+	if reply.Kind == chat.ReplyError {
+		errorBlockID := modalQuestionBlockID
+		if command.Action == chat.ActionAddWriteIn {
+			errorBlockID = modalWriteInBlockID
+		}
+		writeViewErrors(writer, map[string]string{errorBlockID: reply.Text})
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+	if command.ResponseURL != "" {
+		go p.sendMessage(command.ResponseURL, p.newPollMessage(reply))
+	}
+}
+
+func writeViewErrors(writer http.ResponseWriter, modalErrors map[string]string) {
+	writer.Header().Set(httpHeaderContentType, contentTypeJSON)
+	writer.WriteHeader(http.StatusOK)
+	errorResponse := ViewErrorResponse{viewResponseActionError, modalErrors}
+	errorResponseJSON, _ := errorResponse.ToJSON()
+	writer.Write(errorResponseJSON)
+}
+
+func writeDialogErrors(writer http.ResponseWriter, dialogErrors []DialogError) {
+	writer.Header().Set(httpHeaderContentType, contentTypeJSON)
+	writer.WriteHeader(http.StatusOK)
+	errorResponse := DialogErrorResponse{dialogErrors}
+	errorResponseJSON, _ := errorResponse.ToJSON()
+	writer.Write(errorResponseJSON)
+}
+
+// UpdatePollMessage replaces the poll message through the stored response URL
+// of the poll.
+func (p *Platform) UpdatePollMessage(reply chat.Reply) error {
+	if reply.Poll.ResponseURL == "" {
+		return errors.Errorf("No response URL for poll %s, can't update poll message", reply.Poll.ID)
+	}
+	if reply.Kind == chat.ReplyDeletedPoll {
+		deletedMessage := NewDeletedPollMessage()
+		return deletedMessage.SendToResponseURL(reply.Poll.ResponseURL)
+	}
+	pollMessage := p.newPollMessage(reply)
+	return pollMessage.SendToResponseURL(reply.Poll.ResponseURL)
+}
+
+func (p *Platform) newPollMessage(reply chat.Reply) SlackMessage {
+	if reply.ResultsHidden {
+		return p.renderer.NewHiddenResultsPollMessage(reply.Poll, reply.Participants)
+	}
+	if reply.Poll.Type == poll.PollTypeRankedChoice {
+		return p.renderer.NewRankedPollMessage(reply.Poll, reply.Runoff)
+	}
+	return p.renderer.NewPollMessage(reply.Poll, reply.Results)
+}
+
+// writeMessage writes the message as response body or, if a response URL is
+// given, acknowledges the request and sends the message to the response URL.
+func (p *Platform) writeMessage(writer http.ResponseWriter, responseURL string, msg SlackMessage) {
+	if responseURL != "" {
+		writer.WriteHeader(http.StatusOK)
+		go p.sendMessage(responseURL, msg)
+		return
+	}
+	writer.Header().Set(httpHeaderContentType, contentTypeJSON)
+	//Can't set another status than OK because slack then shows an error msg. of its own
+	writer.WriteHeader(http.StatusOK)
+	responseJSON, _ := msg.ToJSON()
+	writer.Write(responseJSON)
+}
+
+func (p *Platform) sendMessage(responseURL string, msg SlackMessage) {
+	err := msg.SendToResponseURL(responseURL)
+	if err != nil {
+		p.logger.Println("Error sending message to response URL: ", err)
+	}
+}
+
+// slashCommandPlatform parses slash commands for new polls and shares
+// everything else with Platform.
+type slashCommandPlatform struct {
+	*Platform
+	anonymous bool
+	pollType  poll.PollType
+}
+
+func (p slashCommandPlatform) ParseCommand(writer http.ResponseWriter, request *http.Request) (chat.Command, bool) {
+	slackRequest, err := p.parseSlashCommandRequest(writer, request)
+	if err != nil {
+		p.logger.Println("Error reading and parsing request for new poll: ", err)
+		return chat.Command{}, false
+	}
+	return p.newPollCommand(writer, slackRequest, TokenizeSlashCommand(slackRequest.MsgText))
+}
+
+// newPollCommand creates the command for a new poll from the arguments of a
+// slash command. Without arguments the poll modal is opened instead.
+func (p slashCommandPlatform) newPollCommand(writer http.ResponseWriter, slackRequest SlashCommandRequest, tokens []Token) (chat.Command, bool) {
+	if len(tokens) == 0 {
+		p.openPollModal(writer, slackRequest)
+		return chat.Command{}, false
+	}
+	spec, err := ParsePollSpec(tokens, p.anonymous, p.pollType)
+	var newPoll poll.Poll
+	if err == nil {
+		newPoll, err = spec.NewPoll(time.Now())
+	}
+	if err != nil {
+		p.logger.Println("Invalid poll command: ", err)
+		p.writeMessage(writer, "", p.renderer.NewErrorMessage(err.Error()))
+		return chat.Command{}, false
+	}
+	return chat.Command{Action: chat.ActionCreatePoll, UserID: slackRequest.UserID, Poll: newPoll, ResponseURL: slackRequest.ResponseURL, Origin: slackRequest}, true
+}
+
+// openPollModal lets the user create a poll with a modal instead of the
+// arguments of the slash command.
+func (p slashCommandPlatform) openPollModal(writer http.ResponseWriter, slackRequest SlashCommandRequest) {
+	metadata := PollModalMetadata{ResponseURL: slackRequest.ResponseURL, Anonymous: p.anonymous, PollType: p.pollType}
+	err := OpenView(p.config.OAuthToken, slackRequest.TriggerID, NewPollModal(metadata))
+	if err != nil {
+		p.logger.Println("Error opening poll modal: ", err)
+		p.writeMessage(writer, "", p.renderer.NewErrorMessage("Error opening form for new poll!"))
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
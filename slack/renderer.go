@@ -0,0 +1,47 @@
+package slack
+
+import (
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+// MessageRenderer creates the messages of the integration in one of the
+// message formats of Slack.
+type MessageRenderer interface {
+	NewPollMessage(p poll.Poll, results map[int]uint64) SlackMessage
+	NewRankedPollMessage(p poll.Poll, runoff poll.RunoffResult) SlackMessage
+	// NewHiddenResultsPollMessage shows an open poll whose results are hidden
+	// with the number of voters instead of the results.
+	NewHiddenResultsPollMessage(p poll.Poll, participants int) SlackMessage
+	NewVoteDetailMessage(results map[string][]string) SlackMessage
+	NewErrorMessage(message string) SlackMessage
+}
+
+func NewMessageRenderer(useBlockKit bool) MessageRenderer {
+	if useBlockKit {
+		return BlockKitRenderer{}
+	}
+	return AttachmentRenderer{}
+}
+
+// AttachmentRenderer creates messages with the legacy attachments.
+type AttachmentRenderer struct{}
+
+func (AttachmentRenderer) NewPollMessage(p poll.Poll, results map[int]uint64) SlackMessage {
+	return NewPollMessage(p, results)
+}
+
+func (AttachmentRenderer) NewRankedPollMessage(p poll.Poll, runoff poll.RunoffResult) SlackMessage {
+	return NewRankedPollMessage(p, runoff)
+}
+
+func (AttachmentRenderer) NewHiddenResultsPollMessage(p poll.Poll, participants int) SlackMessage {
+	return NewHiddenResultsPollMessage(p, participants)
+}
+
+func (AttachmentRenderer) NewVoteDetailMessage(results map[string][]string) SlackMessage {
+	return NewVoteDetailMessage(results)
+}
+
+func (AttachmentRenderer) NewErrorMessage(message string) SlackMessage {
+	return NewSlackErrorMessage(message)
+}
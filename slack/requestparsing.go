@@ -0,0 +1,84 @@
+package slack
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func (p *Platform) parseSlashCommandRequest(writer http.ResponseWriter, request *http.Request) (SlashCommandRequest, error) {
+	if p.config.LogTraffic {
+		p.logger.Printf("Poll Creation Request: %v\n", request)
+	}
+	if request.Method != http.MethodPost {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return SlashCommandRequest{}, errors.New("MethodNotAllowed")
+	}
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return SlashCommandRequest{}, errors.Wrap(err, "BadRequest - Body couldn't be read!")
+
+	}
+	if p.config.LogTraffic {
+		p.logger.Printf("Poll Creation Request Query String: %s\n", string(body))
+	}
+	parsedBody, err := url.ParseQuery(string(body))
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return SlashCommandRequest{}, errors.Wrap(err, "BadRequest - Body couldn't be parsed!")
+	}
+	slackRequest := NewSlackRequest(parsedBody)
+
+	err = p.config.Verifier.Verify(request.Header, body, slackRequest.Token, time.Now())
+	if err != nil {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return SlashCommandRequest{}, errors.Wrap(err, "Unauthorized")
+	}
+
+	return slackRequest, nil
+}
+
+func (p *Platform) parseButtonActionRequest(writer http.ResponseWriter, request *http.Request) (ActionResponse, error) {
+	if p.config.LogTraffic {
+		p.logger.Printf("Poll Update Request: %v\n", request)
+	}
+	if request.Method != http.MethodPost {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return ActionResponse{}, errors.New("MethodNotAllowed")
+	}
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return ActionResponse{}, errors.Wrap(err, "BadRequest - Couldn't read body")
+	}
+	parsedBody, err := url.ParseQuery(string(body))
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return ActionResponse{}, errors.Wrap(err, "BadRequest - Couldn't parse request")
+	}
+	payload := parsedBody.Get("payload")
+	if payload == "" {
+		writer.WriteHeader(http.StatusBadRequest)
+		return ActionResponse{}, errors.New("BadRequest - No Payload")
+	}
+	if p.config.LogTraffic {
+		p.logger.Printf("Poll Update Request Payload: %s\n", payload)
+	}
+
+	actionCallback, err := NewActionResponseFromPayload(payload)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return ActionResponse{}, errors.Wrap(err, "InternalServerError - Error creating new action response from payload!")
+	}
+
+	err = p.config.Verifier.Verify(request.Header, body, actionCallback.Token, time.Now())
+	if err != nil {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return ActionResponse{}, errors.Wrap(err, "Unauthorized")
+	}
+	return actionCallback, nil
+}
@@ -0,0 +1,82 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	SignatureHeader        = "X-Slack-Signature"
+	RequestTimestampHeader = "X-Slack-Request-Timestamp"
+	signatureVersion       = "v0"
+)
+
+var (
+	ErrMissingSignature     = errors.New("Request is not signed!")
+	ErrInvalidSignature     = errors.New("Request signature is invalid!")
+	ErrTimestampOutOfWindow = errors.New("Request timestamp is outside of the allowed window!")
+	ErrInvalidLegacyToken   = errors.New("Request has an invalid verification token!")
+)
+
+// RequestVerifier checks that requests were sent by Slack. Requests are
+// verified by their signature if a signing secret is set. Unsigned requests
+// are only accepted with the legacy verification token if there is no signing
+// secret or AcceptLegacyToken is set, which allows migrating to signed
+// requests.
+type RequestVerifier struct {
+	SigningSecret     string
+	MaxSkew           time.Duration
+	LegacyToken       string
+	AcceptLegacyToken bool
+}
+
+// Verify checks the request with the given header and raw body. token is the
+// verification token sent within the request body.
+func (v RequestVerifier) Verify(header http.Header, body []byte, token string, now time.Time) error {
+	if v.SigningSecret != "" && (header.Get(SignatureHeader) != "" || !v.AcceptLegacyToken) {
+		return VerifySignature(header, body, v.SigningSecret, v.MaxSkew, now)
+	}
+	if v.LegacyToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(v.LegacyToken)) != 1 {
+		return ErrInvalidLegacyToken
+	}
+	return nil
+}
+
+// VerifySignature checks the HMAC-SHA256 signature of a request as described
+// in https://api.slack.com/authentication/verifying-requests-from-slack.
+// Requests whose timestamp differs from now by more than maxSkew are rejected
+// to prevent replays.
+func VerifySignature(header http.Header, body []byte, signingSecret string, maxSkew time.Duration, now time.Time) error {
+	signature := header.Get(SignatureHeader)
+	timestampHeader := header.Get(RequestTimestampHeader)
+	if signature == "" || timestampHeader == "" {
+		return ErrMissingSignature
+	}
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidSignature, "Invalid request timestamp %q", timestampHeader)
+	}
+	skew := now.Sub(time.Unix(timestamp, 0))
+	if skew > maxSkew || skew < -maxSkew {
+		return errors.Wrapf(ErrTimestampOutOfWindow, "Request timestamp %d differs by %s", timestamp, skew)
+	}
+	expectedSignature := computeSignature(timestampHeader, body, signingSecret)
+	if !hmac.Equal([]byte(signature), []byte(expectedSignature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func computeSignature(timestamp string, body []byte, signingSecret string) string {
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte(signatureVersion + ":" + timestamp + ":"))
+	mac.Write(body)
+	return signatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,85 @@
+package slack
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	testSigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+	testLegacyToken   = "xyzz0WbapA4vBCDEFasx0q6G"
+)
+
+type recordedRequest struct {
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+func readRecordedRequest(t *testing.T, fileName string) (http.Header, []byte) {
+	dat, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal("Error reading sample file: ", err)
+	}
+	var request recordedRequest
+	err = json.Unmarshal(dat, &request)
+	if err != nil {
+		t.Fatal("Error parsing sample file: ", err)
+	}
+	header := http.Header{}
+	for name, value := range request.Headers {
+		header.Set(name, value)
+	}
+	return header, []byte(request.Body)
+}
+
+func TestRequestVerifier(t *testing.T) {
+	signedVerifier := RequestVerifier{SigningSecret: testSigningSecret, MaxSkew: 5 * time.Minute, LegacyToken: testLegacyToken}
+	migratingVerifier := signedVerifier
+	migratingVerifier.AcceptLegacyToken = true
+	legacyVerifier := RequestVerifier{LegacyToken: testLegacyToken}
+	requestTime := time.Unix(1531420618, 0)
+	testCases := []struct {
+		name          string
+		fixture       string
+		verifier      RequestVerifier
+		stripHeaders  bool
+		tamperBody    bool
+		token         string
+		now           time.Time
+		expectedError error
+	}{
+		{"SignedSlashCommand", "exampleSignedSlashCommandRequest.json", signedVerifier, false, false, "", requestTime, nil},
+		{"SignedAction", "exampleSignedActionRequest.json", signedVerifier, false, false, "", requestTime.Add(time.Minute), nil},
+		{"TamperedBody", "exampleSignedSlashCommandRequest.json", signedVerifier, false, true, "", requestTime, ErrInvalidSignature},
+		{"WrongSecret", "exampleSignedSlashCommandRequest.json", RequestVerifier{SigningSecret: "other", MaxSkew: 5 * time.Minute}, false, false, "", requestTime, ErrInvalidSignature},
+		{"ReplayedRequest", "exampleSignedSlashCommandRequest.json", signedVerifier, false, false, "", requestTime.Add(6 * time.Minute), ErrTimestampOutOfWindow},
+		{"RequestFromFuture", "exampleSignedSlashCommandRequest.json", signedVerifier, false, false, "", requestTime.Add(-6 * time.Minute), ErrTimestampOutOfWindow},
+		{"UnsignedRequest", "exampleSignedSlashCommandRequest.json", signedVerifier, true, false, testLegacyToken, requestTime, ErrMissingSignature},
+		{"UnsignedRequestWithLegacyToken", "exampleSignedSlashCommandRequest.json", migratingVerifier, true, false, testLegacyToken, requestTime, nil},
+		{"UnsignedRequestWithWrongLegacyToken", "exampleSignedSlashCommandRequest.json", migratingVerifier, true, false, "wrong", requestTime, ErrInvalidLegacyToken},
+		{"SignedRequestWhileMigrating", "exampleSignedSlashCommandRequest.json", migratingVerifier, false, true, testLegacyToken, requestTime, ErrInvalidSignature},
+		{"LegacyTokenOnly", "exampleSignedSlashCommandRequest.json", legacyVerifier, true, false, testLegacyToken, requestTime, nil},
+		{"EmptyLegacyToken", "exampleSignedSlashCommandRequest.json", RequestVerifier{}, true, false, "", requestTime, ErrInvalidLegacyToken},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			header, body := readRecordedRequest(t, testCase.fixture)
+			if testCase.stripHeaders {
+				header.Del(SignatureHeader)
+				header.Del(RequestTimestampHeader)
+			}
+			if testCase.tamperBody {
+				body = append(body, []byte("&text=tampered")...)
+			}
+			err := testCase.verifier.Verify(header, body, testCase.token, testCase.now)
+			if errors.Cause(err) != testCase.expectedError {
+				t.Errorf("Expected error %v but got %v", testCase.expectedError, err)
+			}
+		})
+	}
+}
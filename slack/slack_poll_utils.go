@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"sort"
 	"strings"
+	"time"
 
 	"fmt"
 	"strconv"
@@ -14,9 +15,54 @@ import (
 
 var RefreshButtonActionValue string = "refresh"
 var PollDetailButtonActionValue string = "poll_details"
+var ClosePollButtonActionValue string = "close_poll"
+var RankOptionsButtonActionValue string = "rank_options"
+var MyVoteButtonActionValue string = "my_vote"
+var WithdrawVoteButtonActionValue string = "withdraw_vote"
+var EditPollActionValue string = "edit_poll"
+var AddWriteInButtonActionValue string = "add_write_in"
+var DisableWriteInsActionValue string = "disable_write_ins"
 var ResponseTypeInChannel string = "in_channel"
 var ResponseTypeEphemeral string = "ephemeral"
 
+// OptionValuePrefix starts the values of vote buttons, which are followed by
+// the ID of the option. Buttons of messages sent before options had IDs only
+// hold the position of the option.
+var OptionValuePrefix string = "option_"
+
+func optionValue(option poll.Option) string {
+	return OptionValuePrefix + strconv.Itoa(option.ID)
+}
+
+// ParseOptionValue returns the option of a vote button value and whether it
+// is the position of the option instead of its ID.
+func ParseOptionValue(value string) (option int, isPosition bool, err error) {
+	if strings.HasPrefix(value, OptionValuePrefix) {
+		option, err = strconv.Atoi(strings.TrimPrefix(value, OptionValuePrefix))
+		return option, false, err
+	}
+	option, err = strconv.Atoi(value)
+	return option, true, err
+}
+
+// joinOptionTexts lists the options of a poll one per line.
+func joinOptionTexts(p poll.Poll) string {
+	var texts []string
+	for _, option := range p.Options {
+		texts = append(texts, option.Text())
+	}
+	return "• " + strings.Join(texts, "\n• ")
+}
+
+// optionText returns the text of the option with the given ID. Options which
+// don't exist anymore are shown by their ID.
+func optionText(p poll.Poll, id int) string {
+	if option, found := p.FindOption(id); found {
+		return option.Text()
+	}
+	return fmt.Sprintf("Option %d", id)
+}
+
 func NewVoteDetailMessage(results map[string][]string) SlackMessage {
 	var messageText bytes.Buffer
 	buildVoteDetailMessageTest(results, &messageText)
@@ -53,26 +99,30 @@ func NewPollDetailButtonAttachment(poll poll.Poll) Attachment {
 }
 
 func NewPollMessage(poll poll.Poll, results map[int]uint64) SlackMessage {
+	if poll.Closed {
+		return newClosedPollMessage(poll, results)
+	}
 	var msg SlackMessage
 	msg.ResponseType = ResponseTypeInChannel
 	msg.Text = poll.Question
+	if hint := votingHint(poll); hint != "" {
+		msg.Text += "\n" + hint
+	}
 	msg.ReplaceOriginal = true
 	var buttonAttachment Attachment
-	for index, option := range poll.Options {
-		var voteCount uint64 = results[index]
+	for _, option := range poll.Options {
 		buttonAttachment = Attachment{}
 		buttonAttachment.Fallback = "Poll not available"
 		buttonAttachment.CallbackID = poll.ID
-		buttonAttachment.Text = option
-		voteCountText := "Vote"
-		if voteCount != 1 {
-			voteCountText += "s"
+		buttonAttachment.Text = option.Text()
+		if proposer := proposedBy(option); proposer != "" {
+			buttonAttachment.Text += " (" + proposer + ")"
 		}
 		var button Action
-		button.Name = option + "_button"
-		button.Text = fmt.Sprintf("%d %s", voteCount, voteCountText)
+		button.Name = option.Label + "_button"
+		button.Text = formatVoteCount(results[option.ID])
 		button.Type = "button"
-		button.Value = strconv.Itoa(index)
+		button.Value = optionValue(option)
 		buttonAttachment.AddAction(button)
 		msg.AddAttachment(buttonAttachment)
 	}
@@ -80,9 +130,203 @@ func NewPollMessage(poll poll.Poll, results map[int]uint64) SlackMessage {
 		msg.AddAttachment(NewPollDetailButtonAttachment(poll))
 	}
 	msg.AddAttachment(NewRefreshButtonAttachment(poll))
+	msg.AddAttachment(NewClosePollButtonAttachment(poll))
 	return msg
 }
 
+// NewHiddenResultsPollMessage shows an open poll without its results. The
+// buttons only show the options and the number of voters is shown instead of
+// the votes, so earlier votes don't influence later voters. Vote details
+// aren't offered, as they would reveal the results.
+func NewHiddenResultsPollMessage(p poll.Poll, participants int) SlackMessage {
+	var msg SlackMessage
+	msg.ResponseType = ResponseTypeInChannel
+	msg.Text = p.Question
+	if hint := votingHint(p); hint != "" {
+		msg.Text += "\n" + hint
+	}
+	msg.Text += "\n" + hiddenResultsHint(participants)
+	msg.ReplaceOriginal = true
+	if p.Type == poll.PollTypeRankedChoice {
+		var optionsAttachment Attachment
+		optionsAttachment.Fallback = "Poll not available"
+		optionsAttachment.CallbackID = p.ID
+		optionsAttachment.Text = joinOptionTexts(p)
+		msg.AddAttachment(optionsAttachment)
+		var rankButtonAttachment Attachment
+		rankButtonAttachment.Fallback = "Poll not available"
+		rankButtonAttachment.CallbackID = p.ID
+		rankButtonAttachment.Color = "#008000"
+		rankButtonAttachment.AddAction(Action{RankOptionsButtonActionValue + "_button", "Rank options", "button", RankOptionsButtonActionValue})
+		msg.AddAttachment(rankButtonAttachment)
+	} else {
+		for _, option := range p.Options {
+			var buttonAttachment Attachment
+			buttonAttachment.Fallback = "Poll not available"
+			buttonAttachment.CallbackID = p.ID
+			buttonAttachment.AddAction(Action{option.Label + "_button", option.Text(), "button", optionValue(option)})
+			msg.AddAttachment(buttonAttachment)
+		}
+	}
+	msg.AddAttachment(NewRefreshButtonAttachment(p))
+	msg.AddAttachment(NewClosePollButtonAttachment(p))
+	return msg
+}
+
+func hiddenResultsHint(participants int) string {
+	participantText := "participants"
+	if participants == 1 {
+		participantText = "participant"
+	}
+	return fmt.Sprintf("_Results are hidden until the poll is closed, %d %s so far_", participants, participantText)
+}
+
+// votingHint explains how to vote in polls which don't work like a simple
+// single choice poll.
+func votingHint(p poll.Poll) string {
+	if p.Type == poll.PollTypeRankedChoice {
+		return "_Click \"Rank options\" to submit your ranking, submitting again replaces it_"
+	}
+	if p.Type != poll.PollTypeMultipleChoice {
+		return ""
+	}
+	if p.MaxChoices == 1 {
+		return "_Select one option, click again to remove your vote_"
+	}
+	if p.MaxChoices > 1 {
+		return fmt.Sprintf("_Select up to %d options, click again to remove a vote_", p.MaxChoices)
+	}
+	return "_Select all options that apply, click again to remove a vote_"
+}
+
+// newClosedPollMessage shows the final results of a poll without any buttons
+// for voting.
+func newClosedPollMessage(poll poll.Poll, results map[int]uint64) SlackMessage {
+	var msg SlackMessage
+	msg.ResponseType = ResponseTypeInChannel
+	msg.Text = poll.Question
+	msg.ReplaceOriginal = true
+	for _, option := range poll.Options {
+		var resultAttachment Attachment
+		resultAttachment.Fallback = "Poll not available"
+		resultAttachment.CallbackID = poll.ID
+		resultAttachment.Text = fmt.Sprintf("%s: %s", option.Text(), formatVoteCount(results[option.ID]))
+		msg.AddAttachment(resultAttachment)
+	}
+	var closedAttachment Attachment
+	if !poll.Anonymous {
+		closedAttachment = NewPollDetailButtonAttachment(poll)
+	}
+	closedAttachment.Fallback = "Poll not available"
+	closedAttachment.CallbackID = poll.ID
+	closedAttachment.Color = "#808080"
+	closedAttachment.Text = "This poll is closed."
+	msg.AddAttachment(closedAttachment)
+	return msg
+}
+
+// NewRankedPollMessage shows the options of a ranked choice poll together
+// with every round of the instant-runoff. Rankings are submitted with a dialog
+// opened by the rank options button.
+func NewRankedPollMessage(poll poll.Poll, runoff poll.RunoffResult) SlackMessage {
+	var msg SlackMessage
+	msg.ResponseType = ResponseTypeInChannel
+	msg.Text = poll.Question
+	msg.ReplaceOriginal = true
+	if !poll.Closed {
+		msg.Text += "\n" + votingHint(poll)
+	}
+	var optionsAttachment Attachment
+	optionsAttachment.Fallback = "Poll not available"
+	optionsAttachment.CallbackID = poll.ID
+	optionsAttachment.Text = joinOptionTexts(poll)
+	msg.AddAttachment(optionsAttachment)
+	var runoffAttachment Attachment
+	runoffAttachment.Fallback = "Poll not available"
+	runoffAttachment.CallbackID = poll.ID
+	runoffAttachment.Title = "Instant-runoff"
+	runoffAttachment.Text = formatRunoff(poll, runoff)
+	msg.AddAttachment(runoffAttachment)
+	if poll.Closed {
+		var closedAttachment Attachment
+		if !poll.Anonymous {
+			closedAttachment = NewPollDetailButtonAttachment(poll)
+		}
+		closedAttachment.Fallback = "Poll not available"
+		closedAttachment.CallbackID = poll.ID
+		closedAttachment.Color = "#808080"
+		closedAttachment.Text = "This poll is closed."
+		msg.AddAttachment(closedAttachment)
+		return msg
+	}
+	var rankButtonAttachment Attachment
+	rankButtonAttachment.Fallback = "Poll not available"
+	rankButtonAttachment.CallbackID = poll.ID
+	rankButtonAttachment.Color = "#008000"
+	rankButtonAttachment.AddAction(Action{RankOptionsButtonActionValue + "_button", "Rank options", "button", RankOptionsButtonActionValue})
+	msg.AddAttachment(rankButtonAttachment)
+	if !poll.Anonymous {
+		msg.AddAttachment(NewPollDetailButtonAttachment(poll))
+	}
+	msg.AddAttachment(NewRefreshButtonAttachment(poll))
+	msg.AddAttachment(NewClosePollButtonAttachment(poll))
+	return msg
+}
+
+// formatRunoff writes one line per runoff round with the votes of all options
+// still in the runoff, in the order of the poll, followed by the winner.
+func formatRunoff(p poll.Poll, runoff poll.RunoffResult) string {
+	if runoff.Winner < 0 {
+		return "No votes yet"
+	}
+	var runoffText bytes.Buffer
+	for roundIndex, round := range runoff.Rounds {
+		var counts []string
+		for _, option := range p.Options {
+			if count, ok := round.Counts[option.ID]; ok {
+				counts = append(counts, fmt.Sprintf("%s %d", option.Text(), count))
+			}
+		}
+		fmt.Fprintf(&runoffText, "Round %d: %s", roundIndex+1, strings.Join(counts, ", "))
+		if round.Eliminated >= 0 {
+			fmt.Fprintf(&runoffText, " → %s eliminated", optionText(p, round.Eliminated))
+		}
+		runoffText.WriteString("\n")
+	}
+	fmt.Fprintf(&runoffText, "*Winner: %s*", optionText(p, runoff.Winner))
+	return runoffText.String()
+}
+
+func formatVoteCount(voteCount uint64) string {
+	voteCountText := "Vote"
+	if voteCount != 1 {
+		voteCountText += "s"
+	}
+	return fmt.Sprintf("%d %s", voteCount, voteCountText)
+}
+
+func NewClosePollButtonAttachment(poll poll.Poll) Attachment {
+	var closeButtonAttachment Attachment
+	closeButtonAttachment.Fallback = "Poll not available"
+	closeButtonAttachment.CallbackID = poll.ID
+	if !poll.Deadline.IsZero() {
+		closeButtonAttachment.Text = fmt.Sprintf("Voting closes <!date^%d^{date_short_pretty} at {time}|%s>",
+			poll.Deadline.Unix(), poll.Deadline.UTC().Format(time.RFC1123))
+	}
+	closeButton := Action{ClosePollButtonActionValue + "_button", "Close poll", "button", ClosePollButtonActionValue}
+	closeButtonAttachment.Color = "#808080"
+	closeButtonAttachment.AddAction(closeButton)
+	closeButtonAttachment.AddAction(Action{EditPollActionValue + "_button", "Edit poll", "button", EditPollActionValue})
+	if poll.AllowWriteIns {
+		closeButtonAttachment.AddAction(Action{DisableWriteInsActionValue + "_button", "Disable write-ins", "button", DisableWriteInsActionValue})
+	}
+	return closeButtonAttachment
+}
+
+// NewRefreshButtonAttachment holds the refresh button together with the
+// buttons showing and withdrawing the vote of the user and the button for
+// adding an option to polls which allow write-ins, as polls can't have more
+// attachments.
 func NewRefreshButtonAttachment(poll poll.Poll) Attachment {
 	var refreshButtonAttachment Attachment
 	refreshButtonAttachment.Fallback = "Poll not available"
@@ -90,9 +334,68 @@ func NewRefreshButtonAttachment(poll poll.Poll) Attachment {
 	refreshButton := Action{RefreshButtonActionValue + "_button", "Refresh", "button", RefreshButtonActionValue}
 	refreshButtonAttachment.Color = "#ff0000"
 	refreshButtonAttachment.AddAction(refreshButton)
+	refreshButtonAttachment.AddAction(Action{MyVoteButtonActionValue + "_button", "My vote", "button", MyVoteButtonActionValue})
+	refreshButtonAttachment.AddAction(Action{WithdrawVoteButtonActionValue + "_button", "Withdraw vote", "button", WithdrawVoteButtonActionValue})
+	if poll.AllowWriteIns {
+		refreshButtonAttachment.AddAction(Action{AddWriteInButtonActionValue + "_button", "Add option", "button", AddWriteInButtonActionValue})
+	}
 	return refreshButtonAttachment
 }
 
+// proposedBy tells who added an option as write-in. It is empty for options
+// of the creator.
+func proposedBy(option poll.Option) string {
+	if option.ProposedBy == "" {
+		return ""
+	}
+	return "proposed by <@" + option.ProposedBy + ">"
+}
+
+// NewMyVoteMessage tells a voter which options they currently vote for. It is
+// only shown to the voter, so it doesn't reveal votes in anonymous polls.
+func NewMyVoteMessage(p poll.Poll, choices []int) SlackMessage {
+	open := p.IsOpen(time.Now())
+	var messageText string
+	switch {
+	case len(choices) == 0 && open:
+		messageText = "You haven't voted in this poll yet."
+	case len(choices) == 0:
+		messageText = "You didn't vote in this poll."
+	case p.Type == poll.PollTypeRankedChoice:
+		var ranking []string
+		for rank, option := range choices {
+			ranking = append(ranking, fmt.Sprintf("%d. *%s*", rank+1, optionText(p, option)))
+		}
+		messageText = "Your ranking: " + strings.Join(ranking, ", ")
+		if open {
+			messageText += " (rank the options again to change it)"
+		}
+	case p.Type == poll.PollTypeMultipleChoice:
+		messageText = "You voted for " + joinOptions(p, choices)
+		if open {
+			messageText += " (click again to retract)"
+		}
+	default:
+		messageText = "You voted for " + joinOptions(p, choices)
+		if open {
+			messageText += " (click another option to change your vote or the same one to withdraw it)"
+		}
+	}
+	return NewEphemeralMessage(messageText)
+}
+
+// joinOptions lists the chosen options like "*A*, *B* and *C*".
+func joinOptions(p poll.Poll, choices []int) string {
+	var options []string
+	for _, option := range choices {
+		options = append(options, "*"+optionText(p, option)+"*")
+	}
+	if len(options) == 1 {
+		return options[0]
+	}
+	return strings.Join(options[:len(options)-1], ", ") + " and " + options[len(options)-1]
+}
+
 func ResolveVotersForPollDetails(pollDetails *map[string][]string, slackApiCient *slackApi.Client) error {
 	for option, userList := range *pollDetails {
 		resolvedUsers, err := ResolveVoterNamesBySlackID(&userList, slackApiCient)
@@ -5,7 +5,11 @@ import (
 	"flag"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"bytes"
 
@@ -30,7 +34,7 @@ func TestNewPollMessageSimple(t *testing.T) {
 	if err != nil {
 		t.Fatal("Error parsing sample file: ", err)
 	}
-	poll := poll.Poll{ID: "6b57e603-2366-4116-b51d-011837677e33", Question: "Test Question", CreatorID: "foobar", Options: []string{"Answer 1", "Answer 2"}}
+	poll := poll.Poll{ID: "6b57e603-2366-4116-b51d-011837677e33", Question: "Test Question", CreatorID: "foobar", Options: poll.NewOptions("Answer 1", "Answer 2")}
 	actualPollMessage := NewPollMessage(poll, nil)
 	if diff := deep.Equal(expectedPollMessage, actualPollMessage); diff != nil {
 		t.Logf("Created poll message is not as expected.\nExpected: %v\nActual:%v\n", expectedPollMessage, actualPollMessage)
@@ -49,7 +53,7 @@ func TestNewPollMessageAnonymous(t *testing.T) {
 	if err != nil {
 		t.Fatal("Error parsing sample file: ", err)
 	}
-	poll := poll.Poll{ID: "f843f53f-d7d2-4050-a5d7-fd222114038f", Question: "Test Question", CreatorID: "foobar", Options: []string{"Answer 1", "Answer 2"}, Anonymous: true}
+	poll := poll.Poll{ID: "f843f53f-d7d2-4050-a5d7-fd222114038f", Question: "Test Question", CreatorID: "foobar", Options: poll.NewOptions("Answer 1", "Answer 2"), Anonymous: true}
 	actualPollMessage := NewPollMessage(poll, nil)
 	if diff := deep.Equal(expectedPollMessage, actualPollMessage); diff != nil {
 		t.Logf("Created poll message is not as expected.\nExpected: %v\nActual:%v\n", expectedPollMessage, actualPollMessage)
@@ -58,6 +62,186 @@ func TestNewPollMessageAnonymous(t *testing.T) {
 	}
 }
 
+func TestNewPollMessageClosed(t *testing.T) {
+	dat, err := ioutil.ReadFile("exampleClosedPollMessage.json")
+	if err != nil {
+		t.Fatal("Error reading sample file: ", err)
+	}
+	var expectedPollMessage SlackMessage
+	err = json.Unmarshal(dat, &expectedPollMessage)
+	if err != nil {
+		t.Fatal("Error parsing sample file: ", err)
+	}
+	poll := poll.Poll{ID: "0d1b2b5f-7a4c-4c36-9f0c-5d1e8f6a7b21", Question: "Test Question", CreatorID: "foobar", Options: poll.NewOptions("Answer 1", "Answer 2"), Closed: true}
+	actualPollMessage := NewPollMessage(poll, map[int]uint64{0: 2, 1: 1})
+	if diff := deep.Equal(expectedPollMessage, actualPollMessage); diff != nil {
+		t.Logf("Created poll message is not as expected.\nExpected: %v\nActual:%v\n", expectedPollMessage, actualPollMessage)
+		t.Log("Diff: ", diff)
+		t.Fail()
+	}
+}
+
+func TestNewPollMessageMultipleChoice(t *testing.T) {
+	multiPoll := poll.Poll{ID: "1", Question: "Test Question", CreatorID: "foobar", Options: poll.NewOptions("Answer 1", "Answer 2", "Answer 3"), Type: poll.PollTypeMultipleChoice}
+	expectedTexts := map[int]string{
+		0: "Test Question\n_Select all options that apply, click again to remove a vote_",
+		2: "Test Question\n_Select up to 2 options, click again to remove a vote_",
+	}
+	for maxChoices, expectedText := range expectedTexts {
+		multiPoll.MaxChoices = maxChoices
+		actualPollMessage := NewPollMessage(multiPoll, map[int]uint64{0: 2, 2: 1})
+		if actualPollMessage.Text != expectedText {
+			t.Errorf("Expected text %q but got %q", expectedText, actualPollMessage.Text)
+		}
+		if len(actualPollMessage.Attachments) != 6 || actualPollMessage.Attachments[0].Actions[0].Text != "2 Votes" {
+			t.Errorf("Unexpected attachments for multiple choice poll: %v", actualPollMessage.Attachments)
+		}
+	}
+}
+
+func TestNewHiddenResultsPollMessage(t *testing.T) {
+	hiddenPoll := poll.Poll{ID: "1", Question: "Test Question", CreatorID: "foobar", Options: poll.NewOptions("Answer 1", "Answer 2"), HideResults: true}
+	actualPollMessage := NewHiddenResultsPollMessage(hiddenPoll, 1)
+	expectedText := "Test Question\n_Results are hidden until the poll is closed, 1 participant so far_"
+	if actualPollMessage.Text != expectedText {
+		t.Errorf("Expected text %q but got %q", expectedText, actualPollMessage.Text)
+	}
+	if len(actualPollMessage.Attachments) != 4 {
+		t.Fatalf("Expected option, refresh and close attachments but got %v", actualPollMessage.Attachments)
+	}
+	for index, option := range hiddenPoll.Options {
+		action := actualPollMessage.Attachments[index].Actions[0]
+		if action.Text != option.Label || action.Value != "option_"+strconv.Itoa(option.ID) || actualPollMessage.Attachments[index].Text != "" {
+			t.Errorf("Expected only the option on the button of %q but got %v", option.Label, actualPollMessage.Attachments[index])
+		}
+	}
+	hiddenPoll.Type = poll.PollTypeRankedChoice
+	actualPollMessage = NewHiddenResultsPollMessage(hiddenPoll, 2)
+	if len(actualPollMessage.Attachments) != 4 || actualPollMessage.Attachments[1].Actions[0].Value != RankOptionsButtonActionValue {
+		t.Errorf("Unexpected attachments for ranked choice poll with hidden results: %v", actualPollMessage.Attachments)
+	}
+}
+
+func TestNewPollMessageWithWriteIns(t *testing.T) {
+	writeInPoll := poll.Poll{ID: "1", Question: "Test Question", CreatorID: "foobar", Options: poll.NewOptions("Answer 1", "Answer 2"), AllowWriteIns: true}
+	writeInPoll.Options = append(writeInPoll.Options, poll.Option{ID: 2, Label: "Answer 3", ProposedBy: "U2CERLKJA"})
+	actualPollMessage := NewPollMessage(writeInPoll, map[int]uint64{})
+	if len(actualPollMessage.Attachments) != 6 {
+		t.Fatalf("Expected option, detail, refresh and close attachments but got %v", actualPollMessage.Attachments)
+	}
+	if text := actualPollMessage.Attachments[2].Text; text != "Answer 3 (proposed by <@U2CERLKJA>)" {
+		t.Errorf("Expected proposer of write-in but got %q", text)
+	}
+	refreshActions := actualPollMessage.Attachments[4].Actions
+	if refreshActions[len(refreshActions)-1].Value != AddWriteInButtonActionValue {
+		t.Errorf("Expected button for adding an option but got %v", refreshActions)
+	}
+	closeActions := actualPollMessage.Attachments[5].Actions
+	if closeActions[len(closeActions)-1].Value != DisableWriteInsActionValue {
+		t.Errorf("Expected button for disabling write-ins but got %v", closeActions)
+	}
+}
+
+func TestParseOptionValue(t *testing.T) {
+	testCases := []struct {
+		value              string
+		expectedOption     int
+		expectedIsPosition bool
+		expectError        bool
+	}{
+		{"option_3", 3, false, false},
+		{"1", 1, true, false},
+		{"option_x", 0, false, true},
+		{"refresh", 0, true, true},
+	}
+	for _, testCase := range testCases {
+		option, isPosition, err := ParseOptionValue(testCase.value)
+		if (err != nil) != testCase.expectError || (err == nil && (option != testCase.expectedOption || isPosition != testCase.expectedIsPosition)) {
+			t.Errorf("Parsing %q returned %d, %t and error %v", testCase.value, option, isPosition, err)
+		}
+	}
+}
+
+func TestNewMyVoteMessage(t *testing.T) {
+	testPoll := poll.Poll{Question: "Test Question", Options: poll.NewOptions("Answer 1", "Answer 2", "Answer 3")}
+	multiPoll := testPoll
+	multiPoll.Type = poll.PollTypeMultipleChoice
+	rankedPoll := testPoll
+	rankedPoll.Type = poll.PollTypeRankedChoice
+	closedPoll := testPoll
+	closedPoll.Closed = true
+	testCases := []struct {
+		poll         poll.Poll
+		choices      []int
+		expectedText string
+	}{
+		{testPoll, []int{}, "You haven't voted in this poll yet."},
+		{testPoll, []int{1}, "You voted for *Answer 2* (click another option to change your vote or the same one to withdraw it)"},
+		{multiPoll, []int{0, 1, 2}, "You voted for *Answer 1*, *Answer 2* and *Answer 3* (click again to retract)"},
+		{rankedPoll, []int{2, 0}, "Your ranking: 1. *Answer 3*, 2. *Answer 1* (rank the options again to change it)"},
+		{closedPoll, []int{0}, "You voted for *Answer 1*"},
+		{closedPoll, []int{}, "You didn't vote in this poll."},
+	}
+	for _, testCase := range testCases {
+		msg := NewMyVoteMessage(testCase.poll, testCase.choices)
+		if msg.Text != testCase.expectedText || msg.ResponseType != ResponseTypeEphemeral {
+			t.Errorf("Expected ephemeral message %q but got %v", testCase.expectedText, msg)
+		}
+	}
+}
+
+func TestNewRankedPollMessage(t *testing.T) {
+	dat, err := ioutil.ReadFile("exampleRankedPollMessage.json")
+	if err != nil {
+		t.Fatal("Error reading sample file: ", err)
+	}
+	var expectedPollMessage SlackMessage
+	err = json.Unmarshal(dat, &expectedPollMessage)
+	if err != nil {
+		t.Fatal("Error parsing sample file: ", err)
+	}
+	rankedPoll := poll.Poll{ID: "3c9f1a52-8e1d-4f0b-9a57-2d6b4e0c7f13", Question: "Project name", CreatorID: "foobar", Options: poll.NewOptions("Apollo", "Gemini", "Mercury"), Type: poll.PollTypeRankedChoice}
+	runoff := poll.RunoffResult{
+		Rounds: []poll.RunoffRound{
+			{Counts: map[int]uint64{0: 2, 1: 2, 2: 1}, Eliminated: 2},
+			{Counts: map[int]uint64{0: 3, 1: 2}, Eliminated: -1},
+		},
+		Winner: 0,
+	}
+	actualPollMessage := NewRankedPollMessage(rankedPoll, runoff)
+	if diff := deep.Equal(expectedPollMessage, actualPollMessage); diff != nil {
+		t.Logf("Created poll message is not as expected.\nExpected: %v\nActual:%v\n", expectedPollMessage, actualPollMessage)
+		t.Log("Diff: ", diff)
+		t.Fail()
+	}
+}
+
+func TestParseRankingSubmission(t *testing.T) {
+	testCases := []struct {
+		name            string
+		submission      map[string]string
+		expectedRanking []int
+		expectedErrors  []DialogError
+	}{
+		{"CompleteRanking", map[string]string{"rank_0": "2", "rank_1": "0", "rank_2": "1"}, []int{2, 0, 1}, nil},
+		{"PartialRanking", map[string]string{"rank_0": "1", "rank_1": "", "rank_2": "2"}, []int{1, 2}, nil},
+		{"EmptyRanking", map[string]string{}, nil, []DialogError{{"rank_0", "Select at least one option"}}},
+		{"DuplicateOption", map[string]string{"rank_0": "1", "rank_1": "1"}, []int{1}, []DialogError{{"rank_1", "This option is already ranked higher"}}},
+		{"InvalidOption", map[string]string{"rank_0": "-1"}, nil, []DialogError{{"rank_0", "Invalid option"}}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			ranking, dialogErrors := ParseRankingSubmission(testCase.submission)
+			if diff := deep.Equal(testCase.expectedRanking, ranking); diff != nil {
+				t.Error("Unexpected ranking: ", diff)
+			}
+			if diff := deep.Equal(testCase.expectedErrors, dialogErrors); diff != nil {
+				t.Error("Unexpected dialog errors: ", diff)
+			}
+		})
+	}
+}
+
 func TestNewVoteDetailMessage(t *testing.T) {
 	expectedText := "• Option1: A, B, C\n• Option2: A, B\n• Option3: \n"
 	input := map[string][]string{
@@ -135,6 +319,147 @@ func TestParseSlashCommand(t *testing.T) {
 	}
 }
 
+func TestTokenizeSlashCommand(t *testing.T) {
+	tokens := TokenizeSlashCommand(`q --multi "--not a flag" "open`)
+	expected := []Token{{"q", false}, {"--multi", false}, {"--not a flag", true}, {"open", true}}
+	if diff := deep.Equal(expected, tokens); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestParseQuotedSlashCommand(t *testing.T) {
+	testCases := []struct {
+		arguments      string
+		expectedResult []string
+	}{
+		{"“Lunch?” „Thai food“ «Fish & chips» ‘Ice cream’", []string{"Lunch?", "Thai food", "Fish & chips", "Ice cream"}},
+		{"“Lunch?\" Pizza", []string{"Lunch?", "Pizza"}},
+		{"'Who's hungry?' 'Pizza' Burgers", []string{"Who's hungry?", "Pizza", "Burgers"}},
+		{"Who's hungry? Don't know", []string{"Who's", "hungry?", "Don't", "know"}},
+		{"'90s 80s", []string{"'90s", "80s"}},
+		{`"Say \"cheese\"" C:\\temp C:\temp \'a b\'`, []string{`Say "cheese"`, `C:\temp`, `C:\temp`, "'a", "b'"}},
+		{"a\tb \r c", []string{"a", "b", "c"}},
+		{`"" a`, []string{"", "a"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.arguments, func(t *testing.T) {
+			if diff := deep.Equal(testCase.expectedResult, ParseSlashCommand(testCase.arguments)); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestTokenizeMultiLineSlashCommand(t *testing.T) {
+	tokens := TokenizeSlashCommand("Where do we go for lunch?\n  Pizza place \n\nThai food\n--multi --max 2\n\"--- not a flag\"\n")
+	expected := []Token{{"Where do we go for lunch?", true}, {"Pizza place", true}, {"Thai food", true},
+		{"--multi", false}, {"--max", false}, {"2", false}, {"--- not a flag", true}}
+	if diff := deep.Equal(expected, tokens); diff != nil {
+		t.Error(diff)
+	}
+	tokens = TokenizeSlashCommand("\"Lunch?\"\nPizza\n\"Thai food\"")
+	expected = []Token{{"Lunch?", true}, {"Pizza", false}, {"Thai food", true}}
+	if diff := deep.Equal(expected, tokens); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func FuzzTokenizeSlashCommand(f *testing.F) {
+	for _, seed := range []string{"", `"`, `a b"c d`, "“Lunch?” ‘Ice cream’ «a»", `'Who's hungry?'`, `\"a\\`, "q\nPizza\n--multi"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, arguments string) {
+		if !utf8.ValidString(arguments) {
+			t.Skip("Slack only sends valid UTF-8")
+		}
+		tokens := TokenizeSlashCommand(arguments)
+		quotedArguments := make([]string, len(tokens))
+		for i, token := range tokens {
+			quotedArguments[i] = QuoteSlashCommandArgument(token.Text)
+		}
+		requotedTokens := TokenizeSlashCommand(strings.Join(quotedArguments, " "))
+		for i := range tokens {
+			tokens[i].Quoted = true
+		}
+		if diff := deep.Equal(tokens, requotedTokens); diff != nil {
+			t.Errorf("Quoting the arguments of %q changed them: %v", arguments, diff)
+		}
+	})
+}
+
+func TestParsePollSpec(t *testing.T) {
+	testCases := []struct {
+		command      string
+		pollType     poll.PollType
+		expectedSpec PollSpec
+	}{
+		{`"Release day?" Mon Tue Wed --anonymous --multi --max 2 --closes 48h`, poll.PollTypeSingleChoice,
+			PollSpec{Question: "Release day?", Options: []string{"Mon", "Tue", "Wed"}, Anonymous: true, Type: poll.PollTypeMultipleChoice, MaxChoices: 2, ClosesIn: 48 * time.Hour}},
+		{`--max=3 --closes=2d q a b`, poll.PollTypeMultipleChoice,
+			PollSpec{Question: "q", Options: []string{"a", "b"}, Type: poll.PollTypeMultipleChoice, MaxChoices: 3, ClosesIn: 48 * time.Hour}},
+		{`q "--verbose" -1 -- --multi --max`, poll.PollTypeSingleChoice,
+			PollSpec{Question: "q", Options: []string{"--verbose", "-1", "--multi", "--max"}}},
+		{`q a b --hide-results --closes 1h30m`, poll.PollTypeRankedChoice,
+			PollSpec{Question: "q", Options: []string{"a", "b"}, Type: poll.PollTypeRankedChoice, ClosesIn: 90 * time.Minute, HideResults: true}},
+		{`q a --write-ins b`, poll.PollTypeSingleChoice,
+			PollSpec{Question: "q", Options: []string{"a", "b"}, AllowWriteIns: true}},
+	}
+	for _, testCase := range testCases {
+		spec, err := ParsePollSpec(TokenizeSlashCommand(testCase.command), false, testCase.pollType)
+		if err != nil {
+			t.Errorf("Error parsing %q: %v", testCase.command, err)
+			continue
+		}
+		if diff := deep.Equal(testCase.expectedSpec, spec); diff != nil {
+			t.Errorf("Unexpected spec for %q: %v", testCase.command, diff)
+		}
+	}
+}
+
+func TestParseInvalidPollSpec(t *testing.T) {
+	testCases := []struct {
+		command       string
+		pollType      poll.PollType
+		expectedError string
+	}{
+		{`q a b --max`, poll.PollTypeMultipleChoice, "--max needs the maximum number of choices as value"},
+		{`q a b --max 0`, poll.PollTypeMultipleChoice, `Invalid maximum number of choices "0"`},
+		{`q a b --max 2`, poll.PollTypeSingleChoice, "--max can only be used for multiple choice polls, add --multi"},
+		{`q a b --multi`, poll.PollTypeRankedChoice, "--multi can't be used for ranked choice polls"},
+		{`q a b --closes`, poll.PollTypeSingleChoice, "--closes needs the time until the poll closes as value, e.g. 48h"},
+		{`q a b --closes -1h`, poll.PollTypeSingleChoice, `Invalid time until the poll closes "-1h", use e.g. 30m, 48h or 2d`},
+		{`q a b --closes soon`, poll.PollTypeSingleChoice, `Invalid time until the poll closes "soon", use e.g. 30m, 48h or 2d`},
+		{`q a b --anonymous=yes`, poll.PollTypeSingleChoice, "--anonymous doesn't take a value"},
+		{`q a b --verbose`, poll.PollTypeSingleChoice, "Unknown flag --verbose, put options starting with -- in quotes"},
+		{`--anonymous`, poll.PollTypeSingleChoice, "The poll command needs a question followed by the options"},
+	}
+	for _, testCase := range testCases {
+		_, err := ParsePollSpec(TokenizeSlashCommand(testCase.command), false, testCase.pollType)
+		if err == nil || err.Error() != testCase.expectedError {
+			t.Errorf("Expected error %q for %q but got %v", testCase.expectedError, testCase.command, err)
+		}
+	}
+}
+
+func TestParseEmptyPollCommand(t *testing.T) {
+	for _, commandText := range []string{"", " ", "\""} {
+		_, err := ParsePollCommand(commandText, false, poll.PollTypeSingleChoice)
+		if err == nil {
+			t.Errorf("Expected error for poll command %q", commandText)
+		}
+	}
+}
+
+func TestNewPollFromSpec(t *testing.T) {
+	now := time.Date(2018, 7, 12, 18, 36, 58, 500, time.UTC)
+	spec := PollSpec{Question: "q", Options: []string{"a", "b"}, Anonymous: true, ClosesIn: 48 * time.Hour, HideResults: true}
+	newPoll, err := spec.NewPoll(now)
+	expectedPoll := poll.Poll{Question: "q", Options: poll.NewOptions("a", "b"), Anonymous: true, Deadline: time.Date(2018, 7, 14, 18, 36, 58, 0, time.UTC), HideResults: true}
+	if diff := deep.Equal(expectedPoll, newPoll); err != nil || diff != nil {
+		t.Fatalf("Unexpected poll %v: %v", diff, err)
+	}
+}
+
 func printError(t *testing.T, actual, expected interface{}) {
 	t.Error("Test failed! Expected: \n", spew.Sdump(expected), "\nbut was:\n", spew.Sdump(actual))
 }
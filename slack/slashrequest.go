@@ -15,6 +15,8 @@ type SlashCommandRequest struct {
 	Command     string
 	MsgText     string
 	ResponseURL string
+	// TriggerID allows opening a modal within three seconds of the command.
+	TriggerID string
 }
 
 func NewSlackRequest(requestParams url.Values) SlashCommandRequest {
@@ -29,5 +31,6 @@ func NewSlackRequest(requestParams url.Values) SlashCommandRequest {
 	request.Command = requestParams.Get("command")
 	request.MsgText = requestParams.Get("text")
 	request.ResponseURL = requestParams.Get("response_url")
+	request.TriggerID = requestParams.Get("trigger_id")
 	return request
 }
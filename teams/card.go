@@ -0,0 +1,155 @@
+package teams
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+const (
+	ActivityTypeMessage     = "message"
+	ContentTypeAdaptiveCard = "application/vnd.microsoft.card.adaptive"
+	adaptiveCardSchema      = "http://adaptivecards.io/schemas/adaptive-card.json"
+	adaptiveCardVersion     = "1.2"
+	// ActionVoteForOption buttons hold the ID of the option. ActionVote
+	// buttons of cards sent before options had IDs hold its position.
+	ActionVoteForOption = "vote_option"
+	ActionVote          = "vote"
+	ActionRefresh       = "refresh"
+	ActionClosePoll     = "close"
+)
+
+// Activity is the subset of the Bot Framework activity schema used by
+// outgoing webhooks, both for requests and responses.
+type Activity struct {
+	Type        string          `json:"type"`
+	ID          string          `json:"id,omitempty"`
+	Text        string          `json:"text,omitempty"`
+	From        *ChannelAccount `json:"from,omitempty"`
+	Attachments []Attachment    `json:"attachments,omitempty"`
+	// Value holds the data of a submitted Adaptive Card action.
+	Value *ActionData `json:"value,omitempty"`
+}
+
+type ChannelAccount struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	AADObjectID string `json:"aadObjectId,omitempty"`
+}
+
+type Attachment struct {
+	ContentType string       `json:"contentType"`
+	Content     AdaptiveCard `json:"content"`
+}
+
+// ActionData is sent back by Teams when an Action.Submit button is clicked.
+type ActionData struct {
+	Action string `json:"action"`
+	PollID string `json:"poll_id"`
+	Option int    `json:"option"`
+}
+
+type AdaptiveCard struct {
+	Type    string        `json:"type"`
+	Schema  string        `json:"$schema"`
+	Version string        `json:"version"`
+	Body    []CardElement `json:"body"`
+	Actions []CardAction  `json:"actions,omitempty"`
+}
+
+// CardElement is either a TextBlock or a FactSet.
+type CardElement struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Weight   string `json:"weight,omitempty"`
+	Size     string `json:"size,omitempty"`
+	IsSubtle bool   `json:"isSubtle,omitempty"`
+	Wrap     bool   `json:"wrap,omitempty"`
+	Facts    []Fact `json:"facts,omitempty"`
+}
+
+type Fact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type CardAction struct {
+	Type  string      `json:"type"`
+	Title string      `json:"title"`
+	Data  *ActionData `json:"data,omitempty"`
+}
+
+func (a *Activity) ToJSON() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+func newSubmitAction(title, action, pollID string, option int) CardAction {
+	return CardAction{Type: "Action.Submit", Title: title, Data: &ActionData{action, pollID, option}}
+}
+
+func NewPollCard(p poll.Poll, results map[int]uint64) AdaptiveCard {
+	card := AdaptiveCard{Type: "AdaptiveCard", Schema: adaptiveCardSchema, Version: adaptiveCardVersion}
+	card.Body = append(card.Body, CardElement{Type: "TextBlock", Text: p.Question, Weight: "Bolder", Size: "Medium", Wrap: true})
+	resultFacts := CardElement{Type: "FactSet"}
+	for _, option := range p.Options {
+		resultFacts.Facts = append(resultFacts.Facts, Fact{option.Text(), formatVoteCount(results[option.ID])})
+	}
+	card.Body = append(card.Body, resultFacts)
+	if p.Closed {
+		card.Body = append(card.Body, CardElement{Type: "TextBlock", Text: "This poll is closed.", IsSubtle: true, Wrap: true})
+		return card
+	}
+	if hint := votingHint(p); hint != "" {
+		card.Body = append(card.Body, CardElement{Type: "TextBlock", Text: hint, IsSubtle: true, Wrap: true})
+	}
+	for _, option := range p.Options {
+		card.Actions = append(card.Actions, newSubmitAction(option.Text(), ActionVoteForOption, p.ID, option.ID))
+	}
+	card.Actions = append(card.Actions, newSubmitAction("Refresh", ActionRefresh, p.ID, 0), newSubmitAction("Close poll", ActionClosePoll, p.ID, 0))
+	return card
+}
+
+func NewPollMessage(p poll.Poll, results map[int]uint64) Activity {
+	return Activity{Type: ActivityTypeMessage, Attachments: []Attachment{{ContentTypeAdaptiveCard, NewPollCard(p, results)}}}
+}
+
+func NewTextMessage(text string) Activity {
+	return Activity{Type: ActivityTypeMessage, Text: text}
+}
+
+func NewVoteDetailText(results map[string][]string) string {
+	var options []string
+	for option := range results {
+		options = append(options, option)
+	}
+	sort.Strings(options)
+	var lines []string
+	for _, option := range options {
+		lines = append(lines, fmt.Sprintf("- %s: %s", option, strings.Join(results[option], ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func votingHint(p poll.Poll) string {
+	if p.Type != poll.PollTypeMultipleChoice {
+		return ""
+	}
+	if p.MaxChoices > 1 {
+		return fmt.Sprintf("Select up to %d options, click again to remove a vote", p.MaxChoices)
+	}
+	if p.MaxChoices == 1 {
+		return "Select one option, click again to remove your vote"
+	}
+	return "Select all options that apply, click again to remove a vote"
+}
+
+func formatVoteCount(voteCount uint64) string {
+	voteCountText := "Vote"
+	if voteCount != 1 {
+		voteCountText += "s"
+	}
+	return fmt.Sprintf("%d %s", voteCount, voteCountText)
+}
@@ -0,0 +1,158 @@
+package teams
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"html"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"markusreschke.name/selfhostedchatpolling/chat"
+	"markusreschke.name/selfhostedchatpolling/poll"
+	"markusreschke.name/selfhostedchatpolling/slack"
+)
+
+const (
+	PlatformName          = "teams"
+	contentTypeJSON       = "application/json"
+	httpHeaderContentType = "Content-Type"
+)
+
+var (
+	mentionPattern = regexp.MustCompile(`<at>[^<]*</at>`)
+	tagPattern     = regexp.MustCompile(`<[^>]*>`)
+)
+
+type PlatformConfig struct {
+	// WebhookSecret is the base64 encoded security token of the outgoing
+	// webhook.
+	WebhookSecret string
+	LogTraffic    bool
+}
+
+// Platform is the chat.Platform for Microsoft Teams outgoing webhooks. A
+// message mentioning the webhook creates a poll, the buttons of the poll card
+// post their actions back to the same webhook.
+type Platform struct {
+	key        []byte
+	logTraffic bool
+	logger     *log.Logger
+}
+
+func NewPlatform(config PlatformConfig, logger *log.Logger) (*Platform, error) {
+	key, err := base64.StdEncoding.DecodeString(config.WebhookSecret)
+	if err != nil {
+		return nil, errors.Wrap(err, "Webhook secret isn't base64 encoded!")
+	}
+	return &Platform{key, config.LogTraffic, logger}, nil
+}
+
+func (p *Platform) Name() string {
+	return PlatformName
+}
+
+// extractCommandText removes the mention of the webhook and the HTML markup
+// Teams adds to the message text.
+func extractCommandText(text string) string {
+	text = mentionPattern.ReplaceAllString(text, "")
+	text = html.UnescapeString(tagPattern.ReplaceAllString(text, ""))
+	text = strings.Replace(text, "\u00a0", " ", -1)
+	return strings.TrimSpace(text)
+}
+
+func (p *Platform) ParseCommand(writer http.ResponseWriter, request *http.Request) (chat.Command, bool) {
+	if request.Method != http.MethodPost {
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		p.logger.Println("MethodNotAllowed")
+		return chat.Command{}, false
+	}
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Couldn't read body", err)
+		return chat.Command{}, false
+	}
+	if p.logTraffic {
+		p.logger.Printf("Teams Request: %s\n", string(body))
+	}
+	err = VerifySignature(request.Header.Get(AuthorizationHeader), body, p.key)
+	if err != nil {
+		writer.WriteHeader(http.StatusUnauthorized)
+		p.logger.Println("Unauthorized - Request verification failed: ", err)
+		return chat.Command{}, false
+	}
+	var activity Activity
+	err = json.Unmarshal(body, &activity)
+	if err != nil || activity.From == nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		p.logger.Println("BadRequest - Couldn't parse activity", err)
+		return chat.Command{}, false
+	}
+	command := chat.Command{UserID: activity.From.ID, Origin: activity}
+	if activity.Value != nil {
+		command.PollID = activity.Value.PollID
+		command.Option = activity.Value.Option
+		switch activity.Value.Action {
+		case ActionVoteForOption:
+			command.Action = chat.ActionVote
+		case ActionVote:
+			command.Action = chat.ActionVote
+			command.OptionIsPosition = true
+		case ActionRefresh:
+			command.Action = chat.ActionRefresh
+		case ActionClosePoll:
+			command.Action = chat.ActionClosePoll
+		default:
+			writer.WriteHeader(http.StatusBadRequest)
+			p.logger.Printf("BadRequest - Unknown action %q", activity.Value.Action)
+			return chat.Command{}, false
+		}
+		return command, true
+	}
+	newPoll, err := slack.ParsePollCommand(extractCommandText(activity.Text), false, poll.PollTypeSingleChoice)
+	if err == nil && newPoll.Type != poll.PollTypeSingleChoice {
+		err = errors.New("Only single choice polls can be created in Teams!")
+	}
+	if err == nil && newPoll.HideResults {
+		err = errors.New("Hidden results aren't supported in Teams!")
+	}
+	if err == nil && newPoll.AllowWriteIns {
+		err = errors.New("Write-ins aren't supported in Teams!")
+	}
+	// Only Slack and Matrix update the message of polls closed by their deadline
+	if err == nil && !newPoll.Deadline.IsZero() {
+		err = errors.New("Deadlines aren't supported in Teams!")
+	}
+	if err != nil {
+		p.logger.Println("Invalid poll command: ", err)
+		p.writeActivity(writer, NewTextMessage(err.Error()))
+		return chat.Command{}, false
+	}
+	command.Action = chat.ActionCreatePoll
+	command.Poll = newPoll
+	return command, true
+}
+
+func (p *Platform) WriteReply(writer http.ResponseWriter, command chat.Command, reply chat.Reply) {
+	switch reply.Kind {
+	case chat.ReplyPoll:
+		p.writeActivity(writer, NewPollMessage(reply.Poll, reply.Results))
+	case chat.ReplyVoteDetails:
+		p.writeActivity(writer, NewTextMessage(NewVoteDetailText(reply.Details)))
+	case chat.ReplyRankingForm:
+		p.writeActivity(writer, NewTextMessage("Ranked choice polls aren't supported on Teams!"))
+	default:
+		p.writeActivity(writer, NewTextMessage(reply.Text))
+	}
+}
+
+func (p *Platform) writeActivity(writer http.ResponseWriter, activity Activity) {
+	writer.Header().Set(httpHeaderContentType, contentTypeJSON)
+	writer.WriteHeader(http.StatusOK)
+	responseJSON, _ := activity.ToJSON()
+	writer.Write(responseJSON)
+}
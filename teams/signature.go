@@ -0,0 +1,39 @@
+package teams
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	AuthorizationHeader = "Authorization"
+	authorizationScheme = "HMAC "
+)
+
+var (
+	ErrMissingSignature = errors.New("Request is not signed!")
+	ErrInvalidSignature = errors.New("Request signature is invalid!")
+)
+
+// VerifySignature checks the HMAC-SHA256 signature Teams sends with requests
+// of outgoing webhooks in the Authorization header. The key is the base64
+// decoded security token shown when the webhook is created.
+func VerifySignature(authorization string, body []byte, key []byte) error {
+	if !strings.HasPrefix(authorization, authorizationScheme) {
+		return ErrMissingSignature
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(authorization, authorizationScheme))
+	if err != nil {
+		return errors.Wrap(ErrInvalidSignature, "Signature isn't base64 encoded")
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package teams
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/pkg/errors"
+	"markusreschke.name/selfhostedchatpolling/chat"
+	"markusreschke.name/selfhostedchatpolling/poll"
+)
+
+const testWebhookSecret = "c2hjcC10ZWFtcy13ZWJob29rLXRlc3Qta2V5LTAxMjM="
+
+func TestMain(m *testing.M) {
+	flag.Bool("integration", false, "run integration tests")
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+type recordedRequest struct {
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+func readRecordedRequest(t *testing.T, fileName string) *http.Request {
+	dat, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal("Error reading sample file: ", err)
+	}
+	var recorded recordedRequest
+	err = json.Unmarshal(dat, &recorded)
+	if err != nil {
+		t.Fatal("Error parsing sample file: ", err)
+	}
+	request := httptest.NewRequest(http.MethodPost, "/teams/messages", bytes.NewBufferString(recorded.Body))
+	for name, value := range recorded.Headers {
+		request.Header.Set(name, value)
+	}
+	return request
+}
+
+func newTestPlatform(t *testing.T) *Platform {
+	platform, err := NewPlatform(PlatformConfig{WebhookSecret: testWebhookSecret}, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatal("Error creating platform: ", err)
+	}
+	return platform
+}
+
+func TestVerifySignature(t *testing.T) {
+	key := newTestPlatform(t).key
+	testCases := []struct {
+		name          string
+		fixture       string
+		authorization string
+		tamperBody    bool
+		expectedError error
+	}{
+		{"SignedMessage", "exampleOutgoingWebhookMessage.json", "", false, nil},
+		{"SignedCardSubmit", "exampleCardSubmitMessage.json", "", false, nil},
+		{"TamperedBody", "exampleOutgoingWebhookMessage.json", "", true, ErrInvalidSignature},
+		{"MissingSignature", "exampleOutgoingWebhookMessage.json", "Bearer token", false, ErrMissingSignature},
+		{"MalformedSignature", "exampleOutgoingWebhookMessage.json", "HMAC %%%", false, ErrInvalidSignature},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := readRecordedRequest(t, testCase.fixture)
+			body, _ := ioutil.ReadAll(request.Body)
+			if testCase.tamperBody {
+				body = bytes.Replace(body, []byte("Pizza"), []byte("Pasta"), 1)
+			}
+			authorization := request.Header.Get(AuthorizationHeader)
+			if testCase.authorization != "" {
+				authorization = testCase.authorization
+			}
+			err := VerifySignature(authorization, body, key)
+			if errors.Cause(err) != testCase.expectedError {
+				t.Errorf("Expected error %v but got %v", testCase.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestParsingPollCreation(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	command, ok := newTestPlatform(t).ParseCommand(recorder, readRecordedRequest(t, "exampleOutgoingWebhookMessage.json"))
+	if !ok {
+		t.Fatalf("Expected valid command but got status %d", recorder.Code)
+	}
+	expectedPoll := poll.Poll{Question: "Where do we go for lunch?", Options: poll.NewOptions("Pizza", "Thai food", "Burgers")}
+	if diff := deep.Equal(expectedPoll, command.Poll); command.Action != chat.ActionCreatePoll || command.UserID != "29:1dWpdqnmXPx8Xl5A" || diff != nil {
+		t.Errorf("Unexpected command for poll creation: %v", command)
+	}
+}
+
+func TestParsingCardSubmit(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	command, ok := newTestPlatform(t).ParseCommand(recorder, readRecordedRequest(t, "exampleCardSubmitMessage.json"))
+	if !ok {
+		t.Fatalf("Expected valid command but got status %d", recorder.Code)
+	}
+	if command.Action != chat.ActionVote || command.PollID != "6b57e603-2366-4116-b51d-011837677e33" || command.Option != 1 || !command.OptionIsPosition || command.UserID != "29:1dWpdqnmXPx8Xl5A" {
+		t.Errorf("Unexpected command for card submit: %v", command)
+	}
+}
+
+func TestRejectingUnsignedRequest(t *testing.T) {
+	request := readRecordedRequest(t, "exampleCardSubmitMessage.json")
+	request.Header.Del(AuthorizationHeader)
+	recorder := httptest.NewRecorder()
+	_, ok := newTestPlatform(t).ParseCommand(recorder, request)
+	if ok || recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected unsigned request to be rejected but got %v and status %d", ok, recorder.Code)
+	}
+}
+
+func TestPollMessage(t *testing.T) {
+	dat, err := ioutil.ReadFile("exampleTeamsPollMessage.json")
+	if err != nil {
+		t.Fatal("Error reading sample file: ", err)
+	}
+	var expectedMessage Activity
+	err = json.Unmarshal(dat, &expectedMessage)
+	if err != nil {
+		t.Fatal("Error parsing sample file: ", err)
+	}
+	testPoll := poll.Poll{ID: "6b57e603-2366-4116-b51d-011837677e33", Question: "Where do we go for lunch?", CreatorID: "29:1dWpdqnmXPx8Xl5A", Options: poll.NewOptions("Pizza", "Thai food", "Burgers")}
+	actualMessage := NewPollMessage(testPoll, map[int]uint64{0: 2, 1: 1})
+	if diff := deep.Equal(expectedMessage, actualMessage); diff != nil {
+		t.Error("Created message is not as expected: ", diff)
+	}
+}
+
+func TestRejectingDeadlines(t *testing.T) {
+	platform := newTestPlatform(t)
+	request := readRecordedRequest(t, "exampleOutgoingWebhookMessage.json")
+	body, _ := ioutil.ReadAll(request.Body)
+	body = bytes.Replace(body, []byte("Burgers"), []byte("Burgers --closes 2h"), 1)
+	mac := hmac.New(sha256.New, platform.key)
+	mac.Write(body)
+	request = httptest.NewRequest(http.MethodPost, "/teams/messages", bytes.NewReader(body))
+	request.Header.Set(AuthorizationHeader, "HMAC "+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	recorder := httptest.NewRecorder()
+	_, ok := platform.ParseCommand(recorder, request)
+	if ok || !strings.Contains(recorder.Body.String(), "Deadlines aren't supported in Teams!") {
+		t.Errorf("Expected poll with deadline to be rejected but got %v and %q", ok, recorder.Body.String())
+	}
+}